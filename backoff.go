@@ -0,0 +1,84 @@
+package graphql
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff decides how long to wait before each retry of a request, such
+// as one of ConstantBackoff, ExponentialBackoff and
+// DecorrelatedJitterBackoff, or an adapter for a backoff library:
+//
+//	graphql.WithBackoff(graphql.BackoffFunc(func(attempt int, previous time.Duration, err error) time.Duration {
+//		return time.Duration(attempt) * time.Second
+//	}))
+type Backoff interface {
+	// Delay returns the delay before retry number attempt, counting
+	// from 1, of a request that failed with err, given the delay before
+	// the previous retry, which is zero before the first.
+	Delay(attempt int, previous time.Duration, err error) time.Duration
+}
+
+// BackoffFunc is a function used as a Backoff.
+type BackoffFunc func(attempt int, previous time.Duration, err error) time.Duration
+
+// Delay returns fn(attempt, previous, err).
+func (fn BackoffFunc) Delay(attempt int, previous time.Duration, err error) time.Duration {
+	return fn(attempt, previous, err)
+}
+
+// ConstantBackoff waits d before every retry.
+func ConstantBackoff(d time.Duration) Backoff {
+	return BackoffFunc(func(int, time.Duration, error) time.Duration {
+		return d
+	})
+}
+
+// ExponentialBackoff waits base before the first retry, doubling the delay
+// before each retry after it up to max, with random jitter so that clients
+// failing together do not retry together: each delay is between half and
+// all of the doubled delay. It is the backoff of WithRetry.
+func ExponentialBackoff(base, max time.Duration) Backoff {
+	if base <= 0 {
+		base = defaultBackoff
+	}
+	return BackoffFunc(func(attempt int, _ time.Duration, _ error) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	})
+}
+
+// DecorrelatedJitterBackoff waits a random delay between base and three
+// times the previous delay, up to max, which spreads out the retries of
+// clients failing together more than ExponentialBackoff does.
+func DecorrelatedJitterBackoff(base, max time.Duration) Backoff {
+	if base <= 0 {
+		base = defaultBackoff
+	}
+	return BackoffFunc(func(_ int, previous time.Duration, _ error) time.Duration {
+		if previous < base {
+			previous = base
+		}
+		d := base + time.Duration(rand.Int63n(int64(previous*3-base)+1))
+		if d > max {
+			d = max
+		}
+		return d
+	})
+}
+
+// WithBackoff sets the Backoff deciding the delay before each retry, in
+// place of the exponential backoff of WithRetry. When a 429 or 503
+// response has a Retry-After header, the delay it sets is still used
+// instead.
+func WithBackoff(backoff Backoff) ClientOption {
+	return func(client *Client) {
+		client.backoffStrategy = backoff
+	}
+}
@@ -0,0 +1,82 @@
+package graphql
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestConstantBackoff(t *testing.T) {
+	is := is.New(t)
+	backoff := ConstantBackoff(time.Second)
+	is.Equal(backoff.Delay(1, 0, nil), time.Second)
+	is.Equal(backoff.Delay(5, time.Second, nil), time.Second)
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	is := is.New(t)
+	backoff := ExponentialBackoff(100*time.Millisecond, time.Second)
+	for attempt, max := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		for i := 0; i < 10; i++ {
+			delay := backoff.Delay(attempt, 0, nil)
+			is.True(delay >= max/2)
+			is.True(delay <= max)
+		}
+	}
+}
+
+func TestDecorrelatedJitterBackoff(t *testing.T) {
+	is := is.New(t)
+	backoff := DecorrelatedJitterBackoff(100*time.Millisecond, time.Second)
+	var previous time.Duration
+	for attempt := 1; attempt <= 20; attempt++ {
+		delay := backoff.Delay(attempt, previous, nil)
+		is.True(delay >= 100*time.Millisecond)
+		is.True(delay <= time.Second)
+		if previous > 0 {
+			is.True(delay <= 3*previous)
+		}
+		previous = delay
+	}
+}
+
+func TestWithBackoff(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	type call struct {
+		attempt  int
+		previous time.Duration
+	}
+	var calledWith []call
+	backoff := BackoffFunc(func(attempt int, previous time.Duration, err error) time.Duration {
+		is.True(err != nil)
+		calledWith = append(calledWith, call{attempt, previous})
+		return time.Duration(attempt) * time.Millisecond
+	})
+	client := NewClient(srv.URL, WithRetry(3, time.Second), WithBackoff(backoff))
+	is.True(client.Run(ctx, NewRequest("query {}"), nil) != nil)
+	is.Equal(calls, 3)
+	is.Equal(calledWith, []call{{1, 0}, {2, time.Millisecond}})
+
+	// The backoff of a request takes precedence
+	calledWith = nil
+	req := NewRequest("query {}")
+	req.Retry(2, time.Millisecond)
+	is.True(client.Run(ctx, req, nil) != nil)
+	is.Equal(len(calledWith), 0)
+}
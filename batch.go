@@ -0,0 +1,230 @@
+package graphql
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// BatchError is returned by RunBatch when some of the operations of a
+// batch fail.
+type BatchError struct {
+	// Errors holds the error of each operation, in the order of the
+	// requests, which is nil for the operations that succeeded.
+	Errors []error
+}
+
+func (e *BatchError) Error() string {
+	var failed int
+	var first error
+	for _, err := range e.Errors {
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			failed++
+		}
+	}
+	return fmt.Sprintf("graphql: %d of %d operations in batch failed: %v", failed, len(e.Errors), first)
+}
+
+// Unwrap returns the errors of the operations that failed, for errors.Is
+// and errors.As.
+func (e *BatchError) Unwrap() []error {
+	var errs []error
+	for _, err := range e.Errors {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// batchOperation is an operation in the body of a batched request.
+type batchOperation struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// RunBatch runs the operations of reqs in a single HTTP request, sending
+// them as a JSON array and decoding the response data of each into the
+// response object at the same index in resps, which may be nil, or hold
+// nil objects, to discard the data. Servers such as Apollo Server and
+// Hasura support batching requests in this way.
+//
+// If the batch as a whole fails, such as when the server cannot be
+// reached, the error is returned as Run would return it. If only some of
+// the operations fail, a *BatchError holds the error of each operation,
+// and the data of the others is decoded. Batches are sent with the
+// headers of every request, and are not retried.
+//
+// If any of the requests has files, or Upload variables, the batch is
+// sent in the batched form of the multipart request specification, with
+// the operations field holding the array of operations, whatever the
+// transport of the client.
+func (c *Client) RunBatch(ctx context.Context, reqs []*Request, resps []interface{}) error {
+	if len(reqs) == 0 {
+		return errors.New("graphql: no requests to batch")
+	}
+	if resps != nil && len(resps) != len(reqs) {
+		return fmt.Errorf("graphql: %d response objects for %d requests", len(resps), len(reqs))
+	}
+	if c.transportErr != nil {
+		return c.transportErr
+	}
+	if _, ok := ctx.Deadline(); !ok && c.defaultTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.defaultTimeout)
+		defer cancel()
+	}
+	batch := &Request{Header: make(http.Header), idempotent: true}
+	operations := make([]batchOperation, len(reqs))
+	uploads := make([]multipartRequestSpecQuery, len(reqs))
+	var multipart bool
+	for i, req := range reqs {
+		ex, err := c.prepare(req)
+		if err != nil {
+			return err
+		}
+		operations[i] = batchOperation{Query: ex.q, OperationName: ex.operationName, Variables: ex.vars}
+		uploads[i] = ex.fillMultipartRequestSpecQuery()
+		multipart = multipart || len(uploads[i].Map) > 0
+		c.logf(">> variables: %v", ex.vars)
+		c.logf(">> query: %s", ex.logged)
+		for key, values := range req.Header {
+			batch.Header[key] = append(batch.Header[key], values...)
+		}
+		batch.idempotent = batch.idempotent && req.resendable()
+	}
+	ex := &execution{Request: batch, contentType: "application/json; charset=utf-8"}
+	if multipart {
+		body, contentType, err := c.batchMultipartBody(ctx, reqs, uploads)
+		if err != nil {
+			return err
+		}
+		ex.body, ex.contentType = body, contentType
+	} else {
+		body, err := json.Marshal(operations)
+		if err != nil {
+			return fmt.Errorf("failed to encode request body: %w", err)
+		}
+		ex.body = body
+	}
+	release, err := c.admit(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	res, err := c.sendHedged(ctx, ex)
+	if err != nil {
+		return &TransportError{Err: err}
+	}
+	defer res.Body.Close()
+
+	var r io.Reader = res.Body
+	if c.maxResponseBytes > 0 {
+		r = &maxBytesReader{r: r, remaining: c.maxResponseBytes, limit: c.maxResponseBytes}
+	}
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		var tooLarge *ResponseTooLargeError
+		if errors.As(err, &tooLarge) {
+			return tooLarge
+		}
+		return &TransportError{Err: fmt.Errorf("failed to read response body: %w", err)}
+	}
+	head := raw
+	if len(head) > maxErrorBody {
+		head = head[:maxErrorBody]
+	}
+	c.logf("<< %s", head)
+	for _, req := range reqs {
+		if req.onHTTPResponse != nil {
+			req.onHTTPResponse(res)
+		}
+	}
+	var results []json.RawMessage
+	if err := json.Unmarshal(raw, &results); err != nil {
+		return c.batchFailure(res, raw, head)
+	}
+	if len(results) != len(reqs) {
+		return fmt.Errorf("graphql: batch response has %d results for %d operations", len(results), len(reqs))
+	}
+	errs := make([]error, len(reqs))
+	var failed bool
+	for i, req := range reqs {
+		gr := &graphResponse{stream: req.stream, onRawData: req.onRawData}
+		if resps != nil {
+			gr.Data = resps[i]
+		}
+		if err := c.decodeJSON(bytes.NewReader(results[i]), gr); err != nil {
+			errs[i], failed = &DecodeError{Err: err}, true
+			continue
+		}
+		if req.onExtensions != nil && gr.Extensions != nil {
+			req.onExtensions(gr.Extensions)
+		}
+		switch {
+		case len(gr.Errors) > 0:
+			errs[i] = gr.Errors
+		case c.requireData && !gr.hasData:
+			errs[i] = ErrNoData
+		}
+		failed = failed || errs[i] != nil
+	}
+	if failed {
+		return &BatchError{Errors: errs}
+	}
+	return nil
+}
+
+// batchMultipartBody returns the body of the batch of reqs in the batched
+// form of the multipart request specification, and its content type. The
+// operations are those of the multipart request specification queries of
+// reqs, whose files are numbered across the batch, with the paths in the
+// map starting with the index of their operation.
+func (c *Client) batchMultipartBody(ctx context.Context, reqs []*Request, queries []multipartRequestSpecQuery) ([]byte, string, error) {
+	operations := make([]interface{}, len(queries))
+	fileMap := make(map[string][]string)
+	var files []multipartFile
+	for i, query := range queries {
+		operations[i] = query.Operations
+		prefix := strconv.Itoa(i) + "."
+		for _, file := range query.files(reqs[i].files) {
+			key := strconv.Itoa(len(files))
+			for _, path := range query.Map[file.key] {
+				fileMap[key] = append(fileMap[key], prefix+path)
+			}
+			file.key = key
+			files = append(files, file)
+		}
+	}
+	return c.multipartRequestSpecBody(ctx, operations, fileMap, files)
+}
+
+// batchFailure returns the error for a response to a batch that is not
+// an array of results, such as an error from a server that does not
+// support batching.
+func (c *Client) batchFailure(res *http.Response, raw, head []byte) error {
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return newHTTPError(res, head)
+	}
+	gr := &graphResponse{}
+	if err := c.decodeResponse(res, bufio.NewReader(bytes.NewReader(raw)), gr); err != nil {
+		if _, ok := err.(*ContentTypeError); ok {
+			return err
+		}
+		return newMalformedResponseError(res, head, err)
+	}
+	if len(gr.Errors) > 0 {
+		return gr.Errors
+	}
+	return newMalformedResponseError(res, head, errors.New("batch response is not an array"))
+}
@@ -0,0 +1,169 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestRunBatch(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.Header.Get("Content-Type"), "application/json; charset=utf-8")
+		is.Equal(r.Header.Values("X-Team"), []string{"a", "b"})
+		var ops []batchOperation
+		is.NoErr(json.NewDecoder(r.Body).Decode(&ops))
+		is.Equal(len(ops), 3)
+		is.Equal(ops[0].Query, "query { a }")
+		is.Equal(ops[1].OperationName, "B")
+		is.Equal(ops[1].Variables["id"], "1")
+		io.WriteString(w, `[
+			{"data":{"a":"one"}},
+			{"data":{"b":"two"},"extensions":{"cost":1}},
+			{"data":null,"errors":[{"message":"no c"}]}
+		]`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	a := NewRequest("query { a }")
+	a.Header.Set("X-Team", "a")
+	b := NewRequest("query B($id: ID!) { b(id: $id) }")
+	b.OperationName("B")
+	b.Var("id", "1")
+	b.Header.Set("X-Team", "b")
+	var extensions map[string]interface{}
+	b.OnExtensions(func(ext map[string]interface{}) {
+		extensions = ext
+	})
+	var respA struct{ A string }
+	var respB struct{ B string }
+	err := client.RunBatch(ctx, []*Request{a, b, NewRequest("query { c }")}, []interface{}{&respA, &respB, nil})
+	is.Equal(calls, 1)
+	is.Equal(respA.A, "one")
+	is.Equal(respB.B, "two")
+	is.Equal(extensions["cost"], float64(1))
+
+	var batchErr *BatchError
+	is.True(errors.As(err, &batchErr))
+	is.Equal(len(batchErr.Errors), 3)
+	is.NoErr(batchErr.Errors[0])
+	is.NoErr(batchErr.Errors[1])
+	var gqlErrs Errors
+	is.True(errors.As(batchErr.Errors[2], &gqlErrs))
+	is.Equal(gqlErrs[0].Message, "no c")
+	is.True(errors.As(err, &gqlErrs)) // the errors of the operations are wrapped
+	is.Equal(err.Error(), "graphql: 1 of 3 operations in batch failed: graphql: no c")
+}
+
+func TestRunBatchFiles(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.NoErr(r.ParseMultipartForm(1 << 20))
+		var ops []struct {
+			Query     string
+			Variables map[string]interface{}
+		}
+		is.NoErr(json.Unmarshal([]byte(r.FormValue("operations")), &ops))
+		is.Equal(len(ops), 3)
+		is.Equal(ops[0].Query, "query { a }")
+		is.Equal(ops[1].Variables, map[string]interface{}{"files": []interface{}{nil}})
+		is.Equal(ops[2].Variables, map[string]interface{}{"id": "1", "file": nil})
+		var fileMap map[string][]string
+		is.NoErr(json.Unmarshal([]byte(r.FormValue("map")), &fileMap))
+		is.Equal(fileMap, map[string][]string{
+			"0": {"1.variables.files.0"},
+			"1": {"2.variables.file"},
+		})
+		for key, content := range map[string]string{"0": "first", "1": "second"} {
+			file, _, err := r.FormFile(key)
+			is.NoErr(err)
+			b, err := io.ReadAll(file)
+			is.NoErr(err)
+			is.Equal(string(b), content)
+		}
+		io.WriteString(w, `[{"data":{"a":1}},{"data":{"b":2}},{"data":{"c":3}}]`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	b := NewRequest("mutation ($files: [Upload!]!) { b(files: $files) }")
+	b.File("document", "first.txt", strings.NewReader("first"))
+	c := NewRequest("mutation ($id: ID!, $file: Upload!) { c(id: $id, file: $file) }")
+	c.Var("id", "1")
+	c.Var("file", Upload{Name: "second.txt", R: strings.NewReader("second")})
+	var resp struct{ C int }
+	is.NoErr(client.RunBatch(ctx, []*Request{NewRequest("query { a }"), b, c}, []interface{}{nil, nil, &resp}))
+	is.Equal(resp.C, 3)
+}
+
+func TestRunBatchUnsupported(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"errors":[{"message":"batching is not supported"}]}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	err := client.RunBatch(ctx, []*Request{NewRequest("{ a }"), NewRequest("{ b }")}, nil)
+	var gqlErrs Errors
+	is.True(errors.As(err, &gqlErrs))
+	is.Equal(gqlErrs[0].Message, "batching is not supported")
+}
+
+func TestRunBatchHTTPError(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		io.WriteString(w, "bad gateway")
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	err := client.RunBatch(ctx, []*Request{NewRequest("{ a }")}, nil)
+	var httpErr *HTTPError
+	is.True(errors.As(err, &httpErr))
+	is.Equal(httpErr.StatusCode, http.StatusBadGateway)
+}
+
+func TestRunBatchMismatch(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `[{"data":{"a":1}}]`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	err := client.RunBatch(ctx, []*Request{NewRequest("{ a }"), NewRequest("{ b }")}, nil)
+	is.Equal(err.Error(), "graphql: batch response has 1 results for 2 operations")
+	err = client.RunBatch(ctx, []*Request{NewRequest("{ a }")}, []interface{}{nil, nil})
+	is.Equal(err.Error(), "graphql: 2 response objects for 1 requests")
+	err = client.RunBatch(ctx, nil, nil)
+	is.Equal(err.Error(), "graphql: no requests to batch")
+}
@@ -0,0 +1,197 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batcher coalesces the queries run within a window into batches, sent
+// with RunBatch.
+type batcher struct {
+	window time.Duration
+	max    int
+
+	mu sync.Mutex
+	// pending holds the batches being collected, by the client running
+	// them and the headers of their requests, since clients made with
+	// Client.With share the batcher
+	pending map[batchKey]*pendingBatch
+}
+
+// batchKey identifies the queries that may be batched together.
+type batchKey struct {
+	client *Client
+	header string
+}
+
+// pendingBatch is a batch being collected.
+type pendingBatch struct {
+	items []*batchItem
+}
+
+// batchItem is a query waiting in a batch for its result.
+type batchItem struct {
+	ctx  context.Context
+	req  *Request
+	done chan batchResult
+}
+
+// batchResult is the result of a query in a batch. The data is decoded
+// by the caller waiting for it, so that the batch never writes to the
+// response object of a caller that has given up.
+type batchResult struct {
+	data json.RawMessage
+	err  error
+}
+
+// do runs req in the next batch of queries with the same headers,
+// decoding its data into data.
+func (b *batcher) do(ctx context.Context, c *Client, req *Request, data interface{}) error {
+	item := &batchItem{ctx: ctx, req: req, done: make(chan batchResult, 1)}
+	key := batchKey{client: c, header: headerKey(req.Header)}
+	b.mu.Lock()
+	batch, ok := b.pending[key]
+	if !ok {
+		batch = &pendingBatch{}
+		b.pending[key] = batch
+		go func() {
+			c.clock.Sleep(context.Background(), b.window)
+			b.flush(c, key, batch)
+		}()
+	}
+	batch.items = append(batch.items, item)
+	full := b.max > 0 && len(batch.items) >= b.max
+	if full {
+		delete(b.pending, key)
+	}
+	b.mu.Unlock()
+	if full {
+		go b.send(c, batch.items)
+	}
+	select {
+	case result := <-item.done:
+		if err := c.decodeData(result.data, data); err != nil {
+			return &DecodeError{Err: err}
+		}
+		return result.err
+	case <-ctx.Done():
+		b.remove(key, batch, item)
+		return ctx.Err()
+	}
+}
+
+// remove removes item from batch, collected for the requests with key,
+// unless the batch has been sent.
+func (b *batcher) remove(key batchKey, batch *pendingBatch, item *batchItem) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending[key] != batch {
+		return
+	}
+	for i, pending := range batch.items {
+		if pending == item {
+			batch.items = append(batch.items[:i:i], batch.items[i+1:]...)
+			break
+		}
+	}
+	if len(batch.items) == 0 {
+		delete(b.pending, key)
+	}
+}
+
+// flush sends batch, collected for the requests with key, unless it was
+// sent when it filled up.
+func (b *batcher) flush(c *Client, key batchKey, batch *pendingBatch) {
+	b.mu.Lock()
+	if b.pending[key] != batch {
+		b.mu.Unlock()
+		return
+	}
+	delete(b.pending, key)
+	b.mu.Unlock()
+	b.send(c, batch.items)
+}
+
+// send runs the queries of items in a batch, passing each its result. The
+// batch is canceled once every query has given up waiting for it.
+func (b *batcher) send(c *Client, items []*batchItem) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var waiting sync.WaitGroup
+	waiting.Add(len(items))
+	for _, item := range items {
+		go func(item *batchItem) {
+			select {
+			case <-item.ctx.Done():
+			case <-ctx.Done():
+			}
+			waiting.Done()
+		}(item)
+	}
+	go func() {
+		waiting.Wait()
+		cancel()
+	}()
+
+	reqs := make([]*Request, len(items))
+	data := make([]json.RawMessage, len(items))
+	resps := make([]interface{}, len(items))
+	for i, item := range items {
+		reqs[i], resps[i] = item.req, &data[i]
+	}
+	c.logf(">> sending batch of %d queries", len(items))
+	err := c.RunBatch(ctx, reqs, resps)
+	var batchErr *BatchError
+	for i, item := range items {
+		switch {
+		case errors.As(err, &batchErr):
+			item.done <- batchResult{data: data[i], err: batchErr.Errors[i]}
+		case err != nil:
+			item.done <- batchResult{err: err}
+		default:
+			item.done <- batchResult{data: data[i]}
+		}
+	}
+}
+
+// headerKey returns a key identifying the headers, so that only requests
+// with the same headers are batched together.
+func headerKey(header http.Header) string {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		for _, value := range header[key] {
+			b.WriteString(key)
+			b.WriteByte(':')
+			b.WriteString(value)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// WithBatching coalesces the queries run within window of each other
+// into batches of up to max queries, sent in a single HTTP request as
+// RunBatch sends them, for servers supporting batching. A max of zero
+// does not limit the size of batches. Each query waits up to window for
+// others to join its batch, and is retried on its own, as usual, if its
+// batch fails. Only queries with the same headers are batched together.
+//
+// Mutations, queries with files or streamed fields, persisted queries,
+// and queries run with RunResult or another transport, are sent on their
+// own.
+func WithBatching(window time.Duration, max int) ClientOption {
+	return func(client *Client) {
+		client.batcher = &batcher{window: window, max: max, pending: make(map[batchKey]*pendingBatch)}
+	}
+}
@@ -0,0 +1,207 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestWithBatching(t *testing.T) {
+	is := is.New(t)
+	var mu sync.Mutex
+	var sizes []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ops []batchOperation
+		if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+			io.WriteString(w, `{"data":{"item":"single"}}`)
+			return
+		}
+		mu.Lock()
+		sizes = append(sizes, len(ops))
+		mu.Unlock()
+		results := make([]map[string]interface{}, len(ops))
+		for i, op := range ops {
+			results[i] = map[string]interface{}{"data": map[string]interface{}{"item": op.Variables["id"]}}
+		}
+		json.NewEncoder(w).Encode(results)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithBatching(50*time.Millisecond, 3))
+	var wg sync.WaitGroup
+	items := make([]string, 4)
+	for i := range items {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := NewRequest("query ($id: ID!) { item(id: $id) }")
+			req.Var("id", strconv.Itoa(i))
+			var resp struct{ Item string }
+			is.NoErr(client.Run(ctx, req, &resp))
+			items[i] = resp.Item
+		}(i)
+	}
+	wg.Wait()
+	is.Equal(items, []string{"0", "1", "2", "3"})
+	mu.Lock()
+	is.Equal(len(sizes), 2) // a full batch of 3, then the rest after the window
+	is.Equal(sizes[0]+sizes[1], 4)
+	mu.Unlock()
+
+	// Mutations are sent on their own
+	var resp struct{ Item string }
+	is.NoErr(client.Run(ctx, NewRequest("mutation { item }"), &resp))
+	is.Equal(resp.Item, "single")
+}
+
+func TestWithBatchingHeaders(t *testing.T) {
+	is := is.New(t)
+	var mu sync.Mutex
+	var teams []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ops []batchOperation
+		is.NoErr(json.NewDecoder(r.Body).Decode(&ops))
+		is.Equal(len(ops), 1)
+		mu.Lock()
+		teams = append(teams, r.Header.Get("X-Team"))
+		mu.Unlock()
+		io.WriteString(w, `[{"data":{"item":"a"}}]`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithBatching(20*time.Millisecond, 0))
+	var wg sync.WaitGroup
+	for _, team := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(team string) {
+			defer wg.Done()
+			req := NewRequest("query { item }")
+			req.Header.Set("X-Team", team)
+			is.NoErr(client.Run(ctx, req, nil))
+		}(team)
+	}
+	wg.Wait()
+	is.Equal(len(teams), 2)
+}
+
+func TestWithBatchingCanceled(t *testing.T) {
+	is := is.New(t)
+	var mu sync.Mutex
+	var sizes []int
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ops []batchOperation
+		is.NoErr(json.NewDecoder(r.Body).Decode(&ops))
+		mu.Lock()
+		sizes = append(sizes, len(ops))
+		mu.Unlock()
+		if len(ops) == 2 {
+			<-release
+		}
+		results := make([]map[string]interface{}, len(ops))
+		for i := range ops {
+			results[i] = map[string]interface{}{"data": map[string]interface{}{"item": "sent"}}
+		}
+		json.NewEncoder(w).Encode(results)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithBatching(50*time.Millisecond, 0))
+
+	// Queries given up on before their batch is sent are removed from it
+	canceledCtx, cancelQuery := context.WithCancel(ctx)
+	var canceled, sent struct{ Item string }
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		is.Equal(client.Run(canceledCtx, NewRequest("query { item }"), &canceled), context.Canceled)
+	}()
+	go func() {
+		defer wg.Done()
+		time.Sleep(5 * time.Millisecond)
+		is.NoErr(client.Run(ctx, NewRequest("query { other }"), &sent))
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancelQuery()
+	wg.Wait()
+	is.Equal(sent.Item, "sent")
+	is.Equal(canceled.Item, "")
+
+	// Queries given up on once their batch is sent are not decoded into
+	canceledCtx, cancelQuery = context.WithCancel(ctx)
+	canceled.Item, sent.Item = "", ""
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		is.Equal(client.Run(canceledCtx, NewRequest("query { item }"), &canceled), context.Canceled)
+	}()
+	go func() {
+		defer wg.Done()
+		is.NoErr(client.Run(ctx, NewRequest("query { other }"), &sent))
+	}()
+	for {
+		mu.Lock()
+		n := len(sizes)
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancelQuery()
+	time.Sleep(10 * time.Millisecond)
+	canceled.Item = "mine" // the caller owns its response object again
+	close(release)
+	wg.Wait()
+	is.Equal(sent.Item, "sent")
+	is.Equal(canceled.Item, "mine")
+	mu.Lock()
+	is.Equal(sizes, []int{1, 2})
+	mu.Unlock()
+}
+
+func TestWithBatchingErrors(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `[{"data":{"a":1}},{"errors":[{"message":"no b"}]}]`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithBatching(time.Second, 2))
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for i, q := range []string{"{ a }", "{ b }"} {
+		wg.Add(1)
+		go func(i int, q string) {
+			defer wg.Done()
+			if i == 1 {
+				time.Sleep(10 * time.Millisecond) // join the batch second
+			}
+			errs[i] = client.Run(ctx, NewRequest(q), nil)
+		}(i, q)
+	}
+	wg.Wait()
+	is.NoErr(errs[0])
+	is.Equal(errs[1].Error(), "graphql: no b")
+}
@@ -0,0 +1,407 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Builder builds a query document programmatically, for queries whose
+// fields or arguments are only known at run time:
+//
+//	req, err := graphql.Query("items").
+//		Arg("id", graphql.Var("key")).
+//		Select("field1", "field2").
+//		Var("key", "abc").
+//		Request()
+//
+// renders query ($key: String!) { items(id: $key) { field1 field2 } }.
+// The methods of a Builder apply to the field added last, except Var and
+// Name which apply to the operation.
+type Builder struct {
+	operation string
+	name      string
+	fields    []*builderField
+	values    map[string]interface{}
+}
+
+// builderField is a field of the document built by a Builder.
+type builderField struct {
+	name       string
+	alias      string
+	args       []builderArg
+	selections []*builderField
+}
+
+type builderArg struct {
+	name  string
+	value interface{}
+}
+
+// Variable is a reference to a variable in an argument of a Builder,
+// declared by the rendered operation.
+type Variable struct {
+	name string
+	typ  string
+}
+
+// Var returns a reference to the variable with the given name, for use as
+// an argument. The type of the variable is that set with Type, or else
+// inferred from the value given to Builder.Var: String!, Int!, Float!,
+// Boolean!, or lists of them.
+func Var(name string) Variable {
+	return Variable{name: name}
+}
+
+// Type sets the GraphQL type of the variable, such as ID!.
+func (v Variable) Type(typ string) Variable {
+	v.typ = typ
+	return v
+}
+
+// Query returns a Builder for a query selecting field.
+func Query(field string) *Builder {
+	return &Builder{operation: "query", fields: []*builderField{{name: field}}}
+}
+
+// Mutation returns a Builder for a mutation selecting field.
+func Mutation(field string) *Builder {
+	return &Builder{operation: "mutation", fields: []*builderField{{name: field}}}
+}
+
+// Field returns a Builder for a field, to select it in another Builder
+// with Select, along with the arguments and fields set on it.
+func Field(name string) *Builder {
+	return &Builder{fields: []*builderField{{name: name}}}
+}
+
+// Name sets the name of the operation.
+func (b *Builder) Name(name string) *Builder {
+	b.name = name
+	return b
+}
+
+// Field adds another field, to which the following calls apply.
+func (b *Builder) Field(name string) *Builder {
+	b.fields = append(b.fields, &builderField{name: name})
+	return b
+}
+
+// Alias sets the alias of the field.
+func (b *Builder) Alias(alias string) *Builder {
+	b.last().alias = alias
+	return b
+}
+
+// Arg adds an argument to the field. The value is either a Variable,
+// or a value rendered as a literal from its JSON encoding.
+func (b *Builder) Arg(name string, value interface{}) *Builder {
+	f := b.last()
+	f.args = append(f.args, builderArg{name: name, value: value})
+	return b
+}
+
+// Select adds fields to the selection set of the field, given as names,
+// or as Builders created with Field for fields with arguments or
+// selections of their own.
+func (b *Builder) Select(fields ...interface{}) *Builder {
+	f := b.last()
+	for _, field := range fields {
+		switch field := field.(type) {
+		case string:
+			f.selections = append(f.selections, &builderField{name: field})
+		case *Builder:
+			f.selections = append(f.selections, field.fields...)
+		default:
+			panic(fmt.Sprintf("graphql: cannot select %T", field))
+		}
+	}
+	return b
+}
+
+// Var sets the value of a variable of the operation, which is sent with
+// the request.
+func (b *Builder) Var(name string, value interface{}) *Builder {
+	if b.values == nil {
+		b.values = make(map[string]interface{})
+	}
+	b.values[name] = value
+	return b
+}
+
+func (b *Builder) last() *builderField {
+	return b.fields[len(b.fields)-1]
+}
+
+// Document renders the query document.
+func (b *Builder) Document() (string, error) {
+	var buf bytes.Buffer
+	buf.WriteString(b.operation)
+	if b.operation == "" {
+		buf.WriteString("query")
+	}
+	if b.name != "" {
+		buf.WriteString(" " + b.name)
+	}
+	var vars []Variable
+	seen := make(map[string]bool)
+	collectVariables(b.fields, seen, &vars)
+	if len(vars) > 0 {
+		if b.name == "" {
+			buf.WriteString(" ")
+		}
+		buf.WriteString("(")
+		for i, v := range vars {
+			typ := v.typ
+			if typ == "" {
+				var err error
+				if typ, err = inferType(b.values[v.name]); err != nil {
+					return "", fmt.Errorf("graphql: variable $%s: %w", v.name, err)
+				}
+			}
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			fmt.Fprintf(&buf, "$%s: %s", v.name, typ)
+		}
+		buf.WriteString(")")
+	}
+	buf.WriteString(" ")
+	if err := writeSelections(&buf, b.fields); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Request returns a request for the document, with the variables set
+// with Var.
+func (b *Builder) Request() (*Request, error) {
+	q, err := b.Document()
+	if err != nil {
+		return nil, err
+	}
+	req := NewRequest(q)
+	for name, value := range b.values {
+		req.Var(name, value)
+	}
+	if b.name != "" {
+		req.OperationName(b.name)
+	}
+	return req, nil
+}
+
+// collectVariables appends the variables used in fields to vars, in the
+// order they are first used.
+func collectVariables(fields []*builderField, seen map[string]bool, vars *[]Variable) {
+	for _, f := range fields {
+		for _, arg := range f.args {
+			if v, ok := arg.value.(Variable); ok && !seen[v.name] {
+				seen[v.name] = true
+				*vars = append(*vars, v)
+			}
+		}
+		collectVariables(f.selections, seen, vars)
+	}
+}
+
+// writeSelections writes a selection set of fields to buf.
+func writeSelections(buf *bytes.Buffer, fields []*builderField) error {
+	buf.WriteString("{ ")
+	for _, f := range fields {
+		if f.alias != "" {
+			buf.WriteString(f.alias + ": ")
+		}
+		buf.WriteString(f.name)
+		if len(f.args) > 0 {
+			buf.WriteString("(")
+			for i, arg := range f.args {
+				if i > 0 {
+					buf.WriteString(", ")
+				}
+				literal, err := graphqlLiteral(arg.value)
+				if err != nil {
+					return fmt.Errorf("graphql: argument %s of %s: %w", arg.name, f.name, err)
+				}
+				buf.WriteString(arg.name + ": " + literal)
+			}
+			buf.WriteString(")")
+		}
+		buf.WriteString(" ")
+		if len(f.selections) > 0 {
+			if err := writeSelections(buf, f.selections); err != nil {
+				return err
+			}
+			buf.WriteString(" ")
+		}
+	}
+	buf.WriteString("}")
+	return nil
+}
+
+// graphqlLiteral renders value as a GraphQL literal.
+func graphqlLiteral(value interface{}) (string, error) {
+	var buf strings.Builder
+	if err := writeValueLiteral(&buf, reflect.ValueOf(value)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// writeValueLiteral writes rv as a GraphQL literal, rendering Variables
+// and Enums wherever they are in maps, slices and structs.
+func writeValueLiteral(buf *strings.Builder, rv reflect.Value) error {
+	if !rv.IsValid() {
+		buf.WriteString("null")
+		return nil
+	}
+	switch v := rv.Interface().(type) {
+	case Variable:
+		buf.WriteString("$" + v.name)
+		return nil
+	case enumValue:
+		buf.WriteString(v.enumValue())
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		return writeValueLiteral(buf, rv.Elem())
+	case reflect.Struct:
+		if isMarshaler(rv.Type()) {
+			break
+		}
+		// Convert the struct into a map, keeping the Variables and
+		// Enums it holds
+		fields, err := (*varEncoder)(nil).structVars(rv)
+		if err != nil {
+			return err
+		}
+		return writeValueLiteral(buf, reflect.ValueOf(fields))
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String || isMarshaler(rv.Type()) {
+			break
+		}
+		keys := make([]string, 0, rv.Len())
+		for _, key := range rv.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		buf.WriteString("{")
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(key + ": ")
+			if err := writeValueLiteral(buf, rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()))); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("}")
+		return nil
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 || isMarshaler(rv.Type()) {
+			break
+		}
+		buf.WriteString("[")
+		for i := 0; i < rv.Len(); i++ {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			if err := writeValueLiteral(buf, rv.Index(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("]")
+		return nil
+	}
+	b, err := json.Marshal(rv.Interface())
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var decoded interface{}
+	if err := dec.Decode(&decoded); err != nil {
+		return err
+	}
+	writeLiteral(buf, decoded)
+	return nil
+}
+
+// writeLiteral writes a value decoded from JSON as a GraphQL literal,
+// which differs from JSON in that object keys are not quoted.
+func writeLiteral(buf *strings.Builder, value interface{}) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		buf.WriteString("{")
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(key + ": ")
+			writeLiteral(buf, value[key])
+		}
+		buf.WriteString("}")
+	case []interface{}:
+		buf.WriteString("[")
+		for i, item := range value {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			writeLiteral(buf, item)
+		}
+		buf.WriteString("]")
+	default:
+		b, _ := json.Marshal(value)
+		buf.Write(b)
+	}
+}
+
+// inferType returns the GraphQL type of a variable with the given value.
+func inferType(value interface{}) (string, error) {
+	if value == nil {
+		return "", fmt.Errorf("no type set and no value to infer it from")
+	}
+	return inferTypeOf(reflect.TypeOf(value))
+}
+
+// idType is the type of ID values.
+var idType = reflect.TypeOf(ID(""))
+
+func inferTypeOf(t reflect.Type) (string, error) {
+	if t == idType {
+		return "ID!", nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "String!", nil
+	case reflect.Bool:
+		return "Boolean!", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return "Int!", nil
+	case reflect.Float32, reflect.Float64:
+		return "Float!", nil
+	case reflect.Slice, reflect.Array:
+		elem, err := inferTypeOf(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		return "[" + elem + "]!", nil
+	case reflect.Ptr:
+		elem, err := inferTypeOf(t.Elem())
+		return strings.TrimSuffix(elem, "!"), err
+	}
+	return "", fmt.Errorf("cannot infer the type of %v, set it with Type", t)
+}
@@ -0,0 +1,42 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestBuilder(t *testing.T) {
+	is := is.New(t)
+	req, err := Query("items").
+		Arg("id", Var("key")).
+		Select("field1", "field2").
+		Var("key", "abc").
+		Request()
+	is.NoErr(err)
+	is.Equal(req.Query(), `query ($key: String!) { items(id: $key) { field1 field2 } }`)
+	is.Equal(req.vars["key"], "abc")
+
+	limit := 10
+	q, err := Query("user").
+		Name("UserPosts").
+		Arg("id", Var("id").Type("ID!")).
+		Select("name", Field("posts").
+			Arg("first", Var("limit")).
+			Arg("filter", map[string]interface{}{"tags": []string{"go"}, "draft": false}).
+			Select("title")).
+		Field("viewer").Alias("me").Select("id").
+		Var("limit", &limit).
+		Document()
+	is.NoErr(err)
+	is.Equal(q, `query UserPosts($id: ID!, $limit: Int) { user(id: $id) { name posts(first: $limit, filter: {draft: false, tags: ["go"]}) { title } } me: viewer { id } }`)
+
+	q, err = Mutation("save").Arg("input", struct {
+		Name string `json:"name"`
+	}{"Mat \"M\""}).Select("ok").Document()
+	is.NoErr(err)
+	is.Equal(q, `mutation { save(input: {name: "Mat \"M\""}) { ok } }`)
+
+	_, err = Query("items").Arg("id", Var("key")).Document()
+	is.Equal(err.Error(), "graphql: variable $key: no type set and no value to infer it from")
+}
@@ -0,0 +1,536 @@
+package graphql
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultCacheEntries is the number of responses cached by clients
+// created with WithCache without a CacheStore.
+const defaultCacheEntries = 1000
+
+// validatorRetention is how long responses with an ETag or Last-Modified
+// header are kept after they expire by clients making conditional
+// requests, to revalidate them rather than fetch them again.
+const validatorRetention = 24 * time.Hour
+
+// revalidationTimeout bounds the refreshes of stale responses by clients
+// without WithDefaultTimeout, so that a server that never responds cannot
+// keep a response from being refreshed again.
+const revalidationTimeout = 30 * time.Second
+
+// CacheStats counts the lookups in the response cache of a client.
+type CacheStats struct {
+	// Hits is the number of queries answered from the cache.
+	Hits uint64
+	// Misses is the number of cacheable queries sent to the server.
+	Misses uint64
+	// Stale is the number of queries answered with a stale response, set
+	// with WithStaleWhileRevalidate, which are also counted as hits.
+	Stale uint64
+}
+
+// CacheStore stores the responses cached by a client. Values are stored
+// for at least their TTL, after which the store may drop them; the client
+// checks the expiry of the responses itself. Implementations must be safe
+// for use by multiple goroutines.
+type CacheStore interface {
+	// Get returns the value stored for key, and false if there is none.
+	Get(key string) (value []byte, ok bool, err error)
+	// Set stores value for key for ttl.
+	Set(key string, value []byte, ttl time.Duration) error
+	// Delete removes the value stored for key, if any.
+	Delete(key string) error
+}
+
+// LRUCacheStore is a CacheStore holding values in memory, dropping the
+// least recently used values once it is full.
+type LRUCacheStore struct {
+	max int
+
+	mu sync.Mutex
+	// order holds the entries from the most to the least recently used
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// lruEntry is a value stored in an LRUCacheStore.
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewLRUCacheStore makes a new, empty LRUCacheStore holding up to max
+// values.
+func NewLRUCacheStore(max int) *LRUCacheStore {
+	if max < 1 {
+		max = 1
+	}
+	return &LRUCacheStore{max: max, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// Get returns the value stored for key, unless it has expired.
+func (s *LRUCacheStore) Get(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := elem.Value.(*lruEntry)
+	if !time.Now().Before(entry.expires) {
+		s.order.Remove(elem)
+		delete(s.entries, key)
+		return nil, false, nil
+	}
+	s.order.MoveToFront(elem)
+	return entry.value, true, nil
+}
+
+// Set stores value for key for ttl, dropping the least recently used
+// value if the store is full.
+func (s *LRUCacheStore) Set(key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := &lruEntry{key: key, value: value, expires: time.Now().Add(ttl)}
+	if elem, ok := s.entries[key]; ok {
+		elem.Value = entry
+		s.order.MoveToFront(elem)
+		return nil
+	}
+	s.entries[key] = s.order.PushFront(entry)
+	for s.order.Len() > s.max {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*lruEntry).key)
+	}
+	return nil
+}
+
+// Delete removes the value stored for key.
+func (s *LRUCacheStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.entries[key]; ok {
+		s.order.Remove(elem)
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+// Len returns the number of values stored, including any that have
+// expired but not yet been dropped.
+func (s *LRUCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// responseCache caches the data of query responses in a CacheStore.
+type responseCache struct {
+	// ttl is how long responses are cached, unless the request sets
+	// its own TTL
+	ttl   time.Duration
+	store CacheStore
+	// stale is how long expired responses are served while they are
+	// refreshed
+	stale time.Duration
+	// conditional revalidates expired responses with their validators
+	conditional bool
+	// cacheControl takes the TTLs of responses from their Cache-Control
+	// headers
+	cacheControl bool
+
+	hits, misses, staleHits uint64
+
+	mu sync.Mutex
+	// refreshing holds the keys of the responses being refreshed
+	refreshing map[string]bool
+
+	// index indexes the keys of the responses cached in the store by the
+	// labels of their requests, and is shared by the caches of clients
+	// made with Client.With that share the store
+	index *cacheIndex
+}
+
+// cacheOption returns a ClientOption configuring a copy of the response
+// cache of the client with fn, so that the options of clients made with
+// Client.With do not change the cache of the client they were made from.
+func cacheOption(fn func(cache *responseCache)) ClientOption {
+	return func(client *Client) {
+		cache := &responseCache{store: NewLRUCacheStore(defaultCacheEntries), index: newCacheIndex()}
+		if client.cache != nil {
+			cache.ttl, cache.store, cache.stale = client.cache.ttl, client.cache.store, client.cache.stale
+			cache.conditional, cache.cacheControl = client.cache.conditional, client.cache.cacheControl
+			cache.index = client.cache.index
+		}
+		cache.refreshing = make(map[string]bool)
+		store := cache.store
+		fn(cache)
+		if cache.store != store {
+			cache.index = newCacheIndex()
+		}
+		client.cache = cache
+	}
+}
+
+// cacheEntry is a cached response, as stored in the CacheStore.
+type cacheEntry struct {
+	Data json.RawMessage `json:"data"`
+	// Expires is when the response stops being fresh, and StaleUntil
+	// when it stops being served stale
+	Expires    time.Time `json:"expires"`
+	StaleUntil time.Time `json:"staleUntil,omitempty"`
+	// ETag and LastModified are the validators of the response, for
+	// conditional requests
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// hasValidator reports whether the response can be revalidated with a
+// conditional request.
+func (e *cacheEntry) hasValidator() bool {
+	return e.ETag != "" || e.LastModified != ""
+}
+
+// cacheGet returns the response cached for key, or nil if there is none.
+// Failures of the store are logged, and treated as misses.
+func (c *Client) cacheGet(key string) *cacheEntry {
+	b, ok, err := c.cache.store.Get(key)
+	if err != nil {
+		c.logf("!! failed to read cache: %v", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		c.logf("!! failed to decode cached response: %v", err)
+		return nil
+	}
+	return &entry
+}
+
+// cacheSet caches the response entry for key from now for ttl, and to be
+// served stale for the stale window of the client after that.
+func (c *Client) cacheSet(key string, entry cacheEntry, now time.Time, ttl time.Duration) {
+	entry.Expires = now.Add(ttl)
+	retention := ttl
+	if c.cache.stale > 0 {
+		entry.StaleUntil = entry.Expires.Add(c.cache.stale)
+		retention += c.cache.stale
+	}
+	if c.cache.conditional && entry.hasValidator() && retention < ttl+validatorRetention {
+		retention = ttl + validatorRetention
+	}
+	b, err := json.Marshal(entry)
+	if err == nil {
+		err = c.cache.store.Set(key, b, retention)
+	}
+	if err != nil {
+		c.logf("!! failed to write cache: %v", err)
+	}
+}
+
+// revalidate refreshes the response to req cached for key in the
+// background, unless it is already being refreshed.
+func (c *Client) revalidate(key string, req *Request, ttl time.Duration, cached *cacheEntry) {
+	c.cache.mu.Lock()
+	if c.cache.refreshing[key] {
+		c.cache.mu.Unlock()
+		return
+	}
+	c.cache.refreshing[key] = true
+	c.cache.mu.Unlock()
+	// The caller may change req once Run returns
+	req = req.Clone()
+	timeout := c.defaultTimeout
+	if timeout <= 0 {
+		timeout = revalidationTimeout
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		defer func() {
+			c.cache.mu.Lock()
+			delete(c.cache.refreshing, key)
+			c.cache.mu.Unlock()
+		}()
+		c.logf(">> refreshing stale response")
+		if _, err := c.fetchCached(ctx, key, req, nil, nil, ttl, cached); err != nil {
+			c.logf("!! failed to refresh stale response: %v", err)
+		}
+	}()
+}
+
+// fetchCached runs the query of req, caching the response for key for
+// ttl if it succeeds. Clients making conditional requests send the
+// validators of the cached response, if any, and use it again if the
+// server reports it has not been modified, returning true.
+func (c *Client) fetchCached(ctx context.Context, key string, req *Request, resp interface{}, opts []RunOption, ttl time.Duration, cached *cacheEntry) (notModified bool, err error) {
+	// Capture the data and validators of the response to cache it
+	var entry cacheEntry
+	fetch := req.Clone()
+	fetch.onRawData = func(raw json.RawMessage) {
+		entry.Data = raw
+		if req.onRawData != nil {
+			req.onRawData(raw)
+		}
+	}
+	var header http.Header
+	if c.cache.conditional || c.cache.cacheControl {
+		fetch.onHTTPResponse = func(res *http.Response) {
+			header = res.Header
+			if req.onHTTPResponse != nil {
+				req.onHTTPResponse(res)
+			}
+		}
+	}
+	conditional := c.cache.conditional && cached != nil && cached.hasValidator()
+	if conditional {
+		if cached.ETag != "" {
+			fetch.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			fetch.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+	err = c.runUncached(ctx, fetch, resp, opts)
+	if c.cache.conditional && header != nil {
+		entry.ETag = header.Get("ETag")
+		entry.LastModified = header.Get("Last-Modified")
+	}
+	store := true
+	if c.cache.cacheControl && header != nil {
+		// The TTL of the request takes precedence over the header, but
+		// the server refusing to let the response be stored does not
+		headerTTL, ok, noStore := cacheControlTTL(header)
+		if ok && req.cacheTTL == 0 {
+			ttl = headerTTL
+		}
+		store = !noStore
+	}
+	if !store {
+		c.logf("<< response not stored, as Cache-Control requires")
+		if err := c.cache.store.Delete(key); err != nil {
+			c.logf("!! failed to write cache: %v", err)
+		}
+	}
+	var httpErr *HTTPError
+	if conditional && errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotModified {
+		c.logf("<< not modified, using cached response")
+		settings, err := applyRunOptions(resp, opts)
+		if err != nil {
+			return false, err
+		}
+		if err := c.decodeData(cached.Data, settings.data); err != nil {
+			return false, &DecodeError{Err: err}
+		}
+		// Keep any validators the server sent again, as well as those of
+		// the cached response
+		entry.Data = cached.Data
+		if entry.ETag == "" {
+			entry.ETag = cached.ETag
+		}
+		if entry.LastModified == "" {
+			entry.LastModified = cached.LastModified
+		}
+		if store {
+			c.cacheSet(key, entry, c.clock.Now(), ttl)
+			c.cache.index.add(cacheLabels(req), key)
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// Responses that must be revalidated are kept if they can be
+	if store && hasData(entry.Data) && (ttl > 0 || c.cache.conditional && entry.hasValidator()) {
+		c.cacheSet(key, entry, c.clock.Now(), ttl)
+		c.cache.index.add(cacheLabels(req), key)
+	}
+	return false, nil
+}
+
+// cacheControlTTL returns the TTL set by the Cache-Control header, which
+// is its max-age less the Age of the response, or zero for no-cache, and
+// whether the header forbids storing the response.
+func cacheControlTTL(header http.Header) (ttl time.Duration, ok, noStore bool) {
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			switch strings.ToLower(name) {
+			case "no-store":
+				noStore = true
+			case "no-cache":
+				ttl, ok = 0, true
+			case "max-age":
+				seconds, err := strconv.Atoi(strings.Trim(arg, `"`))
+				if err != nil || ok && ttl == 0 {
+					continue
+				}
+				ttl, ok = time.Duration(seconds)*time.Second, true
+			}
+		}
+	}
+	if ok && ttl > 0 {
+		if age, err := strconv.Atoi(header.Get("Age")); err == nil && age > 0 {
+			ttl -= time.Duration(age) * time.Second
+		}
+		if ttl < 0 {
+			ttl = 0
+		}
+	}
+	return ttl, ok, noStore
+}
+
+// CacheTTL sets how long the response to the request is cached, for
+// clients created with WithCache, overriding the TTL of the client. A TTL
+// of zero or less does not cache the response.
+func (req *Request) CacheTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = -1
+	}
+	req.cacheTTL = ttl
+}
+
+// cacheTTL returns how long the response to req is cached, unless the
+// response sets its own TTL, and false if it is not cacheable.
+func (c *Client) cacheTTL(req *Request) (time.Duration, bool) {
+	if c.cache == nil || req.OperationType() != "query" || len(req.files) > 0 || req.stream != nil {
+		return 0, false
+	}
+	switch {
+	case req.cacheTTL < 0:
+		return 0, false
+	case req.cacheTTL > 0:
+		return req.cacheTTL, true
+	}
+	return c.cache.ttl, c.cache.ttl > 0 || c.cache.cacheControl
+}
+
+// runCached answers the query of req from the cache, or runs it and
+// caches the response for ttl if it succeeds.
+func (c *Client) runCached(ctx context.Context, req *Request, resp interface{}, opts []RunOption, ttl time.Duration) error {
+	settings, err := applyRunOptions(resp, opts)
+	if err != nil {
+		return err
+	}
+	if settings.result != nil {
+		return c.runUncached(ctx, req, resp, opts)
+	}
+	key, ok := c.requestKey(req)
+	if !ok {
+		return c.runUncached(ctx, req, resp, opts)
+	}
+	key = c.endpoint + "\n" + key
+	now := c.clock.Now()
+	entry := c.cacheGet(key)
+	if entry != nil && (now.Before(entry.Expires) || now.Before(entry.StaleUntil)) {
+		atomic.AddUint64(&c.cache.hits, 1)
+		if now.Before(entry.Expires) {
+			c.logf("<< cache hit")
+		} else {
+			atomic.AddUint64(&c.cache.staleHits, 1)
+			c.logf("<< stale cache hit")
+			c.revalidate(key, req, ttl, entry)
+		}
+		if err := c.decodeData(entry.Data, settings.data); err != nil {
+			return &DecodeError{Err: err}
+		}
+		return nil
+	}
+	notModified, err := c.fetchCached(ctx, key, req, resp, opts, ttl, entry)
+	if notModified {
+		atomic.AddUint64(&c.cache.hits, 1)
+	} else {
+		atomic.AddUint64(&c.cache.misses, 1)
+	}
+	return err
+}
+
+// CacheStats returns the number of hits and misses of the response cache
+// of the client, created with WithCache.
+func (c *Client) CacheStats() CacheStats {
+	if c.cache == nil {
+		return CacheStats{}
+	}
+	return CacheStats{
+		Hits:   atomic.LoadUint64(&c.cache.hits),
+		Misses: atomic.LoadUint64(&c.cache.misses),
+		Stale:  atomic.LoadUint64(&c.cache.staleHits),
+	}
+}
+
+// WithCache caches the data of successful query responses for ttl,
+// answering identical queries from the cache until it expires. Queries
+// are identical if they have the same document, operation, variables and
+// headers. Requests can set their own TTL with Request.CacheTTL.
+// Mutations, queries with files or streamed fields, and queries run with
+// RunResult are not cached.
+//
+// Responses are cached in memory, in an LRUCacheStore holding up to 1000
+// responses, unless the client has another store set with
+// WithCacheStore.
+func WithCache(ttl time.Duration) ClientOption {
+	return cacheOption(func(cache *responseCache) {
+		cache.ttl = ttl
+	})
+}
+
+// WithCacheStore caches responses in store, such as one backed by Redis
+// shared by several processes, or a DiskCacheStore keeping them across
+// restarts. For clients without WithCache, only the
+// responses to requests with Request.CacheTTL are cached.
+func WithCacheStore(store CacheStore) ClientOption {
+	return cacheOption(func(cache *responseCache) {
+		cache.store = store
+	})
+}
+
+// WithCacheControl takes the TTL of cached responses from the max-age of
+// their Cache-Control header, less their Age, in place of the TTL of the
+// client, so that the server decides how long its responses are fresh.
+// Responses with no-store are not cached, even for requests with their
+// own TTL set with Request.CacheTTL, and responses with no-cache are only
+// kept to be revalidated, for clients with WithConditionalRequests.
+func WithCacheControl() ClientOption {
+	return cacheOption(func(cache *responseCache) {
+		cache.cacheControl = true
+	})
+}
+
+// WithConditionalRequests keeps the ETag and Last-Modified headers of
+// cached responses, and revalidates expired responses with the
+// If-None-Match and If-Modified-Since headers, using the cached response
+// again, as a cache hit, when the server responds 304 Not Modified. This
+// suits queries sent with GET through CDNs supporting conditional
+// requests. Responses with validators are kept for a day after they
+// expire, to revalidate them.
+func WithConditionalRequests() ClientOption {
+	return cacheOption(func(cache *responseCache) {
+		cache.conditional = true
+	})
+}
+
+// WithStaleWhileRevalidate serves cached responses for up to stale after
+// they expire, answering queries straight away with the stale response
+// while it is refreshed in the background, for callers that prefer fast
+// responses to fresh ones. A stale response is refreshed once at a time,
+// and is served until the refreshed response replaces it or the stale
+// window ends.
+func WithStaleWhileRevalidate(stale time.Duration) ClientOption {
+	return cacheOption(func(cache *responseCache) {
+		cache.stale = stale
+	})
+}
@@ -0,0 +1,504 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestWithCache(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		io.WriteString(w, `{"data":{"item":"a"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	clock := newFakeClock()
+	client := NewClient(srv.URL, WithClock(clock), WithCache(time.Minute))
+	for i := 0; i < 3; i++ {
+		var resp struct{ Item string }
+		is.NoErr(client.Run(ctx, NewRequest("query { item }"), &resp))
+		is.Equal(resp.Item, "a")
+	}
+	is.Equal(calls, 1)
+	is.Equal(client.CacheStats(), CacheStats{Hits: 2, Misses: 1})
+
+	// Other variables are other queries
+	req := NewRequest("query { item }")
+	req.Var("id", 1)
+	is.NoErr(client.Run(ctx, req, nil))
+	is.Equal(calls, 2)
+
+	// Responses expire after the TTL
+	clock.Sleep(ctx, time.Minute)
+	is.NoErr(client.Run(ctx, NewRequest("query { item }"), nil))
+	is.Equal(calls, 3)
+
+	// Mutations are not cached
+	is.NoErr(client.Run(ctx, NewRequest("mutation { item }"), nil))
+	is.NoErr(client.Run(ctx, NewRequest("mutation { item }"), nil))
+	is.Equal(calls, 5)
+
+	// Nor are queries run with RunResult
+	_, err := client.RunResult(ctx, NewRequest("query { item }"), nil)
+	is.NoErr(err)
+	is.Equal(calls, 6)
+}
+
+func TestRequestCacheTTL(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		io.WriteString(w, `{"data":{"item":"a"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	clock := newFakeClock()
+	client := NewClient(srv.URL, WithClock(clock), WithCache(time.Minute))
+	short := func() *Request {
+		req := NewRequest("query { item }")
+		req.CacheTTL(time.Second)
+		return req
+	}
+	is.NoErr(client.Run(ctx, short(), nil))
+	is.NoErr(client.Run(ctx, short(), nil))
+	is.Equal(calls, 1)
+	clock.Sleep(ctx, time.Second)
+	is.NoErr(client.Run(ctx, short(), nil))
+	is.Equal(calls, 2)
+
+	uncached := NewRequest("query { other }")
+	uncached.CacheTTL(0)
+	is.NoErr(client.Run(ctx, uncached, nil))
+	is.NoErr(client.Run(ctx, uncached, nil))
+	is.Equal(calls, 4)
+}
+
+func TestWithCacheErrors(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		io.WriteString(w, `{"data":{"item":"a"},"errors":[{"message":"partial"}]}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithCache(time.Minute))
+	is.True(client.Run(ctx, NewRequest("query { item }"), nil) != nil)
+	is.True(client.Run(ctx, NewRequest("query { item }"), nil) != nil)
+	is.Equal(calls, 2) // responses with errors are not cached
+}
+
+func TestWithCacheTargets(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		io.WriteString(w, `{"data":{"user":{"name":"a"},"repos":[1,2]}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithCache(time.Minute))
+	for i := 0; i < 2; i++ {
+		var user struct{ Name string }
+		var repos []int
+		is.NoErr(client.Run(ctx, NewRequest("query { user { name } repos }"), nil, Into("user", &user), Into("repos", &repos)))
+		is.Equal(user.Name, "a")
+		is.Equal(repos, []int{1, 2})
+	}
+	is.Equal(calls, 1)
+}
+
+func TestLRUCacheStore(t *testing.T) {
+	is := is.New(t)
+	store := NewLRUCacheStore(2)
+	is.NoErr(store.Set("a", []byte("1"), time.Minute))
+	is.NoErr(store.Set("b", []byte("2"), time.Minute))
+	value, ok, err := store.Get("a")
+	is.NoErr(err)
+	is.True(ok)
+	is.Equal(string(value), "1")
+
+	// b is the least recently used value, so it is dropped
+	is.NoErr(store.Set("c", []byte("3"), time.Minute))
+	is.Equal(store.Len(), 2)
+	_, ok, _ = store.Get("b")
+	is.True(!ok)
+	_, ok, _ = store.Get("a")
+	is.True(ok)
+
+	is.NoErr(store.Delete("a"))
+	_, ok, _ = store.Get("a")
+	is.True(!ok)
+
+	// Expired values are dropped
+	is.NoErr(store.Set("d", []byte("4"), -time.Second))
+	_, ok, _ = store.Get("d")
+	is.True(!ok)
+	is.Equal(store.Len(), 1)
+}
+
+// failingCacheStore is a CacheStore that always fails.
+type failingCacheStore struct{}
+
+func (failingCacheStore) Get(key string) ([]byte, bool, error) {
+	return nil, false, errors.New("store down")
+}
+
+func (failingCacheStore) Set(key string, value []byte, ttl time.Duration) error {
+	return errors.New("store down")
+}
+
+func (failingCacheStore) Delete(key string) error {
+	return errors.New("store down")
+}
+
+func TestWithCacheClientHeaders(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		io.WriteString(w, `{"data":{"me":"`+strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")+`"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	base := NewClient(srv.URL, WithCache(time.Minute))
+	admin := base.With(WithHeader("Authorization", "Bearer admin"))
+	user := base.With(WithHeader("Authorization", "Bearer user"))
+	for _, client := range []*Client{admin, user, admin, user} {
+		var resp struct{ Me string }
+		is.NoErr(client.Run(ctx, NewRequest("query { me }"), &resp))
+		is.Equal(resp.Me, strings.TrimPrefix(client.header.Get("Authorization"), "Bearer "))
+	}
+	is.Equal(calls, 2)
+
+	// Headers of the request replace the default headers of the client
+	req := NewRequest("query { me }")
+	req.Header.Set("Authorization", "Bearer user")
+	var resp struct{ Me string }
+	is.NoErr(admin.Run(ctx, req, &resp))
+	is.Equal(resp.Me, "user")
+	is.Equal(calls, 2)
+}
+
+func TestWithCacheFieldDirectives(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var body struct{ Query string }
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		if strings.Contains(body.Query, "@include(if: false)") {
+			io.WriteString(w, `{"data":{"user":{"id":"1"}}}`)
+			return
+		}
+		io.WriteString(w, `{"data":{"user":{"id":"1","email":"ann@example.com"}}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithCache(time.Minute))
+	var resp struct{ User struct{ ID, Email string } }
+	is.NoErr(client.Run(ctx, NewRequest("query { user { id email } }"), &resp))
+	is.Equal(resp.User.Email, "ann@example.com")
+	req := NewRequest("query { user { id email } }")
+	req.FieldDirective("user.email", "@include(if: false)")
+	resp.User.Email = ""
+	is.NoErr(client.Run(ctx, req, &resp))
+	is.Equal(resp.User.Email, "")
+	is.Equal(calls, 2)
+}
+
+func TestWithCacheStore(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		io.WriteString(w, `{"data":{"item":"a"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	store := NewLRUCacheStore(10)
+	client := NewClient(srv.URL, WithCacheStore(store), WithCache(time.Minute))
+	is.NoErr(client.Run(ctx, NewRequest("query { item }"), nil))
+	is.NoErr(client.Run(ctx, NewRequest("query { item }"), nil))
+	is.Equal(calls, 1)
+	is.Equal(store.Len(), 1)
+
+	// A store without a TTL only caches requests with their own TTL
+	client = NewClient(srv.URL, WithCacheStore(NewLRUCacheStore(10)))
+	is.NoErr(client.Run(ctx, NewRequest("query { item }"), nil))
+	req := NewRequest("query { item }")
+	req.CacheTTL(time.Minute)
+	is.NoErr(client.Run(ctx, req, nil))
+	is.NoErr(client.Run(ctx, req, nil))
+	is.Equal(calls, 3)
+
+	// Failures of the store do not fail requests
+	client = NewClient(srv.URL, WithCacheStore(failingCacheStore{}), WithCache(time.Minute))
+	is.NoErr(client.Run(ctx, NewRequest("query { item }"), nil))
+	is.NoErr(client.Run(ctx, NewRequest("query { item }"), nil))
+	is.Equal(calls, 5)
+}
+
+func TestWithStaleWhileRevalidate(t *testing.T) {
+	is := is.New(t)
+	var calls int32
+	refreshed := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		fmt.Fprintf(w, `{"data":{"version":%d}}`, n)
+		if n > 1 {
+			refreshed <- struct{}{}
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	clock := newFakeClock()
+	client := NewClient(srv.URL, WithClock(clock), WithCache(time.Minute), WithStaleWhileRevalidate(time.Hour))
+	var resp struct{ Version int }
+	is.NoErr(client.Run(ctx, NewRequest("query { version }"), &resp))
+	is.Equal(resp.Version, 1)
+
+	// The stale response is served while it is refreshed
+	clock.Sleep(ctx, 2*time.Minute)
+	is.NoErr(client.Run(ctx, NewRequest("query { version }"), &resp))
+	is.Equal(resp.Version, 1)
+	select {
+	case <-refreshed:
+	case <-ctx.Done():
+		t.Fatal("response not refreshed")
+	}
+	// Wait for the refreshed response to be cached
+	for i := 0; i < 100; i++ {
+		is.NoErr(client.Run(ctx, NewRequest("query { version }"), &resp))
+		if resp.Version == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	is.Equal(resp.Version, 2)
+	is.Equal(atomic.LoadInt32(&calls), int32(2))
+	stats := client.CacheStats()
+	is.Equal(stats.Misses, uint64(1))
+	is.True(stats.Stale >= 1)
+
+	// Responses are not served after the stale window
+	clock.Sleep(ctx, 2*time.Hour)
+	go func() { <-refreshed }()
+	is.NoErr(client.Run(ctx, NewRequest("query { version }"), &resp))
+	is.Equal(resp.Version, 3)
+}
+
+func TestWithStaleWhileRevalidateTimeout(t *testing.T) {
+	is := is.New(t)
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		if atomic.AddInt32(&calls, 1) > 1 {
+			<-r.Context().Done() // refreshes hang
+			return
+		}
+		io.WriteString(w, `{"data":{"version":1}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	clock := newFakeClock()
+	client := NewClient(srv.URL, WithClock(clock), WithCache(time.Minute), WithStaleWhileRevalidate(time.Hour),
+		WithDefaultTimeout(20*time.Millisecond))
+	is.NoErr(client.Run(ctx, NewRequest("query { version }"), nil))
+	clock.Sleep(ctx, 2*time.Minute)
+
+	// Refreshes time out, so that the response is refreshed again
+	for atomic.LoadInt32(&calls) < 3 {
+		var resp struct{ Version int }
+		is.NoErr(client.Run(ctx, NewRequest("query { version }"), &resp))
+		is.Equal(resp.Version, 1)
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWithConditionalRequests(t *testing.T) {
+	is := is.New(t)
+	var calls, notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.Method, http.MethodGet)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			is.Equal(r.Header.Get("If-Modified-Since"), "Wed, 14 Oct 2026 10:00:00 GMT")
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Wed, 14 Oct 2026 10:00:00 GMT")
+		io.WriteString(w, `{"data":{"item":"a"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	clock := newFakeClock()
+	client := NewClient(srv.URL, UseGET(), WithClock(clock), WithCache(time.Minute), WithConditionalRequests())
+	var resp struct{ Item string }
+	is.NoErr(client.Run(ctx, NewRequest("query { item }"), &resp))
+	is.Equal(resp.Item, "a")
+
+	// The expired response is revalidated, and used again
+	for i := 0; i < 2; i++ {
+		clock.Sleep(ctx, 2*time.Minute)
+		resp.Item = ""
+		is.NoErr(client.Run(ctx, NewRequest("query { item }"), &resp))
+		is.Equal(resp.Item, "a")
+	}
+	is.Equal(calls, 3)
+	is.Equal(notModified, 2)
+	is.Equal(client.CacheStats(), CacheStats{Hits: 2, Misses: 1})
+
+	// The revalidated response is fresh again
+	is.NoErr(client.Run(ctx, NewRequest("query { item }"), &resp))
+	is.Equal(calls, 3)
+}
+
+func TestWithConditionalRequestsModified(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("ETag", fmt.Sprintf(`"v%d"`, calls))
+		fmt.Fprintf(w, `{"data":{"version":%d}}`, calls)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	clock := newFakeClock()
+	client := NewClient(srv.URL, WithClock(clock), WithCache(time.Minute), WithConditionalRequests())
+	var resp struct{ Version int }
+	is.NoErr(client.Run(ctx, NewRequest("query { version }"), &resp))
+	clock.Sleep(ctx, 2*time.Minute)
+	is.NoErr(client.Run(ctx, NewRequest("query { version }"), &resp))
+	is.Equal(resp.Version, 2)
+	is.Equal(client.CacheStats(), CacheStats{Misses: 2})
+}
+
+func TestCacheControlTTL(t *testing.T) {
+	is := is.New(t)
+	header := func(values ...string) http.Header {
+		h := make(http.Header)
+		for i := 0; i < len(values); i += 2 {
+			h.Add(values[i], values[i+1])
+		}
+		return h
+	}
+	ttl, ok, noStore := cacheControlTTL(header("Cache-Control", "public, max-age=60"))
+	is.Equal(ttl, time.Minute)
+	is.True(ok)
+	is.True(!noStore)
+	ttl, _, _ = cacheControlTTL(header("Cache-Control", "max-age=60", "Age", "15"))
+	is.Equal(ttl, 45*time.Second)
+	ttl, ok, _ = cacheControlTTL(header("Cache-Control", "max-age=60, no-cache"))
+	is.Equal(ttl, time.Duration(0))
+	is.True(ok)
+	_, ok, noStore = cacheControlTTL(header("Cache-Control", "no-store"))
+	is.True(!ok)
+	is.True(noStore)
+	_, ok, _ = cacheControlTTL(header())
+	is.True(!ok)
+	_, ok, _ = cacheControlTTL(header("Cache-Control", "max-age=soon"))
+	is.True(!ok)
+}
+
+func TestWithCacheControl(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var body struct{ Query string }
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		switch body.Query {
+		case "query { short }":
+			w.Header().Set("Cache-Control", "max-age=10")
+		case "query { secret }":
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		io.WriteString(w, `{"data":{"item":"a"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	clock := newFakeClock()
+	client := NewClient(srv.URL, WithClock(clock), WithCacheControl())
+
+	// Responses are cached for their max-age
+	is.NoErr(client.Run(ctx, NewRequest("query { short }"), nil))
+	is.NoErr(client.Run(ctx, NewRequest("query { short }"), nil))
+	is.Equal(calls, 1)
+	clock.Sleep(ctx, 10*time.Second)
+	is.NoErr(client.Run(ctx, NewRequest("query { short }"), nil))
+	is.Equal(calls, 2)
+
+	// Responses without the header are not cached without a TTL
+	is.NoErr(client.Run(ctx, NewRequest("query { other }"), nil))
+	is.NoErr(client.Run(ctx, NewRequest("query { other }"), nil))
+	is.Equal(calls, 4)
+
+	// Responses with no-store are not cached, even for requests with a TTL
+	secret := NewRequest("query { secret }")
+	secret.CacheTTL(time.Minute)
+	is.NoErr(client.Run(ctx, secret, nil))
+	is.NoErr(client.Run(ctx, secret, nil))
+	is.Equal(calls, 6)
+
+	// Requests with a TTL keep it over max-age
+	long := NewRequest("query { short }")
+	long.CacheTTL(time.Hour)
+	clock.Sleep(ctx, time.Minute)
+	is.NoErr(client.Run(ctx, long, nil))
+	clock.Sleep(ctx, time.Minute)
+	is.NoErr(client.Run(ctx, long, nil))
+	is.Equal(calls, 7)
+}
@@ -0,0 +1,77 @@
+package graphql
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// CanonicalKey returns a key identifying the document req sends, with the
+// directives added to it with Directive and FieldDirective, and its
+// operation name and variables, which is the hex-encoded SHA-256 hash of
+// their canonical serialization. Requests whose documents
+// differ only in comments, whitespace and commas, and whose variables
+// differ only in the order of the keys of objects, have the same key, as
+// do requests naming the only operation of their document and those
+// leaving it unnamed. Only the variables set on req are included, not the
+// default variables of a client.
+func CanonicalKey(req *Request) (string, error) {
+	return canonicalKey(req, req.vars)
+}
+
+// canonicalKey returns the canonical key of the operation of req with the
+// variables vars, and extra, such as the headers of the request, for keys
+// that tell apart more than the operation.
+func canonicalKey(req *Request, vars map[string]interface{}, extra ...string) (string, error) {
+	canonicalVars, err := canonicalJSON(vars)
+	if err != nil {
+		return "", fmt.Errorf("graphql: encode variables: %w", err)
+	}
+	// The directives are added to the document when it is sent, so
+	// requests differing only in their directives select other fields
+	var directives, fieldDirectives []string
+	for _, directive := range req.directives {
+		directives = append(directives, minify(directive))
+	}
+	for _, field := range req.fieldDirectives {
+		fieldDirectives = append(fieldDirectives, field.path+" "+minify(field.directive))
+	}
+	b, err := json.Marshal(struct {
+		Document        string          `json:"document"`
+		Directives      []string        `json:"directives,omitempty"`
+		FieldDirectives []string        `json:"fieldDirectives,omitempty"`
+		OperationName   string          `json:"operationName"`
+		Variables       json.RawMessage `json:"variables"`
+		Extra           []string        `json:"extra,omitempty"`
+	}{req.canonicalHash(), directives, fieldDirectives, requestOperationName(req), canonicalVars, extra})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalJSON returns the JSON encoding of vars with the keys of every
+// object sorted, and no insignificant whitespace. Empty variables are
+// encoded as null, as if there were none.
+func canonicalJSON(vars map[string]interface{}) (json.RawMessage, error) {
+	if len(vars) == 0 {
+		return json.RawMessage("null"), nil
+	}
+	b, err := json.Marshal(vars)
+	if err != nil {
+		return nil, err
+	}
+	// Values encoding themselves, such as json.RawMessage and structs,
+	// are decoded and encoded again to sort the keys of their objects.
+	// Numbers are kept as written.
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var value interface{}
+	if err := d.Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
@@ -0,0 +1,95 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestCanonicalKey(t *testing.T) {
+	is := is.New(t)
+	key := func(q string, vars map[string]interface{}) string {
+		req := NewRequest(q)
+		for name, value := range vars {
+			req.Var(name, value)
+		}
+		key, err := CanonicalKey(req)
+		is.NoErr(err)
+		return key
+	}
+	want := key(`query User($id: ID!) { user(id: $id) { id, name } }`, map[string]interface{}{
+		"id":     "1",
+		"filter": map[string]interface{}{"a": 1, "b": 2},
+	})
+	is.Equal(len(want), 64)
+	is.Equal(key("# the user\nquery User($id: ID!) {\n\tuser(id: $id) {\n\t\tid\n\t\tname\n\t}\n}\n", map[string]interface{}{
+		"filter": json.RawMessage(`{"b": 2, "a": 1}`),
+		"id":     "1",
+	}), want)
+
+	// Naming the only operation of the document
+	req := NewRequest(`query User($id: ID!) { user(id: $id) { id name } }`)
+	req.OperationName("User")
+	req.Var("id", "1")
+	req.Var("filter", map[string]interface{}{"b": 2, "a": 1})
+	got, err := CanonicalKey(req)
+	is.NoErr(err)
+	is.Equal(got, want)
+
+	is.True(key(`query User($id: ID!) { user(id: $id) { id name } }`, map[string]interface{}{"id": "2"}) != want)
+	is.True(key(`query User($id: ID!) { user(id: $id) { name id } }`, map[string]interface{}{"id": "1"}) != want)
+	is.True(key(`{ user(name: "a b") { id } }`, nil) != key(`{ user(name: "ab") { id } }`, nil))
+	is.Equal(key(`{ users { id } }`, nil), key(`{ users { id } }`, map[string]interface{}{}))
+
+	// Directives added to the document are part of the key
+	withDirective := func(path, directive string) string {
+		req := NewRequest(`{ user { id email } }`)
+		req.FieldDirective(path, directive)
+		key, err := CanonicalKey(req)
+		is.NoErr(err)
+		return key
+	}
+	is.True(withDirective("user.email", "@include(if: false)") != key(`{ user { id email } }`, nil))
+	is.True(withDirective("user.email", "@include(if: false)") != withDirective("user.id", "@include(if: false)"))
+	is.Equal(withDirective("user.email", "@include(if: false)"), withDirective("user.email", "@include( if: false )"))
+	req = NewRequest(`{ user { id } }`)
+	req.Directive("@cached(ttl: 60)")
+	got, err = CanonicalKey(req)
+	is.NoErr(err)
+	is.True(got != key(`{ user { id } }`, nil))
+
+	req = NewRequest(`{ users { id } }`)
+	req.Var("bad", func() {})
+	_, err = CanonicalKey(req)
+	is.True(err != nil)
+}
+
+func TestWithCacheCanonicalKeys(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		io.WriteString(w, `{"data":{"item":"a"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithCache(time.Minute))
+	req := NewRequest("query ($a: Int, $b: Int) { item(a: $a, b: $b) }")
+	req.Var("a", 1)
+	req.Var("b", 2)
+	is.NoErr(client.Run(ctx, req, nil))
+	req = NewRequest("query ($a: Int, $b: Int) {\n  item(a: $a, b: $b)\n}")
+	req.Var("b", 2)
+	req.Var("a", 1)
+	is.NoErr(client.Run(ctx, req, nil))
+	is.Equal(calls, 1)
+}
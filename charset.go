@@ -0,0 +1,93 @@
+package graphql
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// decodeCharset returns a reader of body transcoded to UTF-8 from charset,
+// with any byte order mark removed. A byte order mark takes precedence
+// over charset, as it does for browsers. ok is false if charset is not
+// supported.
+func decodeCharset(body *bufio.Reader, charset string) (r *bufio.Reader, ok bool) {
+	if bom, _ := body.Peek(3); len(bom) == 3 && bom[0] == 0xef && bom[1] == 0xbb && bom[2] == 0xbf {
+		body.Discard(3)
+		charset = "utf-8"
+	} else if bom, _ := body.Peek(2); len(bom) == 2 && bom[0] == 0xfe && bom[1] == 0xff {
+		body.Discard(2)
+		charset = "utf-16be"
+	} else if len(bom) == 2 && bom[0] == 0xff && bom[1] == 0xfe {
+		body.Discard(2)
+		charset = "utf-16le"
+	}
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return body, true
+	case "utf-16be", "utf-16":
+		// UTF-16 without a byte order mark is big endian
+		return bufio.NewReader(&utf16Reader{r: body, order: binary.BigEndian}), true
+	case "utf-16le":
+		return bufio.NewReader(&utf16Reader{r: body, order: binary.LittleEndian}), true
+	case "iso-8859-1", "latin1", "l1":
+		return bufio.NewReader(&latin1Reader{r: body}), true
+	}
+	return nil, false
+}
+
+// utf16Reader transcodes UTF-16 read from r to UTF-8.
+type utf16Reader struct {
+	r     io.Reader
+	order binary.ByteOrder
+	buf   []byte
+	err   error
+}
+
+func (r *utf16Reader) Read(p []byte) (int, error) {
+	for len(r.buf) < len(p) && r.err == nil {
+		var unit [2]byte
+		if _, r.err = io.ReadFull(r.r, unit[:]); r.err != nil {
+			break
+		}
+		c := rune(r.order.Uint16(unit[:]))
+		if utf16.IsSurrogate(c) {
+			var low [2]byte
+			if _, r.err = io.ReadFull(r.r, low[:]); r.err != nil {
+				break
+			}
+			c = utf16.DecodeRune(c, rune(r.order.Uint16(low[:])))
+		}
+		r.buf = utf8.AppendRune(r.buf, c)
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	if n > 0 {
+		return n, nil
+	}
+	return 0, r.err
+}
+
+// latin1Reader transcodes ISO-8859-1 read from r to UTF-8.
+type latin1Reader struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (r *latin1Reader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		in := make([]byte, len(p)/2+1)
+		n, err := r.r.Read(in)
+		for _, b := range in[:n] {
+			r.buf = utf8.AppendRune(r.buf, rune(b))
+		}
+		if n == 0 {
+			return 0, err
+		}
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,84 @@
+package graphql
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/matryer/is"
+)
+
+func TestDecodeCharset(t *testing.T) {
+	is := is.New(t)
+	body := `{"data":{"name":"Zoë 🎉"}}`
+	var le []byte
+	for _, unit := range utf16.Encode([]rune(body)) {
+		le = append(le, byte(unit), byte(unit>>8))
+	}
+	tests := []struct {
+		contentType string
+		body        []byte
+	}{
+		{"application/json", append([]byte("\xef\xbb\xbf"), body...)},
+		{"", append([]byte("\xef\xbb\xbf"), body...)},
+		{"application/json; charset=utf-16", append([]byte("\xff\xfe"), le...)},
+		{"application/json; charset=UTF-16LE", le},
+		{"text/plain; charset=utf-8", append([]byte("\xef\xbb\xbf"), body...)},
+	}
+	for _, test := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header()["Content-Type"] = []string{test.contentType}
+			w.Write(test.body)
+		}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		var resp struct {
+			Name string
+		}
+		err := NewClient(srv.URL).Run(ctx, NewRequest("query {}"), &resp)
+		is.NoErr(err)                // test.contentType
+		is.Equal(resp.Name, "Zoë 🎉") // test.contentType
+		cancel()
+		srv.Close()
+	}
+}
+
+func TestDecodeCharsetLatin1(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=ISO-8859-1")
+		w.Write([]byte("{\"data\":{\"name\":\"Zo\xeb\"}}"))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var resp struct {
+		Name string
+	}
+	err := NewClient(srv.URL).Run(ctx, NewRequest("query {}"), &resp)
+	is.NoErr(err)
+	is.Equal(resp.Name, "Zoë")
+}
+
+func TestDecodeCharsetUnsupported(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=shift_jis")
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	err := NewClient(srv.URL).Run(ctx, NewRequest("query {}"), nil)
+	ctErr, ok := err.(*ContentTypeError)
+	is.True(ok)
+	is.Equal(ctErr.ContentType, "application/json; charset=shift_jis")
+}
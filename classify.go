@@ -0,0 +1,105 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// retriableCodes are the error codes of GraphQL errors that are expected
+// to succeed if the request is retried.
+var retriableCodes = map[string]bool{
+	"THROTTLED":           true,
+	"RATE_LIMITED":        true,
+	"SERVICE_UNAVAILABLE": true,
+}
+
+// IsRetriable reports whether err is a transient failure that may succeed
+// if the request is sent again: network failures, responses with the
+// status codes 408, 429, 502, 503 or 504, and GraphQL errors that all
+// have a code such as THROTTLED. Other errors, such as validation and
+// authentication errors, are permanent, as are the context being done and
+// a RewindError.
+func IsRetriable(err error) bool {
+	return isRetriable(err, retriableCodes)
+}
+
+// isRetriable is IsRetriable, taking GraphQL errors with the given codes
+// to be retriable.
+func isRetriable(err error, codes map[string]bool) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var rewindErr *RewindError
+	if errors.As(err, &rewindErr) {
+		return false
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var gqlErrs Errors
+	if errors.As(err, &gqlErrs) {
+		for _, gqlErr := range gqlErrs {
+			if !codes[gqlErr.Code()] {
+				return false
+			}
+		}
+		return len(gqlErrs) > 0
+	}
+	var transportErr *TransportError
+	return errors.As(err, &transportErr)
+}
+
+// IsRetriable reports whether err, returned by Run, is a transient failure
+// that may succeed if the request is sent again, according to the
+// classifier set with WithRetryClassifier, which defaults to the
+// IsRetriable function, or with the GraphQL error codes set with
+// WithRetriableCodes.
+func (c *Client) IsRetriable(err error) bool {
+	if c.classifier != nil {
+		return c.classifier(err)
+	}
+	if c.retriableCodes != nil {
+		return isRetriable(err, c.retriableCodes)
+	}
+	return IsRetriable(err)
+}
+
+// WithRetriableCodes sets the codes, in the extensions of GraphQL errors,
+// of the errors the client classifies as retriable, in place of
+// THROTTLED, RATE_LIMITED and SERVICE_UNAVAILABLE. Responses whose errors
+// all have one of the codes are retried, even with a 200 status code, so
+// the codes should only be those of errors that are safe to retry:
+//
+//	NewClient(endpoint, WithRetriableCodes("THROTTLED", "UPSTREAM_TIMEOUT"))
+func WithRetriableCodes(codes ...string) ClientOption {
+	return func(client *Client) {
+		client.retriableCodes = make(map[string]bool, len(codes))
+		for _, code := range codes {
+			client.retriableCodes[code] = true
+		}
+	}
+}
+
+// WithRetryClassifier sets the function deciding whether errors are
+// transient and the request may be retried. Classifiers can refine the
+// IsRetriable function:
+//
+//	NewClient(endpoint, WithRetryClassifier(func(err error) bool {
+//		var httpErr *graphql.HTTPError
+//		if errors.As(err, &httpErr) && httpErr.StatusCode == 500 {
+//			return true
+//		}
+//		return graphql.IsRetriable(err)
+//	}))
+func WithRetryClassifier(classifier func(err error) bool) ClientOption {
+	return func(client *Client) {
+		client.classifier = classifier
+	}
+}
@@ -0,0 +1,58 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestIsRetriable(t *testing.T) {
+	is := is.New(t)
+	is.True(IsRetriable(&TransportError{Err: errors.New("connection reset by peer")}))
+	is.True(IsRetriable(&HTTPError{StatusCode: http.StatusTooManyRequests}))
+	is.True(IsRetriable(&HTTPError{StatusCode: http.StatusServiceUnavailable}))
+	is.True(IsRetriable(Errors{{Message: "slow down", Extensions: map[string]interface{}{"code": "THROTTLED"}}}))
+	is.True(IsRetriable(fmt.Errorf("wrapped: %w", &HTTPError{StatusCode: http.StatusBadGateway})))
+
+	is.True(!IsRetriable(nil))
+	is.True(!IsRetriable(&HTTPError{StatusCode: http.StatusUnauthorized}))
+	is.True(!IsRetriable(&HTTPError{StatusCode: http.StatusBadRequest}))
+	is.True(!IsRetriable(Errors{{Message: "bad", Extensions: map[string]interface{}{"code": "GRAPHQL_VALIDATION_FAILED"}}}))
+	is.True(!IsRetriable(Errors{
+		{Message: "slow down", Extensions: map[string]interface{}{"code": "THROTTLED"}},
+		{Message: "who are you", Extensions: map[string]interface{}{"code": "UNAUTHENTICATED"}},
+	}))
+	is.True(!IsRetriable(&DecodeError{Err: errors.New("bad json")}))
+	is.True(!IsRetriable(&TransportError{Err: context.Canceled}))
+}
+
+func TestRetryClassifier(t *testing.T) {
+	is := is.New(t)
+	client := NewClient("")
+	is.True(!client.IsRetriable(&HTTPError{StatusCode: http.StatusInternalServerError}))
+
+	client = NewClient("", WithRetryClassifier(func(err error) bool {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusInternalServerError {
+			return true
+		}
+		return IsRetriable(err)
+	}))
+	is.True(client.IsRetriable(&HTTPError{StatusCode: http.StatusInternalServerError}))
+	is.True(client.IsRetriable(&HTTPError{StatusCode: http.StatusServiceUnavailable}))
+}
+
+func TestWithRetriableCodes(t *testing.T) {
+	is := is.New(t)
+	client := NewClient("", WithRetriableCodes("UPSTREAM_TIMEOUT"))
+	timeout := Errors{{Message: "timed out", Extensions: map[string]interface{}{"code": "UPSTREAM_TIMEOUT"}}}
+	throttled := Errors{{Message: "slow down", Extensions: map[string]interface{}{"code": "THROTTLED"}}}
+	is.True(client.IsRetriable(timeout))
+	is.True(!client.IsRetriable(throttled)) // the codes replace the defaults
+	is.True(client.IsRetriable(&HTTPError{StatusCode: http.StatusServiceUnavailable}))
+	is.True(!IsRetriable(timeout))
+}
@@ -0,0 +1,42 @@
+package graphql
+
+import (
+	"context"
+	"time"
+)
+
+// Clock tells the time and waits for the retries, rate limits and
+// failover cooldowns of a client. Tests can set a Clock with WithClock
+// whose time advances when it sleeps, so that they run without waiting.
+// The deadlines of contexts are always checked against the real time.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// Sleep waits for d, returning false if ctx is done first.
+	Sleep(ctx context.Context, d time.Duration) bool
+}
+
+// realClock is the Clock of the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// WithClock sets the Clock the client uses in place of the time package.
+func WithClock(clock Clock) ClientOption {
+	return func(client *Client) {
+		client.clock = clock
+	}
+}
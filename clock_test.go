@@ -0,0 +1,102 @@
+package graphql
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+// fakeClock is a Clock whose time only advances when it sleeps.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	slept time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.slept += d
+	return ctx.Err() == nil
+}
+
+func TestWithClock(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		calls++
+		if calls < 4 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	// Retries wait with the clock, without sleeping. The context has no
+	// deadline, since the context is done by the real time while the time
+	// left before its deadline is measured with the clock.
+	ctx := context.Background()
+	clock := newFakeClock()
+	client := NewClient(srv.URL, WithClock(clock), WithRetry(4, 10*time.Second), WithBackoff(ExponentialBackoff(10*time.Second, time.Minute)))
+	start := time.Now()
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	is.True(time.Since(start) < 500*time.Millisecond)
+	is.Equal(calls, 4)
+	is.True(clock.slept >= 35*time.Second) // 10s, 20s and 40s with jitter
+
+	// So does the rate limiter
+	clock = newFakeClock()
+	client = NewClient(srv.URL, WithClock(clock), WithRateLimit(1, 1))
+	for i := 0; i < 3; i++ {
+		is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	}
+	is.Equal(clock.slept, 2*time.Second)
+}
+
+func TestWithClockFailoverCooldown(t *testing.T) {
+	is := is.New(t)
+	var primaryCalls int
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		primaryCalls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer backup.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	clock := newFakeClock()
+	client := NewClient(primary.URL, WithClock(clock), WithFailover(backup.URL), WithFailoverCooldown(time.Minute))
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	is.Equal(primaryCalls, 1) // the primary cools down
+
+	clock.Sleep(ctx, time.Minute)
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	is.Equal(primaryCalls, 2) // and is tried again once it has passed
+}
@@ -0,0 +1,221 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit is the state of the rate limit of an API that charges requests
+// a cost in points from a budget, as reported with a response.
+type RateLimit struct {
+	// Limit is the most points the budget holds.
+	Limit float64
+	// Remaining is the number of points left in the budget.
+	Remaining float64
+	// Cost is the number of points the request cost.
+	Cost float64
+	// RequestedCost is the number of points the request could have cost,
+	// which the server checks against the budget before running it.
+	RequestedCost float64
+	// RestoreRate is the number of points restored to the budget each
+	// second, for budgets that recover continuously.
+	RestoreRate float64
+	// Reset is when the budget is next restored to its limit, for budgets
+	// that reset at intervals.
+	Reset time.Time
+	// Source is where the rate limit was found: "extensions" for the cost
+	// extension Shopify sends, "data" for the rateLimit field GitHub
+	// returns when the query selects it, or "headers" for the
+	// X-RateLimit headers.
+	Source string
+}
+
+// OnRateLimit sets a function called with the rate limit reported with
+// the response, if any, so that the cost of queries and the budget left
+// can be tracked. Rate limits are read from the cost extension of
+// Shopify, the rateLimit field of the data of GitHub, when the query
+// selects it, and the X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset headers. It is called before Run returns.
+func (req *Request) OnRateLimit(fn func(limit RateLimit)) {
+	req.onRateLimit = fn
+}
+
+// resetDeltaMax is the largest X-RateLimit-Reset header taken to be a
+// number of seconds rather than a Unix time.
+const resetDeltaMax = 1e9
+
+// responseRateLimit returns the rate limit reported in the headers or
+// extensions of a response, or in the rateLimit field of its data, which
+// is nil unless it was decoded.
+func responseRateLimit(header http.Header, extensions map[string]interface{}, data *RateLimit, now time.Time) (RateLimit, bool) {
+	if cost, ok := extensions["cost"].(map[string]interface{}); ok {
+		limit := RateLimit{Source: "extensions"}
+		limit.Cost, _ = cost["actualQueryCost"].(float64)
+		limit.RequestedCost, _ = cost["requestedQueryCost"].(float64)
+		if status, ok := cost["throttleStatus"].(map[string]interface{}); ok {
+			limit.Limit, _ = status["maximumAvailable"].(float64)
+			limit.Remaining, _ = status["currentlyAvailable"].(float64)
+			limit.RestoreRate, _ = status["restoreRate"].(float64)
+			return limit, true
+		}
+	}
+	if data != nil {
+		return *data, true
+	}
+	remaining, err := strconv.ParseFloat(header.Get("X-RateLimit-Remaining"), 64)
+	if err != nil {
+		return RateLimit{}, false
+	}
+	limit := RateLimit{Remaining: remaining, Source: "headers"}
+	limit.Limit, _ = strconv.ParseFloat(header.Get("X-RateLimit-Limit"), 64)
+	if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		if reset < resetDeltaMax {
+			// Some servers send the seconds until the reset rather
+			// than its Unix time
+			limit.Reset = now.Add(time.Duration(reset) * time.Second)
+		} else {
+			limit.Reset = time.Unix(reset, 0)
+		}
+	}
+	return limit, true
+}
+
+// dataRateLimit returns the rateLimit field of data, as GitHub returns it
+// when the query selects rateLimit { cost limit remaining resetAt }.
+func dataRateLimit(data json.RawMessage) *RateLimit {
+	var fields struct {
+		RateLimit *struct {
+			Cost      float64
+			Limit     float64
+			Remaining *float64
+			ResetAt   time.Time
+		}
+	}
+	if err := json.Unmarshal(data, &fields); err != nil || fields.RateLimit == nil || fields.RateLimit.Remaining == nil {
+		return nil
+	}
+	return &RateLimit{
+		Limit:     fields.RateLimit.Limit,
+		Remaining: *fields.RateLimit.Remaining,
+		Cost:      fields.RateLimit.Cost,
+		Reset:     fields.RateLimit.ResetAt,
+		Source:    "data",
+	}
+}
+
+// costTracker keeps the last rate limit reported to a client.
+type costTracker struct {
+	mu    sync.Mutex
+	limit RateLimit
+	// at is when the limit was reported, or zero if none has been
+	at time.Time
+}
+
+// observe records a rate limit reported with a response at now.
+func (t *costTracker) observe(limit RateLimit, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.limit, t.at = limit, now
+}
+
+// last returns the last rate limit reported.
+func (t *costTracker) last() (RateLimit, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.limit, !t.at.IsZero()
+}
+
+// available returns the points expected to be in the budget at now.
+func (t *costTracker) available(now time.Time) float64 {
+	limit := t.limit
+	switch {
+	case limit.RestoreRate > 0:
+		available := limit.Remaining + now.Sub(t.at).Seconds()*limit.RestoreRate
+		if limit.Limit > 0 && available > limit.Limit {
+			available = limit.Limit
+		}
+		return available
+	case !limit.Reset.IsZero() && !now.Before(limit.Reset) && limit.Limit > 0:
+		return limit.Limit
+	}
+	return limit.Remaining
+}
+
+// wait waits until the budget is expected to hold the points the last
+// request needed, taking them from it, unless reject is set, in which case
+// ErrRateLimited is returned if it does not. ErrRateLimited is also
+// returned if ctx would be done first, and the error of ctx if it is done
+// while waiting.
+func (t *costTracker) wait(ctx context.Context, clock Clock, reject bool) error {
+	t.mu.Lock()
+	if t.at.IsZero() {
+		t.mu.Unlock()
+		return nil
+	}
+	needed := t.limit.RequestedCost
+	if needed <= 0 {
+		needed = t.limit.Cost
+	}
+	if needed <= 0 {
+		needed = 1
+	}
+	now := clock.Now()
+	available := t.available(now)
+	var delay time.Duration
+	if available < needed {
+		switch {
+		case t.limit.RestoreRate > 0:
+			delay = time.Duration((needed - available) / t.limit.RestoreRate * float64(time.Second))
+			now, available = now.Add(delay), needed
+		case !t.limit.Reset.IsZero() && t.limit.Limit > 0:
+			delay = t.limit.Reset.Sub(now)
+			now, available = t.limit.Reset, t.limit.Limit
+		}
+	}
+	if delay > 0 && reject {
+		t.mu.Unlock()
+		return ErrRateLimited
+	}
+	// Take the points now, as of when the request will be sent, so that
+	// requests sent before the next response is reported are counted
+	if !t.limit.Reset.IsZero() && !now.Before(t.limit.Reset) {
+		t.limit.Reset = time.Time{}
+	}
+	t.limit.Remaining, t.at = available-needed, now
+	t.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	if !sleep(ctx, clock, delay) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// RateLimit returns the last rate limit reported to the client by the
+// server, as passed to the OnRateLimit function of requests. ok is false
+// if none has been.
+func (c *Client) RateLimit() (limit RateLimit, ok bool) {
+	return c.costs.last()
+}
+
+// WithCostThrottling waits before sending requests until the budget of
+// the rate limit last reported by the server is expected to hold the
+// points the last request needed, so that the client slows down as the
+// budget runs out rather than having requests fail with throttling
+// errors. The budget is expected to recover at the restore rate of the
+// rate limit, or at its reset. Requests that would wait past the deadline
+// of their context, or at all with RejectOverLimit, fail with
+// ErrRateLimited.
+func WithCostThrottling() ClientOption {
+	return func(client *Client) {
+		client.costThrottling = true
+	}
+}
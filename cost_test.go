@@ -0,0 +1,126 @@
+package graphql
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestOnRateLimitShopify(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		io.WriteString(w, `{"data":{"shop":{"name":"a"}},"extensions":{"cost":{"requestedQueryCost":101,"actualQueryCost":46,"throttleStatus":{"maximumAvailable":1000.0,"currentlyAvailable":954,"restoreRate":50.0}}}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	_, ok := client.RateLimit()
+	is.True(!ok)
+	var got RateLimit
+	req := NewRequest("query { shop { name } }")
+	req.OnRateLimit(func(limit RateLimit) {
+		got = limit
+	})
+	is.NoErr(client.Run(ctx, req, nil))
+	is.Equal(got, RateLimit{Limit: 1000, Remaining: 954, Cost: 46, RequestedCost: 101, RestoreRate: 50, Source: "extensions"})
+	last, ok := client.RateLimit()
+	is.True(ok)
+	is.Equal(last, got)
+}
+
+func TestOnRateLimitGitHub(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.Header().Set("X-RateLimit-Reset", "1700000000")
+		if !strings.Contains(string(b), "rateLimit") {
+			io.WriteString(w, `{"data":{"viewer":{"login":"a"}}}`)
+			return
+		}
+		io.WriteString(w, `{"data":{"viewer":{"login":"a"},"rateLimit":{"cost":1,"limit":5000,"remaining":4998,"resetAt":"2023-11-14T22:13:20Z"}}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var got RateLimit
+	req := NewRequest("query { viewer { login } rateLimit { cost limit remaining resetAt } }")
+	req.OnRateLimit(func(limit RateLimit) {
+		got = limit
+	})
+	var resp struct {
+		Viewer struct{ Login string }
+	}
+	is.NoErr(NewClient(srv.URL).Run(ctx, req, &resp))
+	is.Equal(resp.Viewer.Login, "a")
+	is.Equal(got.Source, "data")
+	is.Equal(got.Cost, float64(1))
+	is.Equal(got.Remaining, float64(4998))
+	is.True(got.Reset.Equal(time.Unix(1700000000, 0)))
+
+	// Without the rateLimit field, the headers are used
+	req = NewRequest("query { viewer { login } }")
+	req.OnRateLimit(func(limit RateLimit) {
+		got = limit
+	})
+	is.NoErr(NewClient(srv.URL).Run(ctx, req, nil))
+	is.Equal(got, RateLimit{Limit: 5000, Remaining: 4999, Reset: time.Unix(1700000000, 0), Source: "headers"})
+}
+
+func TestWithCostThrottling(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		calls++
+		// The budget holds less than the query needs, and is restored
+		// at 1000 points a second
+		io.WriteString(w, `{"data":{},"extensions":{"cost":{"requestedQueryCost":100,"actualQueryCost":100,"throttleStatus":{"maximumAvailable":1000,"currentlyAvailable":50,"restoreRate":1000}}}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithCostThrottling())
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	start := time.Now()
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	is.True(time.Since(start) >= 40*time.Millisecond) // waited for 50 points
+	is.Equal(calls, 2)
+
+	err := client.With(RejectOverLimit()).Run(ctx, NewRequest("query {}"), nil)
+	is.Equal(err, ErrRateLimited)
+	is.Equal(calls, 2)
+}
+
+func TestCostTrackerReset(t *testing.T) {
+	is := is.New(t)
+	clock := newFakeClock()
+	costs := &costTracker{}
+	costs.observe(RateLimit{Limit: 10, Remaining: 0, Reset: clock.Now().Add(time.Minute)}, clock.Now())
+	is.NoErr(costs.wait(context.Background(), clock, false))
+	is.Equal(clock.slept, time.Minute) // waited for the reset
+	is.Equal(costs.limit.Remaining, float64(9))
+	is.True(costs.limit.Reset.IsZero())
+
+	// The time left before the deadline is measured with the clock
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	clock = &fakeClock{now: time.Now()}
+	costs.observe(RateLimit{Limit: 10, Remaining: 0, Reset: clock.Now().Add(time.Hour)}, clock.Now())
+	is.Equal(costs.wait(ctx, clock, false), ErrRateLimited)
+}
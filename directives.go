@@ -0,0 +1,190 @@
+package graphql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// fieldDirective is a directive added to the field at a path of the
+// operation.
+type fieldDirective struct {
+	path      string
+	directive string
+}
+
+// Directive adds a directive to the operation of the request when it is
+// sent, such as a caching directive, without editing the query:
+//
+//	req.Directive("@cached(ttl: 60)")
+func (req *Request) Directive(directive string) {
+	req.directives = append(req.directives, directiveText(directive))
+}
+
+// FieldDirective adds a directive to the field of the operation at path
+// when the request is sent. The path is made of the response names of the
+// fields, which are their aliases if they have one, separated by dots,
+// such as user.posts. Fields within fragment spreads cannot be given
+// directives.
+//
+//	req.FieldDirective("user.email", "@include(if: $withEmail)")
+func (req *Request) FieldDirective(path, directive string) {
+	req.fieldDirectives = append(req.fieldDirectives, fieldDirective{path: path, directive: directiveText(directive)})
+}
+
+// directiveText returns directive starting with @.
+func directiveText(directive string) string {
+	directive = strings.TrimSpace(directive)
+	if !strings.HasPrefix(directive, "@") {
+		directive = "@" + directive
+	}
+	return directive
+}
+
+// insertion is text inserted into a document at an offset.
+type insertion struct {
+	offset int
+	text   string
+}
+
+// addDirectives returns q with the directives added to the operation with
+// the given name, and the field directives added to its fields.
+func addDirectives(q, operation string, directives []string, fields []fieldDirective) (string, error) {
+	op, _, err := operationDefinition(q, operation)
+	if err != nil {
+		return "", err
+	}
+	selection := selectionSetStart(q, op.start)
+	if selection >= op.end {
+		return "", fmt.Errorf("graphql: cannot add directives: operation %q has no selection set", operation)
+	}
+	var insertions []insertion
+	if len(directives) > 0 {
+		text := strings.Join(directives, " ") + " "
+		if op.keyword == "" {
+			text = "query " + text
+		} else if q[selection-1] != ' ' {
+			text = " " + text
+		}
+		insertions = append(insertions, insertion{offset: selection, text: text})
+	}
+	pending := make(map[string][]string)
+	for _, field := range fields {
+		pending[field.path] = append(pending[field.path], field.directive)
+	}
+	insertions = fieldDirectiveInsertions(q, selection, "", pending, insertions)
+	if len(pending) > 0 {
+		paths := make([]string, 0, len(pending))
+		for path := range pending {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		return "", fmt.Errorf("graphql: cannot add directives: operation has no field %s", strings.Join(paths, ", "))
+	}
+	sort.SliceStable(insertions, func(i, j int) bool {
+		return insertions[i].offset < insertions[j].offset
+	})
+	var b strings.Builder
+	last := 0
+	for _, ins := range insertions {
+		b.WriteString(q[last:ins.offset])
+		b.WriteString(ins.text)
+		last = ins.offset
+	}
+	b.WriteString(q[last:])
+	return b.String(), nil
+}
+
+// selectionSetStart returns the index of the selection set of the
+// definition starting at i, skipping any braces within arguments.
+func selectionSetStart(q string, i int) int {
+	var parens int
+	for i < len(q) {
+		switch q[i] {
+		case '#':
+			i = skipIgnored(q, i)
+			continue
+		case '"':
+			i = skipString(q, i)
+			continue
+		case '(':
+			parens++
+		case ')':
+			parens--
+		case '{':
+			if parens == 0 {
+				return i
+			}
+		}
+		i++
+	}
+	return i
+}
+
+// fieldDirectiveInsertions walks the selection set starting at i, whose
+// fields have paths starting with prefix, adding the insertions of the
+// pending directives of the fields and removing them from pending.
+func fieldDirectiveInsertions(q string, i int, prefix string, pending map[string][]string, insertions []insertion) []insertion {
+	for i = skipIgnored(q, i+1); i < len(q) && q[i] != '}'; i = skipIgnored(q, i) {
+		if strings.HasPrefix(q[i:], "...") {
+			i = skipIgnored(q, i+3)
+			name := q[i:skipName(q, i)]
+			if name != "" && name != "on" {
+				// A fragment spread
+				i = skipDirectives(q, skipName(q, i))
+				continue
+			}
+			if name == "on" {
+				i = skipIgnored(q, skipName(q, i))
+				i = skipName(q, i)
+			}
+			i = skipIgnored(q, skipDirectives(q, i))
+			if i < len(q) && q[i] == '{' {
+				// Fields of inline fragments have the path of the
+				// enclosing field
+				insertions = fieldDirectiveInsertions(q, i, prefix, pending, insertions)
+				i = skipDefinition(q, i)
+			}
+			continue
+		}
+		start := i
+		i = skipName(q, i)
+		if i == start {
+			i++ // skip unexpected punctuation
+			continue
+		}
+		key := q[start:i]
+		if j := skipIgnored(q, i); j < len(q) && q[j] == ':' {
+			i = skipName(q, skipIgnored(q, j+1))
+		}
+		if j := skipIgnored(q, i); j < len(q) && q[j] == '(' {
+			i = skipValue(q, j)
+		}
+		i = skipDirectives(q, i)
+		path := prefix + key
+		if directives, ok := pending[path]; ok {
+			insertions = append(insertions, insertion{offset: i, text: " " + strings.Join(directives, " ")})
+			delete(pending, path)
+		}
+		if j := skipIgnored(q, i); j < len(q) && q[j] == '{' {
+			insertions = fieldDirectiveInsertions(q, j, path+".", pending, insertions)
+			i = skipDefinition(q, j)
+		}
+	}
+	return insertions
+}
+
+// skipDirectives returns the index just past the directives following i,
+// which is i if there are none.
+func skipDirectives(q string, i int) int {
+	for {
+		j := skipIgnored(q, i)
+		if j >= len(q) || q[j] != '@' {
+			return i
+		}
+		i = skipName(q, j+1)
+		if j = skipIgnored(q, i); j < len(q) && q[j] == '(' {
+			i = skipValue(q, j)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestAddDirectives(t *testing.T) {
+	is := is.New(t)
+	q, err := addDirectives(`query Q($id: ID!) @live {
+	u: user(id: $id, filter: {a: "{"}) @auth {
+		email
+		posts { title }
+		... on Admin { level }
+		...F
+	}
+}
+fragment F on User { name }`, "Q", []string{"@cached(ttl: 60)"}, []fieldDirective{
+		{path: "u.email", directive: "@include(if: $withEmail)"},
+		{path: "u", directive: "@a"},
+		{path: "u.posts.title", directive: "@b"},
+		{path: "u.level", directive: "@c"},
+	})
+	is.NoErr(err)
+	is.Equal(q, `query Q($id: ID!) @live @cached(ttl: 60) {
+	u: user(id: $id, filter: {a: "{"}) @auth @a {
+		email @include(if: $withEmail)
+		posts { title @b }
+		... on Admin { level @c }
+		...F
+	}
+}
+fragment F on User { name }`)
+
+	q, err = addDirectives(`{ a }`, "", []string{"@cached"}, nil)
+	is.NoErr(err)
+	is.Equal(q, `query @cached { a }`)
+
+	q, err = addDirectives(`query A { a } mutation B{ b }`, "B", []string{"@x"}, nil)
+	is.NoErr(err)
+	is.Equal(q, `query A { a } mutation B @x { b }`)
+
+	_, err = addDirectives(`query { a }`, "", nil, []fieldDirective{{path: "b", directive: "@x"}})
+	is.Equal(err.Error(), "graphql: cannot add directives: operation has no field b")
+}
+
+func TestRequestDirective(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query string
+		}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		is.Equal(body.Query, `query @cached(ttl: 60) { user { name email @skip(if: true) } }`)
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	req := NewRequest(`query { user { name email } }`)
+	req.Directive("cached(ttl: 60)")
+	req.FieldDirective("user.email", "@skip(if: true)")
+	is.NoErr(NewClient(srv.URL).Run(ctx, req, nil))
+	is.Equal(req.Query(), `query { user { name email } }`)
+}
@@ -0,0 +1,266 @@
+package graphql
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// diskEntryMagic starts the files of a DiskCacheStore, and changes with
+// their format.
+const diskEntryMagic = "gqlcache1"
+
+// diskEntryExt and diskTempPrefix are the extension of the files of a
+// DiskCacheStore, and the prefix of the files being written.
+const (
+	diskEntryExt   = ".entry"
+	diskTempPrefix = "tmp-"
+)
+
+// DiskCacheStore is a CacheStore holding values in files in a directory,
+// so that the cache of a client survives restarts of the program, such as
+// a CLI tool. It drops the least recently used values once their files
+// exceed its size limit. Files that cannot be read back, such as ones
+// truncated by a crash, are removed and treated as missing, and values
+// are written to temporary files renamed into place, so a crash cannot
+// leave a partly written value behind.
+//
+// A directory must be used by one DiskCacheStore at a time.
+type DiskCacheStore struct {
+	dir      string
+	maxBytes int64
+
+	mu sync.Mutex
+	// order holds the entries from the most to the least recently used
+	order   *list.List
+	entries map[string]*list.Element
+	size    int64
+}
+
+// diskEntry is a file of a DiskCacheStore.
+type diskEntry struct {
+	name string
+	size int64
+}
+
+// NewDiskCacheStore opens the DiskCacheStore in dir, creating the
+// directory if needed, holding up to maxBytes of files. The files left in
+// dir by a previous store are kept, apart from those that are corrupt or
+// were being written, and the least recently used are removed if they
+// exceed maxBytes.
+func NewDiskCacheStore(dir string, maxBytes int64) (*DiskCacheStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("graphql: open disk cache: %w", err)
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("graphql: open disk cache: %w", err)
+	}
+	s := &DiskCacheStore{dir: dir, maxBytes: maxBytes, order: list.New(), entries: make(map[string]*list.Element)}
+	type found struct {
+		entry *diskEntry
+		used  time.Time
+	}
+	var entries []found
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(name, diskTempPrefix) {
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		if !strings.HasSuffix(name, diskEntryExt) {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, found{entry: &diskEntry{name: name, size: info.Size()}, used: info.ModTime()})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].used.After(entries[j].used)
+	})
+	for _, found := range entries {
+		s.entries[found.entry.name] = s.order.PushBack(found.entry)
+		s.size += found.entry.size
+	}
+	s.trim()
+	return s, nil
+}
+
+// diskEntryName returns the name of the file holding the value of key.
+func diskEntryName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:]) + diskEntryExt
+}
+
+// encodeDiskEntry returns the contents of the file holding value for key
+// until expires: the magic string, the expiry in Unix nanoseconds, the
+// lengths of the key and value, the CRC-32 checksum of both, then the key
+// and value.
+func encodeDiskEntry(key string, value []byte, expires time.Time) []byte {
+	b := make([]byte, 0, len(diskEntryMagic)+20+len(key)+len(value))
+	b = append(b, diskEntryMagic...)
+	b = binary.BigEndian.AppendUint64(b, uint64(expires.UnixNano()))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(key)))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(value)))
+	sum := crc32.NewIEEE()
+	sum.Write([]byte(key))
+	sum.Write(value)
+	b = binary.BigEndian.AppendUint32(b, sum.Sum32())
+	b = append(b, key...)
+	return append(b, value...)
+}
+
+// errCorruptDiskEntry is returned by decodeDiskEntry for files that are
+// not valid entries.
+var errCorruptDiskEntry = errors.New("graphql: corrupt disk cache entry")
+
+// decodeDiskEntry returns the key, value and expiry of the file b.
+func decodeDiskEntry(b []byte) (key string, value []byte, expires time.Time, err error) {
+	header := len(diskEntryMagic) + 20
+	if len(b) < header || string(b[:len(diskEntryMagic)]) != diskEntryMagic {
+		return "", nil, time.Time{}, errCorruptDiskEntry
+	}
+	b = b[len(diskEntryMagic):]
+	expires = time.Unix(0, int64(binary.BigEndian.Uint64(b)))
+	keyLen, valueLen := binary.BigEndian.Uint32(b[8:]), binary.BigEndian.Uint32(b[12:])
+	sum := binary.BigEndian.Uint32(b[16:])
+	b = b[20:]
+	if uint64(len(b)) != uint64(keyLen)+uint64(valueLen) || crc32.ChecksumIEEE(b) != sum {
+		return "", nil, time.Time{}, errCorruptDiskEntry
+	}
+	return string(b[:keyLen]), b[keyLen:], expires, nil
+}
+
+// Get returns the value stored for key, unless it has expired. Corrupt
+// files are removed and reported as missing.
+func (s *DiskCacheStore) Get(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := diskEntryName(key)
+	elem, ok := s.entries[name]
+	if !ok {
+		return nil, false, nil
+	}
+	path := filepath.Join(s.dir, name)
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.drop(elem)
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("graphql: read disk cache: %w", err)
+	}
+	storedKey, value, expires, err := decodeDiskEntry(b)
+	if err != nil || storedKey != key || !time.Now().Before(expires) {
+		return nil, false, s.remove(elem)
+	}
+	s.order.MoveToFront(elem)
+	now := time.Now()
+	os.Chtimes(path, now, now) // keeps the order across restarts
+	return value, true, nil
+}
+
+// Set stores value for key for ttl, dropping the least recently used
+// values if the store is over its size limit. Values larger than the
+// limit are not stored.
+func (s *DiskCacheStore) Set(key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := diskEntryName(key)
+	b := encodeDiskEntry(key, value, time.Now().Add(ttl))
+	if int64(len(b)) > s.maxBytes {
+		if elem, ok := s.entries[name]; ok {
+			return s.remove(elem)
+		}
+		return nil
+	}
+	f, err := os.CreateTemp(s.dir, diskTempPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("graphql: write disk cache: %w", err)
+	}
+	_, err = f.Write(b)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(s.dir, name))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("graphql: write disk cache: %w", err)
+	}
+	if elem, ok := s.entries[name]; ok {
+		s.drop(elem)
+	}
+	entry := &diskEntry{name: name, size: int64(len(b))}
+	s.entries[name] = s.order.PushFront(entry)
+	s.size += entry.size
+	s.trim()
+	return nil
+}
+
+// Delete removes the value stored for key.
+func (s *DiskCacheStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.entries[diskEntryName(key)]; ok {
+		return s.remove(elem)
+	}
+	return nil
+}
+
+// Len returns the number of values stored, including any that have
+// expired but not yet been dropped.
+func (s *DiskCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// Size returns the size in bytes of the files of the store.
+func (s *DiskCacheStore) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// trim removes the least recently used files until the store is within
+// its size limit.
+func (s *DiskCacheStore) trim() {
+	for s.size > s.maxBytes && s.order.Len() > 0 {
+		s.remove(s.order.Back())
+	}
+}
+
+// remove removes the file of elem.
+func (s *DiskCacheStore) remove(elem *list.Element) error {
+	s.drop(elem)
+	err := os.Remove(filepath.Join(s.dir, elem.Value.(*diskEntry).name))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("graphql: write disk cache: %w", err)
+	}
+	return nil
+}
+
+// drop forgets elem, without removing its file.
+func (s *DiskCacheStore) drop(elem *list.Element) {
+	entry := s.order.Remove(elem).(*diskEntry)
+	delete(s.entries, entry.name)
+	s.size -= entry.size
+}
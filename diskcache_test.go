@@ -0,0 +1,140 @@
+package graphql
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestDiskCacheStore(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	s, err := NewDiskCacheStore(dir, 1<<20)
+	is.NoErr(err)
+	is.NoErr(s.Set("a", []byte("1"), time.Minute))
+	is.NoErr(s.Set("b", []byte("2"), time.Minute))
+	value, ok, err := s.Get("a")
+	is.NoErr(err)
+	is.True(ok)
+	is.Equal(string(value), "1")
+	_, ok, _ = s.Get("c")
+	is.True(!ok)
+
+	// Values expire after their TTL
+	is.NoErr(s.Set("c", []byte("3"), -time.Second))
+	_, ok, _ = s.Get("c")
+	is.True(!ok)
+
+	is.NoErr(s.Delete("b"))
+	_, ok, _ = s.Get("b")
+	is.True(!ok)
+	is.Equal(s.Len(), 1)
+
+	// Values survive reopening the store
+	s, err = NewDiskCacheStore(dir, 1<<20)
+	is.NoErr(err)
+	value, ok, err = s.Get("a")
+	is.NoErr(err)
+	is.True(ok)
+	is.Equal(string(value), "1")
+}
+
+func TestDiskCacheStoreSizeLimit(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	size := int64(len(encodeDiskEntry("a", []byte("1"), time.Now())))
+	s, err := NewDiskCacheStore(dir, 2*size)
+	is.NoErr(err)
+	is.NoErr(s.Set("a", []byte("1"), time.Minute))
+	is.NoErr(s.Set("b", []byte("2"), time.Minute))
+	_, _, _ = s.Get("a")
+	is.NoErr(s.Set("c", []byte("3"), time.Minute))
+	is.Equal(s.Len(), 2)
+	is.Equal(s.Size(), 2*size)
+	_, ok, _ := s.Get("b")
+	is.True(!ok) // the least recently used
+
+	// Values over the limit are not stored
+	is.NoErr(s.Set("a", make([]byte, 2*size), time.Minute))
+	_, ok, _ = s.Get("a")
+	is.True(!ok)
+
+	// Reopening with a lower limit drops files
+	s, err = NewDiskCacheStore(dir, size)
+	is.NoErr(err)
+	is.Equal(s.Len(), 1)
+	files, err := os.ReadDir(dir)
+	is.NoErr(err)
+	is.Equal(len(files), 1)
+}
+
+func TestDiskCacheStoreCorruption(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	s, err := NewDiskCacheStore(dir, 1<<20)
+	is.NoErr(err)
+	is.NoErr(s.Set("a", []byte("value"), time.Minute))
+	is.NoErr(s.Set("b", []byte("value"), time.Minute))
+
+	// A truncated file, and one with a flipped bit
+	path := filepath.Join(dir, diskEntryName("a"))
+	b, err := os.ReadFile(path)
+	is.NoErr(err)
+	is.NoErr(os.WriteFile(path, b[:len(b)-2], 0o600))
+	path = filepath.Join(dir, diskEntryName("b"))
+	b, err = os.ReadFile(path)
+	is.NoErr(err)
+	b[len(b)-1] ^= 1
+	is.NoErr(os.WriteFile(path, b, 0o600))
+	is.NoErr(os.WriteFile(filepath.Join(dir, diskTempPrefix+"1"), []byte("partial"), 0o600))
+
+	s, err = NewDiskCacheStore(dir, 1<<20)
+	is.NoErr(err)
+	for _, key := range []string{"a", "b"} {
+		_, ok, err := s.Get(key)
+		is.NoErr(err)
+		is.True(!ok)
+	}
+	is.Equal(s.Len(), 0)
+	files, err := os.ReadDir(dir)
+	is.NoErr(err)
+	is.Equal(len(files), 0)
+
+	// The store keeps working
+	is.NoErr(s.Set("a", []byte("value"), time.Minute))
+	value, ok, err := s.Get("a")
+	is.NoErr(err)
+	is.True(ok)
+	is.Equal(string(value), "value")
+}
+
+func TestWithCacheStoreDisk(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		io.WriteString(w, `{"data":{"item":"a"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	for i := 0; i < 2; i++ {
+		store, err := NewDiskCacheStore(dir, 1<<20)
+		is.NoErr(err)
+		client := NewClient(srv.URL, WithCache(time.Minute), WithCacheStore(store))
+		var resp struct{ Item string }
+		is.NoErr(client.Run(ctx, NewRequest("query { item }"), &resp))
+		is.Equal(resp.Item, "a")
+	}
+	is.Equal(calls, 1)
+}
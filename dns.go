@@ -0,0 +1,105 @@
+package graphql
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// dnsCache caches the addresses that host names resolve to.
+type dnsCache struct {
+	resolver *net.Resolver
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// lookup returns the addresses of host, resolving it only if there are no
+// cached addresses or they have expired.
+func (d *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	d.mu.Lock()
+	entry, ok := d.entries[host]
+	d.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+	addrs, err := d.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	d.entries[host] = dnsEntry{addrs: addrs, expires: time.Now().Add(d.ttl)}
+	d.mu.Unlock()
+	return addrs, nil
+}
+
+// forget removes host from the cache, so that it is resolved again.
+func (d *dnsCache) forget(host string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.entries, host)
+}
+
+// dialer returns a DialContext function that connects to the cached
+// addresses of the host using dial, trying each address in turn.
+func (d *dnsCache) dialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+		addrs, err := d.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range addrs {
+			var conn net.Conn
+			if conn, err = dial(ctx, network, net.JoinHostPort(ip, port)); err == nil {
+				return conn, nil
+			}
+		}
+		// The addresses may be out of date
+		d.forget(host)
+		return nil, err
+	}
+}
+
+// WithDNSCache caches the addresses endpoint host names resolve to for
+// ttl, rather than resolving them for every new connection, which
+// reduces the load on DNS servers from clients making many requests.
+// The system resolver is used unless WithDNSResolver is given.
+func WithDNSCache(ttl time.Duration) ClientOption {
+	return func(client *Client) {
+		client.dnsTTL = ttl
+		client.transportOptions = append(client.transportOptions, func(transport *http.Transport) {
+			cache := &dnsCache{
+				resolver: client.dnsResolver,
+				ttl:      client.dnsTTL,
+				entries:  make(map[string]dnsEntry),
+			}
+			if cache.resolver == nil {
+				cache.resolver = net.DefaultResolver
+			}
+			dial := transport.DialContext
+			if dial == nil {
+				dial = (&net.Dialer{}).DialContext
+			}
+			transport.DialContext = cache.dialer(dial)
+		})
+	}
+}
+
+// WithDNSResolver sets the resolver used by WithDNSCache.
+func WithDNSResolver(resolver *net.Resolver) ClientOption {
+	return func(client *Client) {
+		client.dnsResolver = resolver
+	}
+}
@@ -0,0 +1,55 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestDNSCache(t *testing.T) {
+	is := is.New(t)
+	var lookups int
+	cache := &dnsCache{
+		resolver: &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				lookups++
+				return nil, errors.New("no dns in tests")
+			},
+		},
+		ttl:     time.Minute,
+		entries: map[string]dnsEntry{"cached.example": {addrs: []string{"127.0.0.1"}, expires: time.Now().Add(time.Minute)}},
+	}
+	var dialed []string
+	dial := cache.dialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return nil, errors.New("refused")
+	})
+
+	// cached hosts are not resolved
+	_, err := dial(context.Background(), "tcp", "cached.example:443")
+	is.True(err != nil)
+	is.Equal(lookups, 0)
+	is.Equal(dialed, []string{"127.0.0.1:443"})
+
+	// and are forgotten when every address fails
+	_, ok := cache.entries["cached.example"]
+	is.True(!ok)
+
+	// IP addresses are dialed directly
+	dialed = nil
+	dial(context.Background(), "tcp", "10.0.0.1:80")
+	is.Equal(lookups, 0)
+	is.Equal(dialed, []string{"10.0.0.1:80"})
+}
+
+func TestWithDNSCache(t *testing.T) {
+	is := is.New(t)
+	client := NewClient("https://machinebox.io/graphql", WithDNSCache(time.Minute))
+	is.NoErr(client.transportErr)
+	is.True(client.httpClient.Transport != nil)
+}
@@ -0,0 +1,24 @@
+package graphql
+
+import "context"
+
+// Do executes the query like Run, returning the data of the response
+// decoded into a new value of type T:
+//
+//	type userResponse struct {
+//		User struct {
+//			Name string
+//		}
+//	}
+//	resp, err := graphql.Do[userResponse](ctx, client, req)
+//
+// If Run returns an error, Do returns it with the zero value of T,
+// discarding any partial data. Use RunPartial to handle partial results.
+func Do[T any](ctx context.Context, client *Client, req *Request, opts ...RunOption) (T, error) {
+	var resp T
+	if err := client.Run(ctx, req, &resp, opts...); err != nil {
+		var zero T
+		return zero, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,47 @@
+package graphql
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestDo(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"user":{"name":"Mat"}}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	type userResponse struct {
+		User struct {
+			Name string
+		}
+	}
+	resp, err := Do[userResponse](ctx, NewClient(srv.URL), NewRequest("query {}"))
+	is.NoErr(err)
+	is.Equal(resp.User.Name, "Mat")
+}
+
+func TestDoPartial(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"user":{"name":"Mat"}},"errors":[{"message":"no avatar"}]}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	resp, err := Do[map[string]interface{}](ctx, NewClient(srv.URL), NewRequest("query {}"))
+	is.Equal(err.Error(), "graphql: no avatar")
+	is.True(resp == nil) // partial data is discarded
+}
@@ -0,0 +1,232 @@
+package graphql
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Document is a parsed GraphQL document holding several named operations
+// and the fragments they share, such as a .graphql file, from which the
+// request for each operation is created.
+type Document struct {
+	src        string
+	operations []definition
+	fragments  map[string]definition
+}
+
+// definition is an operation or fragment definition in a document.
+type definition struct {
+	// keyword is the type of operation, fragment, or empty for the
+	// shorthand { ... } syntax
+	keyword    string
+	name       string
+	start, end int
+	// spreads are the names of the fragments spread in the definition
+	spreads []string
+}
+
+// ParseDocument parses a document holding one or more operations, and
+// any fragments they use. Every operation must be named if there are
+// several.
+func ParseDocument(src string) (*Document, error) {
+	defs, err := parseDefinitions(src)
+	if err != nil {
+		return nil, err
+	}
+	doc := &Document{src: src, fragments: make(map[string]definition)}
+	seen := make(map[string]bool)
+	for _, def := range defs {
+		if def.keyword == "fragment" {
+			if _, ok := doc.fragments[def.name]; ok || def.name == "" {
+				return nil, fmt.Errorf("graphql: duplicate fragment %q in document", def.name)
+			}
+			doc.fragments[def.name] = def
+			continue
+		}
+		if seen[def.name] {
+			return nil, fmt.Errorf("graphql: duplicate operation %q in document", def.name)
+		}
+		seen[def.name] = true
+		doc.operations = append(doc.operations, def)
+	}
+	if len(doc.operations) == 0 {
+		return nil, errors.New("graphql: document has no operations")
+	}
+	if len(doc.operations) > 1 && seen[""] {
+		return nil, errors.New("graphql: every operation must be named in a document with several operations")
+	}
+	return doc, nil
+}
+
+// ParseFS parses the .graphql and .gql files in fsys, such as an
+// embed.FS, as one document, so that operations can use the fragments of
+// any file. Files are read in lexical order of their paths.
+func ParseFS(fsys fs.FS) (*Document, error) {
+	var sources []string
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if ext := path.Ext(name); ext != ".graphql" && ext != ".gql" {
+			return nil
+		}
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		src := string(b)
+		if _, err := parseDefinitions(src); err != nil {
+			return fmt.Errorf("graphql: %s: %s", name, strings.TrimPrefix(err.Error(), "graphql: "))
+		}
+		sources = append(sources, src)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ParseDocument(strings.Join(sources, "\n"))
+}
+
+// MustParseFS is like ParseFS but panics if the files cannot be parsed.
+// It simplifies loading the operations of a program from files embedded
+// in it:
+//
+//	//go:embed queries
+//	var queries embed.FS
+//
+//	var operations = graphql.MustParseFS(queries)
+func MustParseFS(fsys fs.FS) *Document {
+	doc, err := ParseFS(fsys)
+	if err != nil {
+		panic(err)
+	}
+	return doc
+}
+
+// operationDefinition returns the definition of the operation with the
+// given name in q, or of the first operation if name is empty, and all the
+// definitions of q.
+func operationDefinition(q, name string) (*definition, []definition, error) {
+	defs, err := parseDefinitions(q)
+	if err != nil {
+		return nil, nil, err
+	}
+	typ, offset := findOperation(q, name)
+	for i := range defs {
+		if typ != "" && defs[i].keyword != "fragment" && (defs[i].start == offset || offset < 0 && defs[i].keyword == "") {
+			return &defs[i], defs, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("graphql: document has no operation %q", name)
+}
+
+// parseDefinitions splits the document src into its definitions.
+func parseDefinitions(src string) ([]definition, error) {
+	var defs []definition
+	for i := 0; i < len(src); {
+		i = skipIgnored(src, i)
+		if i >= len(src) {
+			break
+		}
+		start := i
+		var keyword, name string
+		if src[i] != '{' {
+			i = skipName(src, i)
+			keyword = src[start:i]
+			nameStart := skipIgnored(src, i)
+			name = src[nameStart:skipName(src, nameStart)]
+		}
+		switch keyword {
+		case "", "query", "mutation", "subscription", "fragment":
+		default:
+			return nil, fmt.Errorf("graphql: unexpected %q in document", keyword)
+		}
+		i = skipDefinition(src, i)
+		defs = append(defs, definition{
+			keyword: keyword,
+			name:    name,
+			start:   start,
+			end:     i,
+			spreads: fragmentSpreads(src[start:i]),
+		})
+	}
+	return defs, nil
+}
+
+// Operations returns the names of the operations in the document, in the
+// order they are defined.
+func (d *Document) Operations() []string {
+	names := make([]string, len(d.operations))
+	for i, op := range d.operations {
+		names[i] = op.name
+	}
+	return names
+}
+
+// Request returns a new request executing the operation with the given
+// name, which may be empty if the document only has one operation. The
+// query of the request holds the operation and the fragments it uses,
+// directly or through other fragments, and its operation name is set.
+func (d *Document) Request(operation string) (*Request, error) {
+	var op *definition
+	for i := range d.operations {
+		if d.operations[i].name == operation || operation == "" && len(d.operations) == 1 {
+			op = &d.operations[i]
+			break
+		}
+	}
+	if op == nil {
+		return nil, fmt.Errorf("graphql: document has no operation %q", operation)
+	}
+	parts := []string{d.src[op.start:op.end]}
+	used := make(map[string]bool)
+	queue := append([]string(nil), op.spreads...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if used[name] {
+			continue
+		}
+		used[name] = true
+		fragment, ok := d.fragments[name]
+		if !ok {
+			return nil, fmt.Errorf("graphql: operation %q uses undefined fragment %q", op.name, name)
+		}
+		parts = append(parts, d.src[fragment.start:fragment.end])
+		queue = append(queue, fragment.spreads...)
+	}
+	req := NewRequest(strings.Join(parts, "\n\n"))
+	req.OperationName(op.name)
+	return req, nil
+}
+
+// fragmentSpreads returns the names of the fragments spread in q, as
+// ...Name, in the order they first appear. Inline fragments are skipped.
+func fragmentSpreads(q string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for i := 0; i < len(q); {
+		switch {
+		case q[i] == '#':
+			i = skipIgnored(q, i)
+		case q[i] == '"':
+			i = skipString(q, i)
+		case strings.HasPrefix(q[i:], "..."):
+			start := skipIgnored(q, i+3)
+			i = skipName(q, start)
+			if name := q[start:i]; name != "" && name != "on" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		default:
+			i++
+		}
+	}
+	return names
+}
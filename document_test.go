@@ -0,0 +1,129 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+const testDocument = `
+# Users
+query GetUser($id: ID!) {
+	user(id: $id) { ...UserFields }
+}
+
+mutation SaveUser($id: ID!) {
+	save(id: $id) { ... on User { id } ...UserFields }
+}
+
+fragment UserFields on User { id name avatar { ...AvatarFields } }
+
+fragment AvatarFields on Avatar { url(size: "big ...NotAFragment") }
+
+fragment Unused on User { id }
+`
+
+func TestDocument(t *testing.T) {
+	is := is.New(t)
+	doc, err := ParseDocument(testDocument)
+	is.NoErr(err)
+	is.Equal(doc.Operations(), []string{"GetUser", "SaveUser"})
+
+	req, err := doc.Request("SaveUser")
+	is.NoErr(err)
+	is.Equal(req.Query(), `mutation SaveUser($id: ID!) {
+	save(id: $id) { ... on User { id } ...UserFields }
+}
+
+fragment UserFields on User { id name avatar { ...AvatarFields } }
+
+fragment AvatarFields on Avatar { url(size: "big ...NotAFragment") }`)
+	is.Equal(req.operationName, "SaveUser")
+	is.Equal(req.OperationType(), "mutation")
+
+	_, err = doc.Request("DeleteUser")
+	is.Equal(err.Error(), `graphql: document has no operation "DeleteUser"`)
+	_, err = doc.Request("")
+	is.True(err != nil) // the operation must be named
+}
+
+func TestDocumentErrors(t *testing.T) {
+	is := is.New(t)
+	_, err := ParseDocument(`fragment F on T { id }`)
+	is.Equal(err.Error(), "graphql: document has no operations")
+	_, err = ParseDocument(`query A { a } query A { b }`)
+	is.Equal(err.Error(), `graphql: duplicate operation "A" in document`)
+	_, err = ParseDocument(`query A { a } { b }`)
+	is.Equal(err.Error(), "graphql: every operation must be named in a document with several operations")
+	_, err = ParseDocument(`query A { a } type T { b }`)
+	is.Equal(err.Error(), `graphql: unexpected "type" in document`)
+
+	doc, err := ParseDocument(`{ a { ...Missing } }`)
+	is.NoErr(err)
+	_, err = doc.Request("")
+	is.Equal(err.Error(), `graphql: operation "" uses undefined fragment "Missing"`)
+}
+
+func TestDocumentRun(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query         string
+			OperationName string
+		}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		is.Equal(body.OperationName, "GetUser")
+		io.WriteString(w, `{"data":{"user":{"name":"Mat"}}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	doc, err := ParseDocument(testDocument)
+	is.NoErr(err)
+	req, err := doc.Request("GetUser")
+	is.NoErr(err)
+	req.Var("id", "1")
+	var resp struct {
+		User struct {
+			Name string
+		}
+	}
+	is.NoErr(NewClient(srv.URL).Run(ctx, req, &resp))
+	is.Equal(resp.User.Name, "Mat")
+}
+
+func TestParseFS(t *testing.T) {
+	is := is.New(t)
+	fsys := fstest.MapFS{
+		"queries/user.graphql":         {Data: []byte("query GetUser($id: ID!) { user(id: $id) { ...UserFields } }")},
+		"queries/save.gql":             {Data: []byte("mutation SaveUser { save { id } }")},
+		"fragments/user.graphql":       {Data: []byte("fragment UserFields on User { id name }")},
+		"queries/README.md":            {Data: []byte("not a document")},
+		"queries/nested/items.graphql": {Data: []byte("query Items { items }")},
+	}
+	doc, err := ParseFS(fsys)
+	is.NoErr(err)
+	is.Equal(doc.Operations(), []string{"Items", "SaveUser", "GetUser"})
+
+	req, err := doc.Request("GetUser")
+	is.NoErr(err)
+	is.Equal(req.Query(), "query GetUser($id: ID!) { user(id: $id) { ...UserFields } }\n\nfragment UserFields on User { id name }")
+
+	fsys["queries/bad.graphql"] = &fstest.MapFile{Data: []byte("subscribe { a }")}
+	_, err = ParseFS(fsys)
+	is.Equal(err.Error(), `graphql: queries/bad.graphql: unexpected "subscribe" in document`)
+
+	defer func() {
+		is.True(recover() != nil)
+	}()
+	MustParseFS(fsys)
+}
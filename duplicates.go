@@ -0,0 +1,112 @@
+package graphql
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DuplicateAction is what a client does with duplicate mutations detected
+// with WithDuplicateSuppression.
+type DuplicateAction int
+
+const (
+	// WarnDuplicates sends duplicate mutations, logging them and passing
+	// a warning to the OnWarning function of the request.
+	WarnDuplicates DuplicateAction = iota
+	// BlockDuplicates fails duplicate mutations with a
+	// DuplicateMutationError without sending them.
+	BlockDuplicates
+)
+
+// DuplicateMutationError is returned when a mutation is blocked for
+// duplicating one run within the window set with WithDuplicateSuppression.
+type DuplicateMutationError struct {
+	// Operation is the name of the operation, if it has one.
+	Operation string
+	// Since is how long ago the mutation it duplicates was run.
+	Since time.Duration
+}
+
+func (e *DuplicateMutationError) Error() string {
+	name := "mutation"
+	if e.Operation != "" {
+		name += " " + e.Operation
+	}
+	return fmt.Sprintf("graphql: duplicate %s, already run %v ago", name, e.Since)
+}
+
+// duplicateTracker remembers the mutations run within the window.
+type duplicateTracker struct {
+	window time.Duration
+	action DuplicateAction
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// check records the mutation req is about to run at now, returning the
+// error to fail it with if it duplicates one, and a function forgetting
+// it, for mutations that fail.
+func (t *duplicateTracker) check(c *Client, req *Request, now time.Time) (forget func(), err error) {
+	key, ok := duplicateKey(c, req)
+	if !ok {
+		return func() {}, nil
+	}
+	t.mu.Lock()
+	for k, at := range t.seen {
+		if now.Sub(at) >= t.window {
+			delete(t.seen, k)
+		}
+	}
+	at, duplicate := t.seen[key]
+	if !duplicate || t.action != BlockDuplicates {
+		t.seen[key] = now
+	}
+	t.mu.Unlock()
+	if duplicate {
+		dupErr := &DuplicateMutationError{Operation: req.operationName, Since: now.Sub(at)}
+		if t.action == BlockDuplicates {
+			return nil, dupErr
+		}
+		c.logf("!! %v", dupErr)
+		if req.onWarning != nil {
+			req.onWarning(Warning{Message: dupErr.Error(), Source: "duplicates"})
+		}
+	}
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.seen[key].Equal(now) {
+			delete(t.seen, key)
+		}
+	}, nil
+}
+
+// duplicateKey returns the key identifying the mutation of req, which is
+// its CanonicalKey with the variables of the client, and its idempotency
+// key. ok is false for
+// requests with files, which are not compared.
+func duplicateKey(c *Client, req *Request) (key string, ok bool) {
+	if len(req.files) > 0 {
+		return "", false
+	}
+	vars, err := c.encodeVars(c.requestVars(req))
+	if err != nil {
+		return "", false
+	}
+	key, err = canonicalKey(req, vars, req.idempotencyKey)
+	return key, err == nil
+}
+
+// WithDuplicateSuppression detects a mutation being run again, with the
+// same variables, within window of the first, as a double click or a
+// retry further up the stack might do, and warns of it or blocks it
+// according to action. Mutations that fail are forgotten, so that they
+// can be run again, and mutations given different idempotency keys with
+// Request.IdempotencyKey are not duplicates.
+func WithDuplicateSuppression(window time.Duration, action DuplicateAction) ClientOption {
+	return func(client *Client) {
+		client.duplicates = &duplicateTracker{window: window, action: action, seen: make(map[string]time.Time)}
+	}
+}
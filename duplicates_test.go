@@ -0,0 +1,98 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestWithDuplicateSuppression(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	fail := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		calls++
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		io.WriteString(w, `{"data":{"pay":true}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	clock := newFakeClock()
+	client := NewClient(srv.URL, WithClock(clock), WithDuplicateSuppression(time.Minute, BlockDuplicates))
+	pay := func(amount int) *Request {
+		req := NewRequest("mutation Pay($amount: Int!) { pay(amount: $amount) }")
+		req.OperationName("Pay")
+		req.Var("amount", amount)
+		return req
+	}
+	is.NoErr(client.Run(ctx, pay(10), nil))
+	clock.Sleep(ctx, time.Second)
+	err := client.Run(ctx, pay(10), nil)
+	var dupErr *DuplicateMutationError
+	is.True(errors.As(err, &dupErr))
+	is.Equal(dupErr.Operation, "Pay")
+	is.Equal(dupErr.Since, time.Second)
+	is.Equal(err.Error(), "graphql: duplicate mutation Pay, already run 1s ago")
+	is.Equal(calls, 1)
+
+	// Other variables, other idempotency keys and queries are not duplicates
+	is.NoErr(client.Run(ctx, pay(20), nil))
+	req := pay(10)
+	req.IdempotencyKey("second-payment")
+	is.NoErr(client.Run(ctx, req, nil))
+	is.NoErr(client.Run(ctx, NewRequest("query { balance }"), nil))
+	is.NoErr(client.Run(ctx, NewRequest("query { balance }"), nil))
+	is.Equal(calls, 5)
+
+	// Nor are mutations after the window
+	clock.Sleep(ctx, time.Minute)
+	is.NoErr(client.Run(ctx, pay(10), nil))
+	is.Equal(calls, 6)
+
+	// Failed mutations can be run again
+	fail = true
+	is.True(client.Run(ctx, pay(30), nil) != nil)
+	fail = false
+	is.NoErr(client.Run(ctx, pay(30), nil))
+	is.Equal(calls, 8)
+}
+
+func TestWithDuplicateSuppressionWarn(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		calls++
+		io.WriteString(w, `{"data":{"save":true}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithDuplicateSuppression(time.Minute, WarnDuplicates))
+	var warnings []Warning
+	for i := 0; i < 2; i++ {
+		req := NewRequest("mutation { save }")
+		req.OnWarning(func(warning Warning) {
+			warnings = append(warnings, warning)
+		})
+		is.NoErr(client.Run(ctx, req, nil))
+	}
+	is.Equal(calls, 2)
+	is.Equal(len(warnings), 1)
+	is.Equal(warnings[0].Source, "duplicates")
+}
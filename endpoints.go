@@ -0,0 +1,300 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LoadBalancing is a policy for distributing requests across the replicas
+// given to WithEndpoints.
+type LoadBalancing int
+
+const (
+	// RoundRobin sends requests to each replica in turn.
+	RoundRobin LoadBalancing = iota
+	// LeastPending sends requests to the replica with the fewest
+	// requests in flight.
+	LeastPending
+)
+
+// EndpointState is the health of an endpoint of a client.
+type EndpointState int
+
+const (
+	// EndpointUp is the state of an endpoint that is answering requests.
+	EndpointUp EndpointState = iota
+	// EndpointDown is the state of an endpoint that was unreachable or
+	// responded with a 5xx status code, which requests fail over from.
+	EndpointDown
+)
+
+func (s EndpointState) String() string {
+	if s == EndpointDown {
+		return "down"
+	}
+	return "up"
+}
+
+// StateChange is a change in the health of an endpoint of a client,
+// passed to the function set with OnStateChange.
+type StateChange struct {
+	// Endpoint is the URL of the endpoint.
+	Endpoint string
+	// State is the new state of the endpoint.
+	State EndpointState
+	// Healthy is how many endpoints of the client are up after the
+	// change, and Total how many it has. The client is degraded while
+	// Healthy is less than Total.
+	Healthy, Total int
+}
+
+// endpoint is a URL requests can be sent to. Its fields are guarded by the
+// mutex of the pool.
+type endpoint struct {
+	url string
+
+	// down is whether the endpoint failed since it last succeeded, and
+	// downUntil is the time until which it is considered unhealthy
+	down      bool
+	downUntil time.Time
+
+	// pending is the number of requests in flight to the endpoint
+	pending int
+}
+
+// endpointPool tracks the health of the endpoints of a client. The first
+// replicas endpoints share the load according to the balancing policy,
+// the remaining endpoints are backups in order of preference.
+type endpointPool struct {
+	mu        sync.Mutex
+	endpoints []*endpoint
+	replicas  int
+	balancing LoadBalancing
+	next      int
+	cooldown  time.Duration
+}
+
+func (c *Client) newEndpointPool() *endpointPool {
+	pool := &endpointPool{
+		endpoints: []*endpoint{{url: c.endpoint}},
+		balancing: c.loadBalancing,
+		cooldown:  c.failoverCooldown,
+	}
+	for _, url := range c.replicas {
+		pool.endpoints = append(pool.endpoints, &endpoint{url: c.resolveEndpoint(url)})
+	}
+	pool.replicas = len(pool.endpoints)
+	for _, url := range c.failover {
+		pool.endpoints = append(pool.endpoints, &endpoint{url: c.resolveEndpoint(url)})
+	}
+	return pool
+}
+
+// order returns the endpoints to try: the healthy replicas ordered by the
+// balancing policy and the healthy backups in order of preference,
+// followed by the unhealthy endpoints as a last resort, starting with the
+// one expected to recover first, as of now.
+func (p *endpointPool) order(now time.Time) []*endpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	replicas := make([]*endpoint, 0, len(p.endpoints))
+	switch p.balancing {
+	case RoundRobin:
+		start := p.next % p.replicas
+		p.next++
+		replicas = append(replicas, p.endpoints[start:p.replicas]...)
+		replicas = append(replicas, p.endpoints[:start]...)
+	case LeastPending:
+		replicas = append(replicas, p.endpoints[:p.replicas]...)
+		sort.SliceStable(replicas, func(i, j int) bool {
+			return replicas[i].pending < replicas[j].pending
+		})
+	}
+	var healthy, down []*endpoint
+	for _, ep := range append(replicas, p.endpoints[p.replicas:]...) {
+		if now.Before(ep.downUntil) {
+			down = append(down, ep)
+			continue
+		}
+		healthy = append(healthy, ep)
+	}
+	sort.SliceStable(down, func(i, j int) bool {
+		return down[i].downUntil.Before(down[j].downUntil)
+	})
+	return append(healthy, down...)
+}
+
+// markDown marks ep as unhealthy until the cooldown has passed since now,
+// calling onStateChange, if not nil, if it was up.
+func (p *endpointPool) markDown(ep *endpoint, now time.Time, onStateChange func(StateChange)) {
+	p.mu.Lock()
+	ep.downUntil = now.Add(p.cooldown)
+	p.setState(ep, true, onStateChange)
+}
+
+// markUp marks ep as healthy, calling onStateChange, if not nil, if it was
+// down.
+func (p *endpointPool) markUp(ep *endpoint, onStateChange func(StateChange)) {
+	p.mu.Lock()
+	ep.downUntil = time.Time{}
+	p.setState(ep, false, onStateChange)
+}
+
+// setState records whether ep is down, unlocking the pool, and then
+// calling onStateChange if the state changed.
+func (p *endpointPool) setState(ep *endpoint, down bool, onStateChange func(StateChange)) {
+	if ep.down == down {
+		p.mu.Unlock()
+		return
+	}
+	ep.down = down
+	change := StateChange{Endpoint: ep.url, Total: len(p.endpoints)}
+	if down {
+		change.State = EndpointDown
+	}
+	for _, ep := range p.endpoints {
+		if !ep.down {
+			change.Healthy++
+		}
+	}
+	p.mu.Unlock()
+	if onStateChange != nil {
+		onStateChange(change)
+	}
+}
+
+// acquire records a request in flight to ep, returning a function that
+// must be called once the request is complete.
+func (p *endpointPool) acquire(ep *endpoint) func() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ep.pending++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			ep.pending--
+		})
+	}
+}
+
+// releaseBody is a response body that completes a pending request once
+// it is closed.
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
+
+// send sends req to the first healthy endpoint, failing over to the next
+// endpoint when one is unreachable or responds with a server error. The
+// response from the last endpoint tried is returned.
+//
+// A server error, or a transport error after connecting, may mean the
+// server already executed the operation, so only queries and requests
+// marked idempotent fail over in that case. Other operations fail over
+// only if they could not be sent.
+func (c *Client) send(ctx context.Context, req *execution) (*http.Response, error) {
+	endpoints := c.endpoints.order(c.clock.Now())
+	safe := req.resendable()
+	for i, ep := range endpoints {
+		r, err := c.newHTTPRequest(ctx, req, ep.url)
+		if err != nil {
+			return nil, err
+		}
+		release := c.endpoints.acquire(ep)
+		res, err := c.do(r)
+		if err != nil {
+			release()
+		} else {
+			res.Body = &releaseBody{ReadCloser: res.Body, release: release}
+		}
+		if err == nil && res.StatusCode < http.StatusInternalServerError {
+			c.endpoints.markUp(ep, c.onStateChange)
+			return res, nil
+		}
+		if ctx.Err() != nil {
+			// The caller gave up, which says nothing about the endpoint
+			return res, err
+		}
+		c.endpoints.markDown(ep, c.clock.Now(), c.onStateChange)
+		if i == len(endpoints)-1 || !safe && !isDialError(err) {
+			return res, err
+		}
+		if res != nil {
+			res.Body.Close()
+		}
+		c.logf(">> failover: %s", endpoints[i+1].url)
+	}
+	return nil, nil
+}
+
+// isDialError reports whether err shows that no connection could be made,
+// so nothing was sent.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// WithEndpoints adds replicas of the endpoint given to NewClient, and
+// distributes requests across all of them using the policy set by
+// WithLoadBalancing. Requests fail over to the next replica when one is
+// unreachable or responds with a 5xx status code.
+//
+//	NewClient(replica1, WithEndpoints(replica2, replica3))
+func WithEndpoints(endpoints ...string) ClientOption {
+	return func(client *Client) {
+		client.replicas = append(client.replicas, endpoints...)
+	}
+}
+
+// WithLoadBalancing sets the policy used to distribute requests across
+// the replicas given to WithEndpoints. The default is RoundRobin.
+func WithLoadBalancing(policy LoadBalancing) ClientOption {
+	return func(client *Client) {
+		client.loadBalancing = policy
+	}
+}
+
+// WithFailover adds backup endpoints that requests are sent to, in order,
+// when the primary endpoint is unreachable or responds with a 5xx status
+// code. An endpoint that fails is skipped until the cooldown set by
+// WithFailoverCooldown has passed, after which it is used again.
+//
+//	NewClient(primary, WithFailover(secondary, tertiary))
+func WithFailover(endpoints ...string) ClientOption {
+	return func(client *Client) {
+		client.failover = append(client.failover, endpoints...)
+	}
+}
+
+// OnStateChange sets a function called when an endpoint of the client goes
+// down, because it was unreachable or responded with a 5xx status code,
+// and when it is back up, because a request to it succeeded, so that
+// operators can be alerted while the client is degraded and failing over.
+// It is called from the goroutine sending the request, so should not
+// block.
+func OnStateChange(fn func(change StateChange)) ClientOption {
+	return func(client *Client) {
+		client.onStateChange = fn
+	}
+}
+
+// WithFailoverCooldown sets how long an endpoint that failed is skipped
+// before requests are sent to it again. The default is 30 seconds.
+func WithFailoverCooldown(d time.Duration) ClientOption {
+	return func(client *Client) {
+		client.failoverCooldown = d
+	}
+}
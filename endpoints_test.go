@@ -0,0 +1,191 @@
+package graphql
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestFailover(t *testing.T) {
+	is := is.New(t)
+	var primaryCalls, backupCalls int
+	primaryDown := true
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryCalls++
+		if primaryDown {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"data":{"value":"primary"}}`)
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backupCalls++
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `{"query":"query {}","variables":null}`+"\n")
+		io.WriteString(w, `{"data":{"value":"backup"}}`)
+	}))
+	defer backup.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(primary.URL, WithFailover(backup.URL), WithFailoverCooldown(50*time.Millisecond))
+	var resp struct {
+		Value string
+	}
+	err := client.Run(ctx, NewRequest("query {}"), &resp)
+	is.NoErr(err)
+	is.Equal(resp.Value, "backup")
+	is.Equal(primaryCalls, 1)
+	is.Equal(backupCalls, 1)
+
+	// the primary is skipped while it cools down
+	err = client.Run(ctx, NewRequest("query {}"), &resp)
+	is.NoErr(err)
+	is.Equal(primaryCalls, 1)
+	is.Equal(backupCalls, 2)
+
+	// and used again once it has recovered
+	primaryDown = false
+	time.Sleep(60 * time.Millisecond)
+	err = client.Run(ctx, NewRequest("query {}"), &resp)
+	is.NoErr(err)
+	is.Equal(resp.Value, "primary")
+	is.Equal(primaryCalls, 2)
+	is.Equal(backupCalls, 2)
+}
+
+func TestFailoverAllDown(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient("http://127.0.0.1:1", WithFailover(srv.URL))
+	err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.Equal(err.Error(), "graphql: server returned a non-200 status code: 502")
+}
+
+func TestRoundRobin(t *testing.T) {
+	is := is.New(t)
+	calls := make([]int, 3)
+	var urls []string
+	for i := range calls {
+		i := i
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls[i]++
+			io.WriteString(w, `{"data":{}}`)
+		}))
+		defer srv.Close()
+		urls = append(urls, srv.URL)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(urls[0], WithEndpoints(urls[1:]...))
+	for i := 0; i < 6; i++ {
+		err := client.Run(ctx, NewRequest("query {}"), nil)
+		is.NoErr(err)
+	}
+	is.Equal(calls, []int{2, 2, 2})
+}
+
+func TestLeastPending(t *testing.T) {
+	is := is.New(t)
+	pool := &endpointPool{
+		endpoints: []*endpoint{{url: "a"}, {url: "b"}, {url: "c"}},
+		replicas:  2,
+		balancing: LeastPending,
+	}
+	release := pool.acquire(pool.endpoints[0])
+	order := pool.order(time.Now())
+	is.Equal(order[0].url, "b")
+	is.Equal(order[1].url, "a")
+	is.Equal(order[2].url, "c") // backups come after the replicas
+	release()
+	release() // releasing twice has no effect
+	is.Equal(pool.endpoints[0].pending, 0)
+	is.Equal(pool.order(time.Now())[0].url, "a")
+}
+
+func TestFailoverMutation(t *testing.T) {
+	is := is.New(t)
+	var backupCalls int
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backupCalls++
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer backup.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	// a mutation that reached the server is never replayed
+	client := NewClient(primary.URL, WithFailover(backup.URL))
+	err := client.Run(ctx, NewRequest("mutation { save }"), nil)
+	is.Equal(err.Error(), "graphql: server returned a non-200 status code: 503")
+	is.Equal(backupCalls, 0)
+
+	// but fails over when it could not be sent at all
+	client = NewClient("http://127.0.0.1:1", WithFailover(backup.URL))
+	err = client.Run(ctx, NewRequest("mutation { save }"), nil)
+	is.NoErr(err)
+	is.Equal(backupCalls, 1)
+}
+
+func TestOnStateChange(t *testing.T) {
+	is := is.New(t)
+	primaryDown := true
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		if primaryDown {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer backup.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var changes []StateChange
+	client := NewClient(primary.URL, WithFailover(backup.URL), WithFailoverCooldown(10*time.Millisecond), OnStateChange(func(change StateChange) {
+		changes = append(changes, change)
+	}))
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	is.Equal(changes, []StateChange{{Endpoint: primary.URL, State: EndpointDown, Healthy: 1, Total: 2}})
+
+	// Failing again after the cooldown is not a change
+	time.Sleep(20 * time.Millisecond)
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	is.Equal(len(changes), 1)
+
+	primaryDown = false
+	time.Sleep(20 * time.Millisecond)
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	is.Equal(changes[1], StateChange{Endpoint: primary.URL, State: EndpointUp, Healthy: 2, Total: 2})
+	is.Equal(changes[1].State.String(), "up")
+	is.Equal(len(changes), 2)
+}
@@ -0,0 +1,369 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error is an error returned by the GraphQL server.
+type Error struct {
+	// Message describes the error.
+	Message string `json:"message"`
+	// Locations are the locations in the query document the error
+	// refers to, if any.
+	Locations []Location `json:"locations,omitempty"`
+	// Path is the path of the response field the error occurred in, made
+	// of field names and list indexes, if the error occurred while
+	// executing the operation.
+	Path []interface{} `json:"path,omitempty"`
+	// Extensions holds any additional information the server provided,
+	// such as an error code.
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return "graphql: " + e.Message
+}
+
+// Code returns the code in the extensions of the error, such as
+// UNAUTHENTICATED, or an empty string if there is none.
+func (e *Error) Code() string {
+	code, _ := e.Extensions["code"].(string)
+	return code
+}
+
+// PathString returns the path of the error in dotted form, such as
+// user.posts.3.title.
+func (e *Error) PathString() string {
+	return pathString(e.Path)
+}
+
+// pathString returns path in dotted form.
+func pathString(path []interface{}) string {
+	var b strings.Builder
+	for i, elem := range path {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		fmt.Fprint(&b, elem)
+	}
+	return b.String()
+}
+
+// Error codes commonly set in the extensions of errors by servers, such as
+// Apollo Server, for use with HasErrorCode.
+const (
+	CodeGraphQLParseFailed         = "GRAPHQL_PARSE_FAILED"
+	CodeGraphQLValidationFailed    = "GRAPHQL_VALIDATION_FAILED"
+	CodeBadUserInput               = "BAD_USER_INPUT"
+	CodeBadRequest                 = "BAD_REQUEST"
+	CodeUnauthenticated            = "UNAUTHENTICATED"
+	CodeForbidden                  = "FORBIDDEN"
+	CodeInternalServerError        = "INTERNAL_SERVER_ERROR"
+	CodePersistedQueryNotFound     = "PERSISTED_QUERY_NOT_FOUND"
+	CodePersistedQueryNotSupported = "PERSISTED_QUERY_NOT_SUPPORTED"
+	CodeOperationResolutionFailure = "OPERATION_RESOLUTION_FAILURE"
+)
+
+// HasErrorCode reports whether err is, or wraps, a GraphQL error with the
+// given code, such as CodePersistedQueryNotFound. Every error in Errors is
+// checked, not only the first.
+func HasErrorCode(err error, code string) bool {
+	var gqlErrs Errors
+	if errors.As(err, &gqlErrs) {
+		for _, gqlErr := range gqlErrs {
+			if gqlErr.Code() == code {
+				return true
+			}
+		}
+		return false
+	}
+	var gqlErr *Error
+	return errors.As(err, &gqlErr) && gqlErr.Code() == code
+}
+
+// Location is a location in a query document.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Errors is the list of errors returned by the GraphQL server, which Run
+// returns when the response contains any errors. Use errors.As to get the
+// list, or any of the errors in it:
+//
+//	var gqlErrs graphql.Errors
+//	if errors.As(err, &gqlErrs) {
+//		for _, gqlErr := range gqlErrs {
+//			log.Println(gqlErr.Message)
+//		}
+//	}
+type Errors []*Error
+
+// Error returns the message of the first error, noting how many more
+// errors there are.
+func (e Errors) Error() string {
+	switch len(e) {
+	case 0:
+		return "graphql: no errors"
+	case 1:
+		return e[0].Error()
+	case 2:
+		return fmt.Sprintf("%s (and 1 more error)", e[0].Error())
+	}
+	return fmt.Sprintf("%s (and %d more errors)", e[0].Error(), len(e)-1)
+}
+
+// Unwrap returns every error in the list.
+func (e Errors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// ForPath returns the errors for the response field at path, made of
+// field names, or aliases, and list indexes, and for the fields nested in
+// it:
+//
+//	postErrs := errs.ForPath("user", "posts", 3)
+//
+// It returns nil if no error affects the field.
+func (e Errors) ForPath(path ...interface{}) Errors {
+	var errs Errors
+	for _, err := range e {
+		if err.hasPathPrefix(path) {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// hasPathPrefix reports whether the path of the error starts with prefix.
+func (e *Error) hasPathPrefix(prefix []interface{}) bool {
+	if len(e.Path) < len(prefix) {
+		return false
+	}
+	for i, elem := range prefix {
+		if !pathElemEqual(e.Path[i], elem) {
+			return false
+		}
+	}
+	return true
+}
+
+// pathElemEqual reports whether two path elements are equal. Indexes
+// decoded from JSON are float64, and equal to indexes of any integer type.
+func pathElemEqual(a, b interface{}) bool {
+	x, xok := pathIndex(a)
+	y, yok := pathIndex(b)
+	if xok || yok {
+		return xok && yok && x == y
+	}
+	return a == b
+}
+
+// pathIndex returns the list index of a path element, if it is one.
+func pathIndex(elem interface{}) (float64, bool) {
+	switch v := elem.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// ErrNoData is returned by clients created with RequireData when a
+// response has neither data nor errors.
+var ErrNoData = errors.New("graphql: response has no data")
+
+// maxErrorBody is the number of bytes of the response body kept in an
+// HTTPError.
+const maxErrorBody = 4 << 10
+
+// HTTPError is returned when the server responds with a non-2xx status
+// code and no GraphQL errors.
+type HTTPError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Header holds the headers of the response.
+	Header http.Header
+	// Body holds the start of the response body, truncated to 4KiB.
+	Body []byte
+}
+
+func newHTTPError(res *http.Response, body []byte) *HTTPError {
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody]
+	}
+	return &HTTPError{
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		Body:       append([]byte(nil), body...),
+	}
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("graphql: server returned a non-200 status code: %v", e.StatusCode)
+}
+
+// RetryAfter returns how long the server asked clients to wait before
+// retrying in the Retry-After header of the response, given in seconds or
+// as a date. ok is false if the response has no valid Retry-After header.
+func (e *HTTPError) RetryAfter() (d time.Duration, ok bool) {
+	value := strings.TrimSpace(e.Header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if d = time.Until(date); d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// TransportError is returned when the request could not be sent, or the
+// response could not be read.
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return "graphql: " + e.Err.Error()
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError is returned when the response could not be decoded.
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return "graphql: failed to decode response: " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// MalformedResponseError is returned when the body of a response is not
+// valid JSON, or not a JSON object, such as an HTML error page returned by
+// a proxy with a JSON content type, or a truncated body.
+type MalformedResponseError struct {
+	// ContentType is the Content-Type header of the response.
+	ContentType string
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Body holds the start of the response body, truncated to 4KiB.
+	Body []byte
+	// Err is the error decoding the body.
+	Err error
+}
+
+// maxMalformedSnippet is the number of bytes of the body included in the
+// message of a MalformedResponseError.
+const maxMalformedSnippet = 128
+
+func newMalformedResponseError(res *http.Response, body []byte, err error) *MalformedResponseError {
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody]
+	}
+	return &MalformedResponseError{
+		ContentType: res.Header.Get("Content-Type"),
+		StatusCode:  res.StatusCode,
+		Body:        append([]byte(nil), body...),
+		Err:         err,
+	}
+}
+
+func (e *MalformedResponseError) Error() string {
+	body := e.Body
+	ellipsis := ""
+	if len(body) > maxMalformedSnippet {
+		body, ellipsis = body[:maxMalformedSnippet], "..."
+	}
+	return fmt.Sprintf("graphql: malformed response (content type %q): %v: %q%s", e.ContentType, e.Err, body, ellipsis)
+}
+
+func (e *MalformedResponseError) Unwrap() error {
+	return e.Err
+}
+
+// TimeoutError is returned when a request is not done within the timeout
+// set with Request.Timeout or WithTimeout, rather than the context it is
+// run with being done or the server failing.
+type TimeoutError struct {
+	// Timeout is the timeout of the request.
+	Timeout time.Duration
+	// Err is the error the request failed with when it timed out.
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("graphql: request timed out after %v", e.Timeout)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// RewindError is returned when a request that failed with a retriable
+// error is not retried because one of its files cannot be read again from
+// the start, since its reader is not an io.Seeker or seeking it failed.
+type RewindError struct {
+	// Field and Name are the form field and file name of the file, or
+	// the path of the variable and file name of an Upload.
+	Field, Name string
+	// SeekErr is the error seeking the reader, or nil if it is not an
+	// io.Seeker.
+	SeekErr error
+	// Err is the error of the attempt that would have been retried.
+	Err error
+}
+
+func (e *RewindError) Error() string {
+	reason := "reader is not an io.Seeker"
+	if e.SeekErr != nil {
+		reason = e.SeekErr.Error()
+	}
+	return fmt.Sprintf("graphql: cannot retry request: cannot rewind file %s (%s): %s: %v", e.Field, e.Name, reason, e.Err)
+}
+
+func (e *RewindError) Unwrap() error {
+	return e.Err
+}
+
+// ResponseTooLargeError is returned when the response body is larger than
+// the limit set with WithMaxResponseBytes.
+type ResponseTooLargeError struct {
+	// Limit is the maximum number of bytes allowed.
+	Limit int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("graphql: response body larger than %d bytes", e.Limit)
+}
@@ -0,0 +1,274 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestErrors(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{
+			"errors": [
+				{"message": "first"},
+				{"message": "second"},
+				{"message": "third"}
+			]
+		}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.Equal(err.Error(), "graphql: first (and 2 more errors)")
+
+	var gqlErrs Errors
+	is.True(errors.As(err, &gqlErrs))
+	is.Equal(len(gqlErrs), 3)
+	is.Equal(gqlErrs[2].Message, "third")
+
+	var gqlErr *Error
+	is.True(errors.As(err, &gqlErr))
+	is.Equal(gqlErr.Message, "first")
+}
+
+func TestErrorFields(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{
+			"errors": [{
+				"message": "not signed in",
+				"locations": [{"line": 2, "column": 3}],
+				"path": ["user", "posts", 3],
+				"extensions": {"code": "UNAUTHENTICATED", "retry": false}
+			}]
+		}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	err := client.Run(ctx, NewRequest("query {}"), nil)
+	var gqlErr *Error
+	is.True(errors.As(err, &gqlErr))
+	is.Equal(gqlErr.Locations, []Location{{Line: 2, Column: 3}})
+	is.Equal(gqlErr.Path, []interface{}{"user", "posts", float64(3)})
+	is.Equal(gqlErr.PathString(), "user.posts.3")
+	is.Equal(gqlErr.Code(), "UNAUTHENTICATED")
+	is.Equal(gqlErr.Extensions["retry"], false)
+}
+
+func TestRunPartial(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{
+			"data": {"user": {"name": "Mat", "avatar": null}},
+			"errors": [{"message": "avatar unavailable", "path": ["user", "avatar"]}]
+		}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	var resp struct {
+		User struct {
+			Name   string
+			Avatar *string
+		}
+	}
+	errs, err := client.RunPartial(ctx, NewRequest("query {}"), &resp)
+	is.NoErr(err)
+	is.Equal(len(errs), 1)
+	is.Equal(errs[0].PathString(), "user.avatar")
+	is.Equal(resp.User.Name, "Mat")
+	is.True(resp.User.Avatar == nil)
+}
+
+func TestRunPartialFailure(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	errs, err := client.RunPartial(ctx, NewRequest("query {}"), nil)
+	is.True(err != nil)
+	is.True(errs == nil)
+}
+
+func TestHTTPError(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, strings.Repeat("slow down ", 1000))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.Equal(err.Error(), "graphql: server returned a non-200 status code: 429")
+	var httpErr *HTTPError
+	is.True(errors.As(err, &httpErr))
+	is.Equal(httpErr.StatusCode, http.StatusTooManyRequests)
+	is.Equal(httpErr.Header.Get("Retry-After"), "30")
+	is.Equal(len(httpErr.Body), maxErrorBody)
+	is.True(strings.HasPrefix(string(httpErr.Body), "slow down slow down"))
+}
+
+func TestTransportError(t *testing.T) {
+	is := is.New(t)
+	client := NewClient("http://127.0.0.1:1")
+	err := client.Run(context.Background(), NewRequest("query {}"), nil)
+	var transportErr *TransportError
+	is.True(errors.As(err, &transportErr))
+	var opErr *net.OpError
+	is.True(errors.As(err, &opErr))
+	is.Equal(opErr.Op, "dial")
+}
+
+func TestTransportErrorCanceled(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.True(errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestDecodeError(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"data":{"value":1}}`)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	var resp struct {
+		Value string
+	}
+	err := client.Run(context.Background(), NewRequest("query {}"), &resp)
+	var decodeErr *DecodeError
+	is.True(errors.As(err, &decodeErr))
+	var typeErr *json.UnmarshalTypeError
+	is.True(errors.As(err, &typeErr))
+}
+
+func TestMalformedResponseError(t *testing.T) {
+	is := is.New(t)
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	body = `{"data":`
+	err := client.Run(context.Background(), NewRequest("query {}"), nil)
+	var malformedErr *MalformedResponseError
+	is.True(errors.As(err, &malformedErr))
+	is.True(errors.Is(err, io.ErrUnexpectedEOF))
+	is.Equal(malformedErr.ContentType, "application/json")
+	is.Equal(malformedErr.StatusCode, http.StatusOK)
+	is.Equal(string(malformedErr.Body), `{"data":`)
+
+	body = "<html><body>" + strings.Repeat("Bad Gateway ", 20) + "</body></html>"
+	err = client.Run(context.Background(), NewRequest("query {}"), nil)
+	is.True(errors.As(err, &malformedErr))
+	is.Equal(string(malformedErr.Body), body)
+	is.Equal(err.Error(), `graphql: malformed response (content type "application/json"): invalid character '<' looking for beginning of value: "`+body[:128]+`"...`)
+
+	// Empty bodies have no data, see TestDoEmptyBody
+	for _, body = range []string{`[1]`, `  x`} {
+		err = client.Run(context.Background(), NewRequest("query {}"), nil)
+		is.True(errors.As(err, &malformedErr)) // body
+	}
+}
+
+func TestErrorsForPath(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{
+			"data": {"user": {"name": "Mat", "posts": [{}, {}, {}, null]}},
+			"errors": [
+				{"message": "no title", "path": ["user", "posts", 3, "title"]},
+				{"message": "no post", "path": ["user", "posts", 2]},
+				{"message": "no avatar", "path": ["user", "avatar"]},
+				{"message": "unauthorized"}
+			]
+		}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	errs, err := client.RunPartial(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	is.Equal(len(errs.ForPath("user")), 3)
+	is.Equal(len(errs.ForPath("user", "posts")), 2)
+	postErrs := errs.ForPath("user", "posts", 3)
+	is.Equal(len(postErrs), 1)
+	is.Equal(postErrs[0].Message, "no title")
+	is.Equal(errs.ForPath("user", "name"), Errors(nil))
+	is.Equal(errs.ForPath("user", "posts", "3"), Errors(nil)) // indexes are not names
+	is.Equal(len(errs.ForPath()), 4)
+}
+
+func TestHasErrorCode(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"errors": [
+			{"message": "bad input", "extensions": {"code": "BAD_USER_INPUT"}},
+			{"message": "not found", "extensions": {"code": "PERSISTED_QUERY_NOT_FOUND"}}
+		]}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	err := NewClient(srv.URL).Run(ctx, NewRequest("query {}"), nil)
+	is.True(HasErrorCode(err, CodeBadUserInput))
+	is.True(HasErrorCode(err, CodePersistedQueryNotFound))
+	is.True(HasErrorCode(fmt.Errorf("wrapped: %w", err), CodePersistedQueryNotFound))
+	is.True(!HasErrorCode(err, CodeUnauthenticated))
+	is.True(HasErrorCode(&Error{Extensions: map[string]interface{}{"code": "FORBIDDEN"}}, CodeForbidden))
+	is.True(!HasErrorCode(errors.New("FORBIDDEN"), CodeForbidden))
+	is.True(!HasErrorCode(nil, CodeForbidden))
+}
@@ -0,0 +1,251 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Fanout runs the operation of req once for each set of variables in a
+// single request, returning the data for each set decoded into a value of
+// type T, in the order of the sets. The operation is repeated in one
+// document with its top-level fields aliased, and its variables renamed,
+// for each set:
+//
+//	query ($id_0: ID!, $id_1: ID!) {
+//		q0_item: item(id: $id_0) { name }
+//		q1_item: item(id: $id_1) { name }
+//	}
+//
+// Variables missing from a set take their value from the variables of
+// req. Fragments used by the operation cannot use variables, and the
+// top-level fields cannot be in fragments.
+//
+// If the server returns errors, they are returned as Errors with the data
+// that was decoded, and their paths start with the aliases of the fields.
+// Other failures return no data.
+func Fanout[T any](ctx context.Context, client *Client, req *Request, vars []map[string]interface{}, opts ...RunOption) ([]T, error) {
+	fanout, keys, err := client.fanoutRequest(req, vars)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]json.RawMessage
+	runErr := client.Run(ctx, fanout, &data, opts...)
+	var errs Errors
+	if runErr != nil && !errors.As(runErr, &errs) {
+		return nil, runErr
+	}
+	items := make([]T, len(vars))
+	for i := range vars {
+		fields := make(map[string]json.RawMessage, len(keys))
+		for _, key := range keys {
+			if value, ok := data[fanoutAlias(i, key)]; ok {
+				fields[key] = value
+			}
+		}
+		b, err := json.Marshal(fields)
+		if err != nil {
+			return nil, &DecodeError{Err: err}
+		}
+		if err := client.decodeData(b, &items[i]); err != nil {
+			return nil, &DecodeError{Err: err}
+		}
+	}
+	return items, runErr
+}
+
+// fanoutAlias returns the alias of the top-level field with the response
+// name key for the variable set i.
+func fanoutAlias(i int, key string) string {
+	return "q" + strconv.Itoa(i) + "_" + key
+}
+
+// rootField is a top-level field of an operation.
+type rootField struct {
+	// start is the offset of the field
+	start int
+	// key is the response name of the field, which is its alias if
+	// aliased is true
+	key     string
+	aliased bool
+}
+
+// fanoutRequest returns the request running the operation of req once
+// for each set of variables, and the response names of its top-level
+// fields.
+func (c *Client) fanoutRequest(req *Request, sets []map[string]interface{}) (*Request, []string, error) {
+	if len(sets) == 0 {
+		return nil, nil, errors.New("graphql: no variable sets to fan out")
+	}
+	q := req.q
+	op, defs, err := operationDefinition(q, req.operationName)
+	if err != nil {
+		return nil, nil, err
+	}
+	varDefs, _ := variableDefinitions(q, req.operationName)
+	declared := make(map[string]bool, len(varDefs))
+	for _, def := range varDefs {
+		declared[def.name] = true
+	}
+	for _, def := range defs {
+		if def.keyword == "fragment" && len(variableReferences(q, def.start, def.end, declared)) > 0 {
+			return nil, nil, fmt.Errorf("graphql: cannot fan out operation using variables in fragment %s", def.name)
+		}
+	}
+	selection := selectionSetStart(q, op.start)
+	fields, err := rootFields(q, selection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The keyword and name of the operation, and the directives following
+	// its variable definitions
+	var b strings.Builder
+	b.WriteString(q[:op.start])
+	header := op.start
+	if op.keyword == "" {
+		b.WriteString("query")
+	} else {
+		header = skipName(q, skipIgnored(q, skipName(q, op.start)))
+		b.WriteString(q[op.start:header])
+	}
+	directives := skipIgnored(q, header)
+	if directives < selection && q[directives] == '(' {
+		directives = skipValue(q, directives)
+	}
+	if len(varDefs) > 0 {
+		b.WriteString("(")
+		for i := range sets {
+			for j, def := range varDefs {
+				if i > 0 || j > 0 {
+					b.WriteString(", ")
+				}
+				b.WriteString("$" + def.name + "_" + strconv.Itoa(i) + ": " + def.typ)
+				if def.defaultValue != "" {
+					b.WriteString(" = " + def.defaultValue)
+				}
+			}
+		}
+		b.WriteString(")")
+	}
+	if op.keyword == "" {
+		b.WriteString(" ")
+	}
+	b.WriteString(q[directives:selection])
+
+	// The selection set repeated for each variable set, without the
+	// spaces at its end. Line breaks are kept, to end any comment.
+	refs := variableReferences(q, selection, op.end, declared)
+	end := op.end - 1
+	for end > selection+1 && strings.IndexByte(" \t,", q[end-1]) >= 0 {
+		end--
+	}
+	b.WriteString("{")
+	for i := range sets {
+		suffix := "_" + strconv.Itoa(i)
+		var insertions []insertion
+		for _, field := range fields {
+			if field.aliased {
+				// Prefix the alias of the field
+				insertions = append(insertions, insertion{offset: field.start, text: fanoutAlias(i, "")})
+			} else {
+				insertions = append(insertions, insertion{offset: field.start, text: fanoutAlias(i, field.key) + ": "})
+			}
+		}
+		for _, ref := range refs {
+			insertions = append(insertions, insertion{offset: ref, text: suffix})
+		}
+		sort.SliceStable(insertions, func(i, j int) bool {
+			return insertions[i].offset < insertions[j].offset
+		})
+		last := selection + 1
+		for _, ins := range insertions {
+			b.WriteString(q[last:ins.offset])
+			b.WriteString(ins.text)
+			last = ins.offset
+		}
+		b.WriteString(q[last:end])
+	}
+	b.WriteString(q[end:])
+
+	// The variables of each set, renamed, falling back to the variables
+	// of the request
+	base := c.requestVars(req)
+	vars := make(map[string]interface{}, len(sets)*len(varDefs))
+	for i, set := range sets {
+		for _, def := range varDefs {
+			value, ok := set[def.name]
+			if !ok {
+				value, ok = base[def.name]
+			}
+			if ok && !isOmitted(value) {
+				vars[def.name+"_"+strconv.Itoa(i)] = value
+			}
+		}
+	}
+
+	fanout := req.Clone()
+	fanout.q, fanout.vars = b.String(), vars
+	fanout.hash = &documentHash{}
+	keys := make([]string, len(fields))
+	for i, field := range fields {
+		keys[i] = field.key
+	}
+	return fanout, keys, nil
+}
+
+// rootFields returns the fields of the selection set starting at i.
+func rootFields(q string, i int) ([]rootField, error) {
+	var fields []rootField
+	for i = skipIgnored(q, i+1); i < len(q) && q[i] != '}'; i = skipIgnored(q, i) {
+		if strings.HasPrefix(q[i:], "...") {
+			return nil, errors.New("graphql: cannot fan out operation with fragments at the top level")
+		}
+		start := i
+		i = skipName(q, i)
+		if i == start {
+			return nil, fmt.Errorf("graphql: unexpected %q in selection set", q[i])
+		}
+		field := rootField{start: start, key: q[start:i]}
+		if j := skipIgnored(q, i); j < len(q) && q[j] == ':' {
+			field.aliased = true
+			i = skipName(q, skipIgnored(q, j+1))
+		}
+		if j := skipIgnored(q, i); j < len(q) && q[j] == '(' {
+			i = skipValue(q, j)
+		}
+		i = skipDirectives(q, i)
+		if j := skipIgnored(q, i); j < len(q) && q[j] == '{' {
+			i = skipDefinition(q, j)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// variableReferences returns the offsets just past the names of the uses
+// of the declared variables between start and end in q.
+func variableReferences(q string, start, end int, declared map[string]bool) []int {
+	var refs []int
+	for i := start; i < end; {
+		switch q[i] {
+		case '#':
+			i = skipIgnored(q, i)
+		case '"':
+			i = skipString(q, i)
+		case '$':
+			nameEnd := skipName(q, i+1)
+			if declared[q[i+1:nameEnd]] {
+				refs = append(refs, nameEnd)
+			}
+			i = nameEnd
+		default:
+			i++
+		}
+	}
+	return refs
+}
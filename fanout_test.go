@@ -0,0 +1,88 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestFanoutRequest(t *testing.T) {
+	is := is.New(t)
+	client := NewClient("", WithDefaultVars(map[string]interface{}{"lang": "en"}))
+	req := NewRequest(`query Items($id: ID!, $size: Int = 10) @cached {
+	item(id: $id) { name(lang: $lang) ...F }
+	p: price(id: $id, note: "$id")
+}
+fragment F on Item { id }`)
+	req.Var("size", 20)
+	fanout, keys, err := client.fanoutRequest(req, []map[string]interface{}{{"id": "a"}, {"id": "b", "size": 5}})
+	is.NoErr(err)
+	is.Equal(keys, []string{"item", "p"})
+	is.Equal(fanout.Query(), `query Items($id_0: ID!, $size_0: Int = 10, $id_1: ID!, $size_1: Int = 10) @cached {
+	q0_item: item(id: $id_0) { name(lang: $lang) ...F }
+	q0_p: price(id: $id_0, note: "$id")
+
+	q1_item: item(id: $id_1) { name(lang: $lang) ...F }
+	q1_p: price(id: $id_1, note: "$id")
+}
+fragment F on Item { id }`)
+	is.Equal(fanout.Vars(), map[string]interface{}{"id_0": "a", "size_0": 20, "id_1": "b", "size_1": 5})
+	is.Equal(req.Query(), `query Items($id: ID!, $size: Int = 10) @cached {
+	item(id: $id) { name(lang: $lang) ...F }
+	p: price(id: $id, note: "$id")
+}
+fragment F on Item { id }`)
+
+	fanout, _, err = client.fanoutRequest(NewRequest(`{ a }`), []map[string]interface{}{{}, {}})
+	is.NoErr(err)
+	is.Equal(fanout.Query(), `query { q0_a: a q1_a: a }`)
+
+	_, _, err = client.fanoutRequest(NewRequest(`query ($id: ID) { ...F } fragment F on Query { a }`), []map[string]interface{}{{}})
+	is.Equal(err.Error(), "graphql: cannot fan out operation with fragments at the top level")
+	_, _, err = client.fanoutRequest(NewRequest(`query ($id: ID) { a { ...F } } fragment F on A { b(id: $id) }`), []map[string]interface{}{{}})
+	is.Equal(err.Error(), "graphql: cannot fan out operation using variables in fragment F")
+	_, _, err = client.fanoutRequest(NewRequest(`{ a }`), nil)
+	is.True(err != nil)
+}
+
+func TestFanout(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query     string
+			Variables map[string]string
+		}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		is.Equal(body.Query, `query ($id_0: ID!, $id_1: ID!, $id_2: ID!) { q0_item: item(id: $id_0) { name } q1_item: item(id: $id_1) { name } q2_item: item(id: $id_2) { name } }`)
+		is.Equal(body.Variables, map[string]string{"id_0": "a", "id_1": "b", "id_2": "c"})
+		io.WriteString(w, `{
+			"data":{"q0_item":{"name":"A"},"q1_item":null,"q2_item":{"name":"C"}},
+			"errors":[{"message":"not found","path":["q1_item"]}]
+		}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	type itemResponse struct {
+		Item *struct {
+			Name string
+		}
+	}
+	req := NewRequest(`query ($id: ID!) { item(id: $id) { name } }`)
+	items, err := Fanout[itemResponse](ctx, NewClient(srv.URL), req, []map[string]interface{}{{"id": "a"}, {"id": "b"}, {"id": "c"}})
+	errs, ok := err.(Errors)
+	is.True(ok)
+	is.Equal(errs[0].PathString(), "q1_item")
+	is.Equal(len(items), 3)
+	is.Equal(items[0].Item.Name, "A")
+	is.True(items[1].Item == nil)
+	is.Equal(items[2].Item.Name, "C")
+}
@@ -0,0 +1,96 @@
+package graphql
+
+import (
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// FaultInjection configures synthetic failures injected into the requests
+// of a client with WithFaultInjection, to check how retries, failover and
+// callers cope with failures in staging. Each fault is injected into a
+// fraction of requests, chosen at random, from 0 for none to 1 for all.
+type FaultInjection struct {
+	// LatencyRate is the fraction of requests delayed by Latency before
+	// they are sent.
+	LatencyRate float64
+	Latency     time.Duration
+	// ErrorRate is the fraction of requests answered with StatusCode,
+	// which defaults to 503, without being sent.
+	ErrorRate  float64
+	StatusCode int
+	// ResetRate is the fraction of requests failing with a connection
+	// reset without being sent.
+	ResetRate float64
+}
+
+// WithFaultInjection injects the synthetic failures of faults into the
+// requests of the client, as if the network or the server had failed.
+// Faults are injected into each attempt at a request, before it is sent
+// to an endpoint, so they are retried and failed over like real failures.
+// It is meant for testing, and every fault is logged.
+func WithFaultInjection(faults FaultInjection) ClientOption {
+	return func(client *Client) {
+		client.faults = &faults
+	}
+}
+
+// do sends r with the http.Client of the client, injecting any faults.
+func (c *Client) do(r *http.Request) (*http.Response, error) {
+	faults := c.faults
+	if faults == nil {
+		return c.httpClient.Do(r)
+	}
+	if faults.LatencyRate > 0 && rand.Float64() < faults.LatencyRate {
+		c.logf("!! injecting %v latency", faults.Latency)
+		timer := time.NewTimer(faults.Latency)
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			return nil, &url.Error{Op: urlErrorOp(r.Method), URL: r.URL.String(), Err: r.Context().Err()}
+		case <-timer.C:
+		}
+	}
+	if faults.ResetRate > 0 && rand.Float64() < faults.ResetRate {
+		c.logf("!! injecting connection reset")
+		err := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+		return nil, &url.Error{Op: urlErrorOp(r.Method), URL: r.URL.String(), Err: err}
+	}
+	if faults.ErrorRate > 0 && rand.Float64() < faults.ErrorRate {
+		status := faults.StatusCode
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		c.logf("!! injecting %d response", status)
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		body := "injected fault"
+		return &http.Response{
+			Status:        http.StatusText(status),
+			StatusCode:    status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       r,
+		}, nil
+	}
+	return c.httpClient.Do(r)
+}
+
+// urlErrorOp returns the operation of the errors http.Client returns for
+// requests with the method, such as Get or Post.
+func urlErrorOp(method string) string {
+	if method == "" {
+		return "Get"
+	}
+	return method[:1] + strings.ToLower(method[1:])
+}
@@ -0,0 +1,54 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestWithFaultInjection(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		calls++
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithFaultInjection(FaultInjection{ErrorRate: 1, StatusCode: http.StatusBadGateway}))
+	err := client.Run(ctx, NewRequest("query {}"), nil)
+	httpErr, ok := err.(*HTTPError)
+	is.True(ok)
+	is.Equal(httpErr.StatusCode, http.StatusBadGateway)
+	is.True(IsRetriable(err))
+
+	client = NewClient(srv.URL, WithFaultInjection(FaultInjection{ResetRate: 1}))
+	err = client.Run(ctx, NewRequest("query {}"), nil)
+	is.True(errors.Is(err, syscall.ECONNRESET))
+	is.True(IsRetriable(err))
+	is.Equal(calls, 0) // the faulty requests are not sent
+
+	client = NewClient(srv.URL, WithFaultInjection(FaultInjection{LatencyRate: 1, Latency: 20 * time.Millisecond}))
+	start := time.Now()
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	is.True(time.Since(start) >= 20*time.Millisecond)
+	is.Equal(calls, 1)
+
+	// Failures are retried like real ones
+	client = NewClient(srv.URL, WithFaultInjection(FaultInjection{ErrorRate: 0.5}), WithRetry(50, time.Microsecond))
+	for i := 0; i < 10; i++ {
+		is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	}
+	is.Equal(calls, 11)
+}
@@ -0,0 +1,124 @@
+package graphql
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// RegisterFragment registers a fragment shared by the queries of the
+// client, which is appended to the document of every request spreading
+// it, or using it with Request.UseFragment, so that queries do not need
+// to include the fragments they use. body is the definition of the
+// fragment, with or without the fragment keyword and name:
+//
+//	client.RegisterFragment("UserFields", "on User { id name }")
+//
+// Fragments may use other registered fragments. Registering a different
+// fragment with the name of a registered fragment is an error.
+func (c *Client) RegisterFragment(name, body string) error {
+	body = strings.TrimSpace(body)
+	if strings.HasPrefix(body, "on") && (len(body) == 2 || !isNameChar(body[2])) {
+		body = "fragment " + name + " " + body
+	}
+	defs, err := parseDefinitions(body)
+	if err != nil {
+		return err
+	}
+	if len(defs) != 1 || defs[0].keyword != "fragment" || defs[0].name != name {
+		return fmt.Errorf("graphql: %q is not the definition of fragment %s", body, name)
+	}
+	r := c.fragments
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if registered, ok := r.fragments[name]; ok {
+		if registered.body == body {
+			return nil
+		}
+		return fmt.Errorf("graphql: fragment %s is already registered", name)
+	}
+	if r.fragments == nil {
+		r.fragments = make(map[string]registeredFragment)
+	}
+	r.fragments[name] = registeredFragment{body: body, spreads: defs[0].spreads}
+	return nil
+}
+
+// fragmentRegistry holds the fragments registered with a client.
+type fragmentRegistry struct {
+	mu        sync.RWMutex
+	fragments map[string]registeredFragment
+}
+
+// clone returns a copy of the registry.
+func (r *fragmentRegistry) clone() *fragmentRegistry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	clone := &fragmentRegistry{fragments: make(map[string]registeredFragment, len(r.fragments))}
+	for name, fragment := range r.fragments {
+		clone.fragments[name] = fragment
+	}
+	return clone
+}
+
+// registeredFragment is a fragment registered with RegisterFragment.
+type registeredFragment struct {
+	body    string
+	spreads []string
+}
+
+// UseFragment appends the fragment registered with the client with the
+// given name to the document of the request when it is run, for
+// fragments that the query does not spread itself. Fragments the query
+// spreads are appended without it.
+func (req *Request) UseFragment(name string) {
+	req.fragments = append(req.fragments, name)
+}
+
+// composeQuery returns the query of req with the registered fragments it
+// uses appended, skipping any the query defines itself.
+func (c *Client) composeQuery(req *Request) (string, error) {
+	c.fragments.mu.RLock()
+	defer c.fragments.mu.RUnlock()
+	if len(c.fragments.fragments) == 0 && len(req.fragments) == 0 {
+		return req.q, nil
+	}
+	defs, err := parseDefinitions(req.q)
+	if err != nil {
+		// Leave documents that cannot be parsed for the server to reject
+		return req.q, nil
+	}
+	defined := make(map[string]bool)
+	var queue []string
+	for _, def := range defs {
+		if def.keyword == "fragment" {
+			defined[def.name] = true
+		}
+		queue = append(queue, def.spreads...)
+	}
+	explicit := make(map[string]bool)
+	for _, name := range req.fragments {
+		explicit[name] = true
+	}
+	queue = append(req.fragments[:len(req.fragments):len(req.fragments)], queue...)
+	q := req.q
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if defined[name] {
+			continue
+		}
+		fragment, ok := c.fragments.fragments[name]
+		if !ok {
+			if explicit[name] {
+				return "", fmt.Errorf("graphql: fragment %s is not registered", name)
+			}
+			// Leave fragments defined elsewhere for the server to reject
+			continue
+		}
+		defined[name] = true
+		q += "\n\n" + fragment.body
+		queue = append(queue, fragment.spreads...)
+	}
+	return q, nil
+}
@@ -0,0 +1,53 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestRegisterFragment(t *testing.T) {
+	is := is.New(t)
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query string
+		}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		query = body.Query
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	is.NoErr(client.RegisterFragment("UserFields", "on User { id name avatar { ...AvatarFields } }"))
+	is.NoErr(client.RegisterFragment("AvatarFields", "fragment AvatarFields on Avatar { url }"))
+	is.NoErr(client.RegisterFragment("Extra", "on User { email }"))
+	is.NoErr(client.RegisterFragment("Extra", "on User { email }")) // registering the same fragment again is fine
+	is.Equal(client.RegisterFragment("Extra", "on User { phone }").Error(), "graphql: fragment Extra is already registered")
+	is.Equal(client.RegisterFragment("Other", "fragment Extra on User { phone }").Error(), `graphql: "fragment Extra on User { phone }" is not the definition of fragment Other`)
+
+	req := NewRequest("query { user { ...UserFields } }")
+	is.NoErr(client.Run(ctx, req, nil))
+	is.Equal(query, "query { user { ...UserFields } }\n\nfragment UserFields on User { id name avatar { ...AvatarFields } }\n\nfragment AvatarFields on Avatar { url }")
+	is.Equal(req.Query(), "query { user { ...UserFields } }") // req is not modified
+
+	// fragments the query defines are not duplicated
+	req = NewRequest("query { user { ...UserFields } } fragment AvatarFields on Avatar { big: url }")
+	req.UseFragment("Extra")
+	is.NoErr(client.Run(ctx, req, nil))
+	is.Equal(query, "query { user { ...UserFields } } fragment AvatarFields on Avatar { big: url }\n\nfragment Extra on User { email }\n\nfragment UserFields on User { id name avatar { ...AvatarFields } }")
+
+	req = NewRequest("query { user { id } }")
+	req.UseFragment("Missing")
+	is.Equal(client.Run(ctx, req, nil).Error(), "graphql: fragment Missing is not registered")
+}
@@ -23,6 +23,13 @@
 //	    log.Fatal(err)
 //	}
 //
+// # Unix domain sockets
+//
+// Servers listening on a unix domain socket can be reached by giving the
+// path of the socket, followed by the HTTP path to request:
+//
+//	client := graphql.NewClient("unix:///var/run/graphql.sock:/query")
+//
 // # Specify client
 //
 // To specify your own http.Client, use the WithHTTPClient option:
@@ -32,16 +39,26 @@
 package graphql
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
+)
 
-	"github.com/pkg/errors"
+const (
+	mediaTypeJSON            = "application/json"
+	mediaTypeGraphQLResponse = "application/graphql-response+json"
 )
 
 // Client is a client for interacting with a GraphQL API.
@@ -52,6 +69,152 @@ type Client struct {
 
 	useMultipartRequestSpec bool
 
+	// useGET sends queries as HTTP GET requests
+	useGET bool
+
+	// useRawBody sends the bare query document as an application/graphql body
+	useRawBody bool
+
+	// strictDecoding rejects response data with unknown fields
+	strictDecoding bool
+
+	// requireData treats responses with no data and no errors as failures
+	requireData bool
+
+	// maxResponseBytes limits the size of response bodies
+	maxResponseBytes int64
+
+	// dataDecoder decodes the data of responses instead of encoding/json
+	dataDecoder func(data []byte, v interface{}) error
+
+	// scalars decodes the custom scalars of response data
+	scalars *scalarRegistry
+
+	// varEncoder encodes the custom scalars of variables
+	varEncoder *varEncoder
+
+	// defaultVars are sent with every request
+	defaultVars map[string]interface{}
+
+	// header holds the headers sent with every request
+	header http.Header
+
+	// fragments are the fragments registered with RegisterFragment
+	fragments *fragmentRegistry
+
+	// useGraphQLResponseJSON accepts application/graphql-response+json responses
+	useGraphQLResponseJSON bool
+
+	// useIncrementalDelivery accepts multipart incremental delivery responses
+	useIncrementalDelivery bool
+
+	// addTypename adds __typename to the selection sets of every query
+	addTypename bool
+
+	// validateVariables checks the variables of requests before sending
+	validateVariables bool
+
+	// minify strips comments and whitespace from the documents sent
+	minify bool
+
+	// inlineVariables writes the variables into the documents sent
+	inlineVariables bool
+
+	// sockets maps the synthetic addresses of unix socket endpoints to
+	// the path of the socket
+	sockets map[string]string
+
+	// transportOptions modify the transport of the http.Client
+	transportOptions []func(*http.Transport)
+
+	// dnsTTL is how long resolved addresses are cached for, using
+	// dnsResolver to resolve them
+	dnsTTL      time.Duration
+	dnsResolver *net.Resolver
+
+	// transportErr is returned by every request if the transport could
+	// not be configured
+	transportErr error
+
+	// replicas lists the endpoints sharing the load with the primary
+	// endpoint, failover lists the backup endpoints, and endpoints
+	// tracks the health of all of them
+	replicas         []string
+	loadBalancing    LoadBalancing
+	failover         []string
+	failoverCooldown time.Duration
+	endpoints        *endpointPool
+	// onStateChange is called when an endpoint goes down or back up
+	onStateChange func(change StateChange)
+
+	// hedgeDelay is how long to wait before hedging a query
+	hedgeDelay time.Duration
+
+	// classifier decides whether errors are retriable, and
+	// retriableCodes are the codes of retriable GraphQL errors
+	classifier     func(err error) bool
+	retriableCodes map[string]bool
+
+	// defaultTimeout bounds calls to Run with no other deadline
+	defaultTimeout time.Duration
+
+	// faults are the synthetic failures injected into requests
+	faults *FaultInjection
+
+	// clock tells the time and waits for retries, limits and cooldowns
+	clock Clock
+
+	// duplicates detects mutations run again within a window
+	duplicates *duplicateTracker
+
+	// persistedQueries sends automatic persisted queries
+	persistedQueries *persistedQueries
+	// manifest holds the IDs of the operations persisted on the server,
+	// and requirePersisted refuses to send other operations
+	manifest         PersistedManifest
+	requirePersisted bool
+
+	// batcher coalesces the queries run within a window into batches
+	batcher *batcher
+
+	// inflight tracks the queries in flight, to send identical queries
+	// once
+	inflight *inflightGroup
+
+	// cache caches the data of query responses
+	cache *responseCache
+	// normalized caches the objects of responses by their keys
+	normalized *NormalizedCache
+
+	// retries is how many times transient failures of queries are
+	// retried, waiting for backoff before the first retry
+	retries int
+	backoff time.Duration
+	// backoffStrategy decides the delays between retries in place of
+	// the exponential backoff starting at backoff
+	backoffStrategy Backoff
+	// retryBudget limits retries to a fraction of requests
+	retryBudget *retryBudget
+
+	// rateLimiter limits the rate of requests, and concurrencyLimiter the
+	// number in flight. Requests over the limits fail rather than wait if
+	// rejectOverLimit is set.
+	rateLimiter        *rateLimiter
+	concurrencyLimiter concurrencyLimiter
+	rejectOverLimit    bool
+	// adaptiveRateLimit adapts the rate limiter to the rate limit the
+	// server reports
+	adaptiveRateLimit bool
+
+	// costs keeps the last rate limit reported by the server, which
+	// requests wait on if costThrottling is set
+	costs          *costTracker
+	costThrottling bool
+
+	// schema is the schema introspected by Client.Schema, shared by the
+	// clients made with Client.With
+	schema *schemaCache
+
 	// closeReq will close the request body immediately allowing for reuse of client
 	closeReq bool
 
@@ -64,8 +227,13 @@ type Client struct {
 // NewClient makes a new Client capable of making GraphQL requests.
 func NewClient(endpoint string, opts ...ClientOption) *Client {
 	c := &Client{
-		endpoint: endpoint,
-		Log:      func(string) {},
+		endpoint:         endpoint,
+		failoverCooldown: 30 * time.Second,
+		fragments:        &fragmentRegistry{},
+		costs:            &costTracker{},
+		schema:           newSchemaCache(),
+		clock:            realClock{},
+		Log:              func(string) {},
 	}
 	for _, optionFunc := range opts {
 		optionFunc(c)
@@ -73,77 +241,633 @@ func NewClient(endpoint string, opts ...ClientOption) *Client {
 	if c.httpClient == nil {
 		c.httpClient = http.DefaultClient
 	}
+	if c.adaptiveRateLimit && c.rateLimiter == nil {
+		c.rateLimiter = newRateLimiter(0, 1)
+	}
+	c.endpoint = c.resolveEndpoint(c.endpoint)
+	c.endpoints = c.newEndpointPool()
+	c.configureTransport()
 	return c
 }
 
+// NewClientE makes a new Client like NewClient, but returns an error if
+// an endpoint is not a valid http, https or unix socket URL, or the
+// options conflict, rather than failing every request made.
+func NewClientE(endpoint string, opts ...ClientOption) (*Client, error) {
+	c := NewClient(endpoint, opts...)
+	var errs []error
+	for _, endpoint := range append([]string{endpoint}, append(c.replicas, c.failover...)...) {
+		if err := validateEndpoint(endpoint); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	var transports []string
+	for _, option := range []struct {
+		name string
+		set  bool
+	}{
+		{"UseMultipartForm", c.useMultipartForm},
+		{"UseMultipartRequestSpec", c.useMultipartRequestSpec},
+		{"UseGET", c.useGET},
+		{"UseRawBody", c.useRawBody},
+	} {
+		if option.set {
+			transports = append(transports, option.name)
+		}
+	}
+	// GET requests fall back to a raw body for other operations, but are
+	// never used with the multipart options
+	if len(transports) > 1 && !(len(transports) == 2 && c.useGET && c.useRawBody) {
+		errs = append(errs, fmt.Errorf("graphql: conflicting options %s", strings.Join(transports, ", ")))
+	}
+	if c.transportErr != nil {
+		errs = append(errs, c.transportErr)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// With returns a copy of the client with opts applied, such as to send
+// different headers, or use a different transport, for some requests
+// without building a new client. The copy shares the connections, and
+// the health of the endpoints, of the client unless opts change them.
+// The fragments and scalars registered with the client are copied.
+//
+//	admin := client.With(graphql.WithHeader("Authorization", "Bearer "+token))
+func (c *Client) With(opts ...ClientOption) *Client {
+	clone := *c
+	clone.header = c.header.Clone()
+	if c.defaultVars != nil {
+		clone.defaultVars = make(map[string]interface{}, len(c.defaultVars))
+		for key, value := range c.defaultVars {
+			clone.defaultVars[key] = value
+		}
+	}
+	clone.fragments = c.fragments.clone()
+	clone.scalars = c.scalars.clone()
+	clone.varEncoder = c.varEncoder.clone()
+	clone.transportOptions = c.transportOptions[:len(c.transportOptions):len(c.transportOptions)]
+	clone.replicas = c.replicas[:len(c.replicas):len(c.replicas)]
+	clone.failover = c.failover[:len(c.failover):len(c.failover)]
+	for _, optionFunc := range opts {
+		optionFunc(&clone)
+	}
+	if clone.adaptiveRateLimit && clone.rateLimiter == nil {
+		clone.rateLimiter = newRateLimiter(0, 1)
+	}
+	if len(clone.replicas) != len(c.replicas) || len(clone.failover) != len(c.failover) ||
+		clone.loadBalancing != c.loadBalancing || clone.failoverCooldown != c.failoverCooldown {
+		clone.sockets = make(map[string]string, len(c.sockets))
+		for addr, socket := range c.sockets {
+			clone.sockets[addr] = socket
+		}
+		clone.endpoints = clone.newEndpointPool()
+	}
+	switch {
+	case clone.httpClient != c.httpClient:
+		// A new http.Client is configured with every transport option
+		clone.transportErr = nil
+		clone.configureTransport()
+	case len(clone.transportOptions) > len(c.transportOptions) || len(clone.sockets) > len(c.sockets):
+		// The transport of the client is already configured, so only
+		// the new options are applied to a copy of it
+		options := clone.transportOptions
+		clone.transportOptions = options[len(c.transportOptions):]
+		clone.configureTransport()
+		clone.transportOptions = options
+	}
+	return &clone
+}
+
+// validateEndpoint returns an error if endpoint is not a valid http, https
+// or unix socket URL.
+func validateEndpoint(endpoint string) error {
+	if socket, _, ok := parseUnixEndpoint(endpoint); ok {
+		if socket == "" {
+			return fmt.Errorf("graphql: invalid endpoint %q: no socket path", endpoint)
+		}
+		return nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("graphql: invalid endpoint %q: %w", endpoint, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("graphql: invalid endpoint %q: scheme must be http or https", endpoint)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("graphql: invalid endpoint %q: no host", endpoint)
+	}
+	return nil
+}
+
 func (c *Client) logf(format string, args ...interface{}) {
 	c.Log(fmt.Sprintf(format, args...))
 }
 
 // Run executes the query and unmarshals the response from the data field
 // into the provided response object. Pass a nil response object to skip
-// response parsing. If the request fails or the server returns an error,
-// the first error encountered will be returned.
+// response parsing. If the request fails the error is returned, and if
+// the server returns errors they are returned as Errors. Any data the
+// server returned alongside the errors is still unmarshaled into the
+// response object, use RunPartial to handle such partial results. Errors
+// that servers streaming the response send after the data, in a further
+// JSON object at the end of the body or in a GraphQL-Errors trailer
+// holding a JSON list of errors, are returned too.
 //
-// This function handles different request formats based on the client configuration:
+// This function handles different request formats based on the client configuration,
+// or the transport set with WithTransport:
 // - If files are included in the request and neither multipart form nor multipart request spec is enabled, it returns an error.
 // - If useMultipartForm is enabled, it uses runWithPostFields to send the request.
 // - If useMultipartRequestSpec is enabled, it uses runMultipartRequestSpec to send the request.
+// - If useGET is enabled and the request is a query, it uses runWithGET to send the request.
+// - If useRawBody is enabled, it uses runWithRawBody to send the request.
 // - Otherwise, it defaults to using runWithJSON to send the request.
-func (c *Client) Run(ctx context.Context, req *Request, resp interface{}) error {
+//
+// The data can instead be split across several response objects, one per
+// top-level field, by passing Into targets in place of the response object:
+//
+//	err := client.Run(ctx, req, graphql.Into("user", &user), graphql.Into("repos", &repos))
+//
+// Options such as WithTimeout, WithRetries and WithTransport change how a
+// single call is made, so that one client can serve operations with
+// different needs.
+func (c *Client) Run(ctx context.Context, req *Request, resp interface{}, opts ...RunOption) error {
+	var err error
+	if c.normalized != nil && (req.OperationType() == "query" || req.OperationType() == "mutation") &&
+		len(req.files) == 0 && req.stream == nil {
+		err = c.runNormalized(ctx, req, resp, opts)
+	} else {
+		err = c.runRemote(ctx, req, resp, opts)
+	}
+	if err == nil {
+		for _, tag := range req.invalidates {
+			c.EvictTag(tag)
+		}
+	}
+	return err
+}
+
+// runRemote makes a call to Run without the normalized cache.
+func (c *Client) runRemote(ctx context.Context, req *Request, resp interface{}, opts []RunOption) error {
+	if ttl, ok := c.cacheTTL(req); ok {
+		return c.runCached(ctx, req, resp, opts, ttl)
+	}
+	return c.runUncached(ctx, req, resp, opts)
+}
+
+// runUncached makes a call to Run without the response cache.
+func (c *Client) runUncached(ctx context.Context, req *Request, resp interface{}, opts []RunOption) error {
+	if c.sharesInflight(req, resp, opts) {
+		return c.runShared(ctx, req, resp)
+	}
+	if c.duplicates == nil || req.OperationType() != "mutation" {
+		return c.runCall(ctx, req, resp, opts)
+	}
+	forget, err := c.duplicates.check(c, req, c.clock.Now())
+	if err != nil {
+		return err
+	}
+	if err := c.runCall(ctx, req, resp, opts); err != nil {
+		forget()
+		return err
+	}
+	return nil
+}
+
+// runCall makes a call to Run, retrying it as needed.
+func (c *Client) runCall(ctx context.Context, req *Request, resp interface{}, opts []RunOption) error {
+	settings, err := applyRunOptions(resp, opts)
+	if err != nil {
+		return err
+	}
+	timeout := settings.timeout
+	if timeout <= 0 {
+		timeout = req.timeout
+	}
+	if timeout <= 0 && c.defaultTimeout > 0 {
+		if _, ok := ctx.Deadline(); !ok {
+			timeout = c.defaultTimeout
+		}
+	}
+	settings.idempotencyKey = req.idempotencyKey
+	if settings.idempotencyKey == "" && req.OperationType() == "mutation" {
+		if settings.idempotencyKey, err = newIdempotencyKey(); err != nil {
+			return err
+		}
+	}
+	runCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	policy := c.retryPolicy(req, settings.retries)
+	if c.retryBudget != nil {
+		c.retryBudget.deposit()
+	}
+	var files *fileRewinder
+	if policy.retries > 0 && req.resendable() {
+		files = newFileRewinder(req)
+	}
+	var delay time.Duration
+	for attempt := 1; ; attempt++ {
+		start := c.clock.Now()
+		err = c.run(runCtx, req, settings)
+		if c.retryBudget != nil {
+			c.retryBudget.observe(c.clock.Now().Sub(start))
+		}
+		if err != nil && timeout > 0 && runCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return &TimeoutError{Timeout: timeout, Err: err}
+		}
+		if err == nil || attempt > policy.retries || !req.resendable() || !c.IsRetriable(err) {
+			return err
+		}
+		if err := files.rewind(err); err != nil {
+			return err
+		}
+		delay = policy.delay(attempt, delay, err)
+		if c.retryBudget != nil && !c.retryBudget.withdraw(runCtx, c.clock.Now(), delay) {
+			c.logf(">> not retrying, over retry budget: %v", err)
+			return err
+		}
+		c.logf(">> retrying in %v after: %v", delay, err)
+		if !sleep(runCtx, c.clock, delay) {
+			return err
+		}
+	}
+}
+
+// run makes a single attempt at running req.
+func (c *Client) run(ctx context.Context, req *Request, settings *runSettings) error {
+	newResponse := func() *graphResponse {
+		return &graphResponse{
+			Data:      settings.data,
+			stream:    req.stream,
+			onRawData: req.onRawData,
+			result:    settings.result,
+
+			parseRateLimit: req.onRateLimit != nil || c.costThrottling,
+		}
+	}
+	transport := settings.transport
+	if transport == defaultTransport {
+		transport = c.transport()
+	}
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
 	default:
 	}
-	if len(req.files) > 0 && !(c.useMultipartForm || c.useMultipartRequestSpec) {
+	if c.transportErr != nil {
+		return c.transportErr
+	}
+	if len(req.files) > 0 && transport != MultipartForm && transport != MultipartRequestSpec {
 		return errors.New("cannot send files with PostFields option")
 	}
-	if c.useMultipartForm {
-		return c.runWithPostFields(ctx, req, resp)
+	byID := transport == JSON || transport == GET && (req.OperationType() == "query" || !c.useRawBody)
+	var persistedID string
+	if c.manifest != nil || c.requirePersisted {
+		name, id, ok := c.persistedID(req)
+		if c.requirePersisted && (!ok || !byID) {
+			return &NotPersistedError{Operation: name}
+		}
+		if ok && byID {
+			persistedID = id
+		}
+	}
+	if c.batcher != nil && transport == JSON && persistedID == "" && c.persistedQueries == nil &&
+		settings.result == nil && req.stream == nil && len(req.files) == 0 && req.OperationType() == "query" {
+		return c.batcher.do(ctx, c, req, settings.data)
+	}
+	release, err := c.admit(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	ex, err := c.prepare(req)
+	if err != nil {
+		return err
+	}
+	ex.idempotencyKey = settings.idempotencyKey
+	if persistedID != "" {
+		ex.persistedQuery = &persistedQuery{Version: 1, Sha256Hash: persistedID}
+		ex.omitQuery = true
+		c.logf(">> sending persisted operation %s", persistedID)
+	} else if c.persistedQueries != nil && byID {
+		return c.runPersisted(ctx, ex, transport, newResponse)
+	}
+	return c.dispatch(ctx, ex, transport, newResponse())
+}
+
+// admit waits for the limits of the client to allow sending a request,
+// returning a function to call once the response has been handled.
+func (c *Client) admit(ctx context.Context) (release func(), err error) {
+	release = func() {}
+	if c.concurrencyLimiter != nil {
+		if err := c.concurrencyLimiter.acquire(ctx, c.rejectOverLimit); err != nil {
+			return nil, err
+		}
+		release = c.concurrencyLimiter.release
+	}
+	if c.rateLimiter != nil {
+		if err := c.rateLimiter.wait(ctx, c.clock, c.rejectOverLimit); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	if c.costThrottling {
+		if err := c.costs.wait(ctx, c.clock, c.rejectOverLimit); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	return release, nil
+}
+
+// prepare returns the execution of req, with the registered fragments it
+// uses added to its query and its variables encoded, without modifying
+// req.
+func (c *Client) prepare(req *Request) (*execution, error) {
+	q, err := c.composeQuery(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.directives) > 0 || len(req.fieldDirectives) > 0 {
+		if q, err = addDirectives(q, req.operationName, req.directives, req.fieldDirectives); err != nil {
+			return nil, err
+		}
+	}
+	if c.addTypename {
+		q = addTypename(q)
+	}
+	vars := c.requestVars(req)
+	if c.validateVariables {
+		if err := c.validateVars(req, vars); err != nil {
+			return nil, err
+		}
+	}
+	vars, err = c.encodeVars(vars)
+	if err != nil {
+		return nil, err
+	}
+	if c.inlineVariables {
+		if q, err = inlineVariables(q, req.operationName, vars); err != nil {
+			return nil, err
+		}
+		vars = nil
+	}
+	logged := q
+	if c.minify {
+		q = minify(q)
+	}
+	if q != req.q || c.varEncoder != nil || len(c.defaultVars) > 0 || c.inlineVariables {
+		composed := *req
+		composed.q, composed.vars = q, vars
+		if q != req.q {
+			composed.hash = &documentHash{}
+		}
+		req = &composed
 	}
-	if c.useMultipartRequestSpec {
-		return c.runMultipartRequestSpec(ctx, req, resp)
+	return &execution{Request: req, logged: logged}, nil
+}
+
+// dispatch sends the execution ex with transport.
+func (c *Client) dispatch(ctx context.Context, ex *execution, transport Transport, gr *graphResponse) error {
+	switch transport {
+	case MultipartForm:
+		return c.runWithPostFields(ctx, ex, gr)
+	case MultipartRequestSpec:
+		return c.runMultipartRequestSpec(ctx, ex, gr)
+	case GET:
+		if ex.OperationType() == "query" {
+			return c.runWithGET(ctx, ex, gr)
+		}
+		// Other operations are sent in the body, as the raw document
+		// if the client sends raw bodies
+		if c.useRawBody {
+			return c.runWithRawBody(ctx, ex, gr)
+		}
+	case RawBody:
+		return c.runWithRawBody(ctx, ex, gr)
 	}
-	return c.runWithJSON(ctx, req, resp)
+	return c.runWithJSON(ctx, ex, gr)
 }
 
-func (c *Client) runWithJSON(ctx context.Context, req *Request, resp interface{}) error {
+// transport returns the transport the client sends requests with.
+func (c *Client) transport() Transport {
+	switch {
+	case c.useMultipartForm:
+		return MultipartForm
+	case c.useMultipartRequestSpec:
+		return MultipartRequestSpec
+	case c.useGET:
+		return GET
+	case c.useRawBody:
+		return RawBody
+	}
+	return JSON
+}
+
+// execution is a single run of a request, holding the HTTP request built
+// for it. Run never modifies the Request, so that one Request can be run
+// several times, including by several goroutines at once.
+type execution struct {
+	*Request
+
+	// logged is the query logged, which is the query before minifying
+	logged string
+	// idempotencyKey is sent in the Idempotency-Key header
+	idempotencyKey string
+	// persistedQuery is sent in the extensions of the request, for
+	// automatic persisted queries, with the query unless omitQuery is set
+	persistedQuery *persistedQuery
+	omitQuery      bool
+
+	body        []byte
+	contentType string
+	method      string
+	params      url.Values
+}
+
+// RunPartial executes the query like Run, but returns the errors
+// returned by the server separately from failures. GraphQL servers
+// return as much data as they can when resolving some fields fails, so
+// the response object holds the data for the fields that did resolve,
+// and errs describes the fields that did not. err is only non-nil if the
+// request failed, in which case there is no data.
+func (c *Client) RunPartial(ctx context.Context, req *Request, resp interface{}, opts ...RunOption) (errs Errors, err error) {
+	err = c.Run(ctx, req, resp, opts...)
+	if errs, ok := err.(Errors); ok {
+		return errs, nil
+	}
+	return nil, err
+}
+
+// RunOption configures a single call to Run.
+type RunOption interface {
+	applyRun(*runSettings)
+}
+
+// runSettings holds the configuration of a single call to Run.
+type runSettings struct {
+	// data is the value the response data is decoded into
+	data    interface{}
+	targets targets
+	// result collects the details of the response for RunResult
+	result *Result
+	// timeout bounds the whole call, including retries
+	timeout time.Duration
+	// retries is how many times transient failures of queries are
+	// retried, or -1 to retry as the client does
+	retries int
+	// transport overrides the transport of the client
+	transport Transport
+	// idempotencyKey is sent with every attempt
+	idempotencyKey string
+}
+
+// applyRunOptions applies opts to the settings of a call to Run decoding
+// the response data into resp.
+func applyRunOptions(resp interface{}, opts []RunOption) (*runSettings, error) {
+	settings := &runSettings{data: resp, retries: -1}
+	if target, ok := resp.(*Target); ok {
+		target.applyRun(settings)
+		resp = nil
+	}
+	for _, opt := range opts {
+		opt.applyRun(settings)
+	}
+	if len(settings.targets) == 0 {
+		return settings, nil
+	}
+	if resp != nil {
+		return nil, errors.New("graphql: cannot decode into both a response object and Into targets")
+	}
+	settings.data = settings.targets
+	return settings, nil
+}
+
+func (c *Client) runWithGET(ctx context.Context, req *execution, gr *graphResponse) error {
+	params := url.Values{}
+	if !req.omitQuery {
+		params.Set("query", req.q)
+	}
+	if req.operationName != "" {
+		params.Set("operationName", req.operationName)
+	}
+	if req.persistedQuery != nil {
+		extensions, err := json.Marshal(map[string]interface{}{"persistedQuery": req.persistedQuery})
+		if err != nil {
+			return fmt.Errorf("failed to encode extensions: %w", err)
+		}
+		params.Set("extensions", string(extensions))
+	}
+
+	// Encode the variables as JSON if there are any
+	if len(req.vars) > 0 {
+		variables, err := json.Marshal(req.vars)
+		if err != nil {
+			return fmt.Errorf("failed to encode variables: %w", err)
+		}
+		params.Set("variables", string(variables))
+	}
+
+	// Log the request details
+	c.logf(">> variables: %v", req.vars)
+	c.logf(">> query: %s", req.logged)
+
+	// Set the query parameters, GET requests have no body
+	req.method = http.MethodGet
+	req.params = params
+	req.contentType = ""
+
+	// Make the HTTP request
+	return c.makeRequest(ctx, req, gr)
+}
+
+func (c *Client) runWithRawBody(ctx context.Context, req *execution, gr *graphResponse) error {
+	// The body carries the bare document, so any variables are sent
+	// as a query parameter
+	params := url.Values{}
+	if len(req.vars) > 0 {
+		variables, err := json.Marshal(req.vars)
+		if err != nil {
+			return fmt.Errorf("failed to encode variables: %w", err)
+		}
+		params.Set("variables", string(variables))
+	}
+	if req.operationName != "" {
+		params.Set("operationName", req.operationName)
+	}
+
+	// Log the request details
+	c.logf(">> variables: %v", req.vars)
+	c.logf(">> query: %s", req.logged)
+
+	// Set the request body and content type
+	req.body = []byte(req.q)
+	req.params = params
+	req.contentType = "application/graphql; charset=utf-8"
+
+	// Make the HTTP request
+	return c.makeRequest(ctx, req, gr)
+}
+
+func (c *Client) runWithJSON(ctx context.Context, req *execution, gr *graphResponse) error {
 	var requestBody bytes.Buffer
 
 	// Prepare the request body object
 	requestBodyObj := struct {
-		Query     string                 `json:"query"`
-		Variables map[string]interface{} `json:"variables"`
+		Query         *string                `json:"query,omitempty"`
+		OperationName string                 `json:"operationName,omitempty"`
+		Variables     map[string]interface{} `json:"variables"`
+		Extensions    map[string]interface{} `json:"extensions,omitempty"`
 	}{
-		Query:     req.q,
-		Variables: req.vars,
+		Query:         &req.q,
+		OperationName: req.operationName,
+		Variables:     req.vars,
+	}
+	if req.omitQuery {
+		requestBodyObj.Query = nil
+	}
+	if req.persistedQuery != nil {
+		requestBodyObj.Extensions = map[string]interface{}{"persistedQuery": req.persistedQuery}
 	}
 
 	// Encode the request body to JSON
 	if err := json.NewEncoder(&requestBody).Encode(requestBodyObj); err != nil {
-		return errors.Wrap(err, "failed to encode request body")
+		return fmt.Errorf("failed to encode request body: %w", err)
 	}
 
 	// Log the request details
 	c.logf(">> variables: %v", req.vars)
-	c.logf(">> query: %s", req.q)
+	c.logf(">> query: %s", req.logged)
 
 	// Set the request body and content type
-	req.body = requestBody
+	req.body = requestBody.Bytes()
 	req.contentType = "application/json; charset=utf-8"
 
 	// Make the HTTP request
-	return c.makeRequest(ctx, req, resp)
+	return c.makeRequest(ctx, req, gr)
 }
 
-func (c *Client) runWithPostFields(ctx context.Context, req *Request, resp interface{}) error {
+func (c *Client) runWithPostFields(ctx context.Context, req *execution, gr *graphResponse) error {
 	var requestBody bytes.Buffer
 	writer := multipart.NewWriter(&requestBody)
 
 	// Write the query field
 	if err := writer.WriteField("query", req.q); err != nil {
-		return errors.Wrap(err, "failed to write query field")
+		return fmt.Errorf("failed to write query field: %w", err)
+	}
+
+	// Write the operation name field if there is one
+	if req.operationName != "" {
+		if err := writer.WriteField("operationName", req.operationName); err != nil {
+			return fmt.Errorf("failed to write operation name field: %w", err)
+		}
 	}
 
 	// Write the variables field if there are any
@@ -151,10 +875,10 @@ func (c *Client) runWithPostFields(ctx context.Context, req *Request, resp inter
 	if len(req.vars) > 0 {
 		variablesField, err := writer.CreateFormField("variables")
 		if err != nil {
-			return errors.Wrap(err, "failed to create variables field")
+			return fmt.Errorf("failed to create variables field: %w", err)
 		}
 		if err := json.NewEncoder(io.MultiWriter(variablesField, &variablesBuf)).Encode(req.vars); err != nil {
-			return errors.Wrap(err, "failed to encode variables")
+			return fmt.Errorf("failed to encode variables: %w", err)
 		}
 	}
 
@@ -162,149 +886,403 @@ func (c *Client) runWithPostFields(ctx context.Context, req *Request, resp inter
 	for _, file := range req.files {
 		part, err := writer.CreateFormFile(file.Field, file.Name)
 		if err != nil {
-			return errors.Wrap(err, "failed to create form file")
+			return fmt.Errorf("failed to create form file: %w", err)
 		}
-		if _, err := io.Copy(part, file.R); err != nil {
-			return errors.Wrap(err, "failed to copy file content")
+		if err := copyFile(ctx, part, file.R); err != nil {
+			return err
 		}
 	}
 
 	// Close the multipart writer to finalize the request body
 	if err := writer.Close(); err != nil {
-		return errors.Wrap(err, "failed to close writer")
+		return fmt.Errorf("failed to close writer: %w", err)
 	}
 
 	// Log the request details
 	c.logf(">> variables: %s", variablesBuf.String())
 	c.logf(">> files: %v", req.files)
-	c.logf(">> query: %s", req.q)
+	c.logf(">> query: %s", req.logged)
 
 	// Set the request body and content type
-	req.body = requestBody
+	req.body = requestBody.Bytes()
 	req.contentType = writer.FormDataContentType()
 
 	// Make the HTTP request
-	return c.makeRequest(ctx, req, resp)
+	return c.makeRequest(ctx, req, gr)
+}
+
+func (c *Client) runMultipartRequestSpec(ctx context.Context, req *execution, gr *graphResponse) error {
+	// Prepare the operations and map fields for the multipart request
+	multipartRequestSpecQuery := req.fillMultipartRequestSpecQuery()
+	body, contentType, err := c.multipartRequestSpecBody(ctx, multipartRequestSpecQuery.Operations,
+		multipartRequestSpecQuery.Map, multipartRequestSpecQuery.files(req.files))
+	if err != nil {
+		return err
+	}
+
+	// Set the request body and content type
+	req.body, req.contentType = body, contentType
+
+	// Make the HTTP request
+	return c.makeRequest(ctx, req, gr)
+}
+
+// multipartFile is a file sent in a multipart request under key.
+type multipartFile struct {
+	key  string
+	name string
+	r    io.Reader
+}
+
+// files returns the files sent for the operation of the query, which are
+// files, under their fields, then the Upload variables.
+func (query *multipartRequestSpecQuery) files(files []File) []multipartFile {
+	parts := make([]multipartFile, 0, len(files)+len(query.uploads))
+	for _, file := range files {
+		parts = append(parts, multipartFile{key: file.Field, name: file.Name, r: file.R})
+	}
+	// The Upload variables, in the order of their keys
+	for i := 0; i < len(query.uploads); i++ {
+		key := "upload" + strconv.Itoa(i)
+		upload := query.uploads[key]
+		parts = append(parts, multipartFile{key: key, name: upload.Name, r: upload.R})
+	}
+	return parts
 }
 
-func (c *Client) runMultipartRequestSpec(ctx context.Context, req *Request, resp interface{}) error {
+// multipartRequestSpecBody returns the body of a request following the
+// multipart request specification, with the operations and map fields,
+// and the files, and its content type.
+func (c *Client) multipartRequestSpecBody(ctx context.Context, operations interface{}, fileMap interface{}, files []multipartFile) ([]byte, string, error) {
 	var requestBody bytes.Buffer
 	writer := multipart.NewWriter(&requestBody)
 
-	// Prepare the operations and map fields for the multipart request
-	multipartRequestSpecQuery := req.fillMultipartRequestSpecQuery()
-	operations, err := json.Marshal(multipartRequestSpecQuery.Operations)
+	ops, err := json.Marshal(operations)
 	if err != nil {
-		return errors.Wrap(err, "failed to marshal operations")
+		return nil, "", fmt.Errorf("failed to marshal operations: %w", err)
 	}
 
-	maps, err := json.Marshal(multipartRequestSpecQuery.Map)
+	maps, err := json.Marshal(fileMap)
 	if err != nil {
-		return errors.Wrap(err, "failed to marshal map")
+		return nil, "", fmt.Errorf("failed to marshal map: %w", err)
 	}
 
 	// Write the operations field
-	if err := writer.WriteField("operations", string(operations)); err != nil {
-		return errors.Wrap(err, "failed to write operations field")
+	if err := writer.WriteField("operations", string(ops)); err != nil {
+		return nil, "", fmt.Errorf("failed to write operations field: %w", err)
 	}
-	c.logf(">> field: %s = %s", "operations", string(operations))
+	c.logf(">> field: %s = %s", "operations", string(ops))
 
 	// Write the map field
 	if err := writer.WriteField("map", string(maps)); err != nil {
-		return errors.Wrap(err, "failed to write map field")
+		return nil, "", fmt.Errorf("failed to write map field: %w", err)
 	}
 	c.logf(">> field: %s = %s", "map", string(maps))
 
 	// Add the files to the multipart request
-	for _, file := range req.files {
-		part, err := writer.CreateFormFile(file.Field, file.Name)
+	for _, file := range files {
+		part, err := writer.CreateFormFile(file.key, file.name)
 		if err != nil {
-			return errors.Wrap(err, "failed to create form file")
+			return nil, "", fmt.Errorf("failed to create form file: %w", err)
 		}
-		if _, err := io.Copy(part, file.R); err != nil {
-			return errors.Wrap(err, "failed to copy file content")
+		if err := copyFile(ctx, part, file.r); err != nil {
+			return nil, "", err
 		}
-		c.logf(">> file: %s = %s", file.Field, file.Name)
+		c.logf(">> file: %s = %s", file.key, file.name)
 	}
 
 	// Close the multipart writer to finalize the request body
 	if err := writer.Close(); err != nil {
-		return errors.Wrap(err, "failed to close writer")
+		return nil, "", fmt.Errorf("failed to close writer: %w", err)
 	}
+	return requestBody.Bytes(), writer.FormDataContentType(), nil
+}
 
-	// Set the request body and content type
-	req.body = requestBody
-	req.contentType = writer.FormDataContentType()
+// copyFile copies the content of a file into the body of a request,
+// stopping as soon as ctx is done, in which case the error of ctx is
+// returned.
+func copyFile(ctx context.Context, dst io.Writer, r io.Reader) error {
+	if _, err := io.Copy(dst, &contextReader{ctx: ctx, r: r}); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to copy file content: %w", err)
+	}
+	return nil
+}
 
-	// Make the HTTP request
-	return c.makeRequest(ctx, req, resp)
+// contextReader is a reader failing with the error of ctx once it is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
 }
 
-func (c *Client) makeRequest(ctx context.Context, req *Request, resp interface{}) error {
-	gr := &graphResponse{
-		Data: resp,
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
 	}
+	return r.r.Read(p)
+}
 
-	// Create the HTTP request
-	r, err := http.NewRequest(http.MethodPost, c.endpoint, &req.body)
+func (c *Client) makeRequest(ctx context.Context, req *execution, gr *graphResponse) error {
+	// Send the request
+	res, err := c.sendHedged(ctx, req)
 	if err != nil {
-		return err
+		return &TransportError{Err: err}
 	}
-	r.Close = c.closeReq
-	r.Header.Set("Content-Type", req.contentType)
-	r.Header.Set("Accept", "application/json; charset=utf-8")
+	defer res.Body.Close()
 
-	// Set additional headers from the request
-	for key, values := range req.Header {
-		for _, value := range values {
-			r.Header.Add(key, value)
-		}
+	// Decode the response into graphResponse straight from the body,
+	// keeping the start of the body for logging and errors
+	head := &headBuffer{max: maxErrorBody}
+	var raw bytes.Buffer
+	var r io.Reader = res.Body
+	if c.maxResponseBytes > 0 {
+		r = &maxBytesReader{r: r, remaining: c.maxResponseBytes, limit: c.maxResponseBytes}
 	}
+	r = io.TeeReader(r, head)
+	if req.onRawResponse != nil {
+		r = io.TeeReader(r, &raw)
+	}
+	body := &readRecorder{r: r}
+	decodeErr := c.decodeResponse(res, bufio.NewReader(body), gr)
 
-	// Log the request headers
-	c.logf(">> headers: %v", r.Header)
+	// Read the rest of the body, so any trailers are available
+	if _, err := io.Copy(io.Discard, body); err != nil && decodeErr == nil {
+		decodeErr = err
+	}
 
-	// Attach context to the request
-	r = r.WithContext(ctx)
+	// Log the response body
+	c.logf("<< %s", head)
 
-	// Send the request
-	res, err := c.httpClient.Do(r)
-	if err != nil {
-		return err
+	// Pass the raw body to the request callback
+	if req.onRawResponse != nil && body.err == nil {
+		req.onRawResponse(raw.Bytes())
 	}
-	defer res.Body.Close()
 
-	// Read the response body
-	var buf bytes.Buffer
-	if _, err := io.Copy(&buf, res.Body); err != nil {
-		return errors.Wrap(err, "failed to read response body")
+	// Pass the response to the request callback, now that the body has
+	// been read and any trailers are available
+	if req.onHTTPResponse != nil {
+		req.onHTTPResponse(res)
 	}
 
-	// Log the response body
-	c.logf("<< %s", buf.String())
+	// Record the rate limit reported with the response, including with
+	// responses rejecting the request for exceeding it
+	if limit, ok := responseRateLimit(res.Header, gr.Extensions, gr.rateLimit, c.clock.Now()); ok {
+		c.costs.observe(limit, c.clock.Now())
+		if c.adaptiveRateLimit {
+			c.rateLimiter.adapt(limit, c.clock.Now())
+		}
+		if req.onRateLimit != nil {
+			req.onRateLimit(limit)
+		}
+	}
+
+	// Record the response for RunResult
+	if gr.result != nil {
+		gr.result.StatusCode = res.StatusCode
+		gr.result.Header = res.Header
+		gr.result.Trailer = res.Trailer
+		gr.result.Extensions = gr.Extensions
+	}
 
-	// Decode the response into graphResponse
-	if err := json.NewDecoder(&buf).Decode(&gr); err != nil {
+	if decodeErr != nil {
+		var tooLarge *ResponseTooLargeError
+		if errors.As(body.err, &tooLarge) {
+			return tooLarge
+		}
 		if res.StatusCode != http.StatusOK {
-			return fmt.Errorf("graphql: server returned a non-200 status code: %v", res.StatusCode)
+			return newHTTPError(res, head.Bytes())
+		}
+		if body.err != nil {
+			return &TransportError{Err: fmt.Errorf("failed to read response body: %w", body.err)}
+		}
+		if _, ok := decodeErr.(*ContentTypeError); ok {
+			return decodeErr
+		}
+		if isMalformed(decodeErr) {
+			return newMalformedResponseError(res, head.Bytes(), decodeErr)
 		}
-		return errors.Wrap(err, "failed to decode response")
+		return &DecodeError{Err: decodeErr}
 	}
 
-	// Return the first error if any
+	// Add any errors the server sent after the body
+	gr.Errors = append(gr.Errors, trailerErrors(res.Trailer)...)
+
+	// Pass the extensions to the request callback
+	if req.onExtensions != nil && gr.Extensions != nil {
+		req.onExtensions(gr.Extensions)
+	}
+
+	// Pass any deprecation warnings to the request callback
+	if req.onWarning != nil {
+		for _, warning := range responseWarnings(res.Header, gr.Extensions) {
+			req.onWarning(warning)
+		}
+	}
+
+	// Return the errors if any
 	if len(gr.Errors) > 0 {
-		return gr.Errors[0]
+		return gr.Errors
+	}
+
+	// A non-2xx status code indicates a failed request, even if the body
+	// carries no errors
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return newHTTPError(res, head.Bytes())
+	}
+
+	if c.requireData && !gr.hasData {
+		return ErrNoData
 	}
 
 	return nil
 }
 
+// acceptHeader returns the Accept header listing the response media types
+// supported by the client.
+func (c *Client) acceptHeader() string {
+	accept := mediaTypeJSON + "; charset=utf-8"
+	if c.useGraphQLResponseJSON {
+		accept = mediaTypeGraphQLResponse + ", " + mediaTypeJSON + ";q=0.9"
+	}
+	if c.useIncrementalDelivery {
+		accept = "multipart/mixed;deferSpec=20220824, " + accept
+	}
+	return accept
+}
+
+// newHTTPRequest creates the http.Request sending req to endpoint, adding
+// any query parameters to the endpoint URL. Requests are sent using POST
+// unless another method has been set.
+func (c *Client) newHTTPRequest(ctx context.Context, req *execution, endpoint string) (*http.Request, error) {
+	if len(req.params) > 0 {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse endpoint: %w", err)
+		}
+		params := u.Query()
+		for key, values := range req.params {
+			params[key] = values
+		}
+		u.RawQuery = params.Encode()
+		endpoint = u.String()
+	}
+	var body io.Reader
+	method := http.MethodGet
+	if req.method != http.MethodGet {
+		method = http.MethodPost
+		body = bytes.NewReader(req.body)
+	}
+	r, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	r.Close = c.closeReq
+	if req.contentType != "" {
+		r.Header.Set("Content-Type", req.contentType)
+	}
+	r.Header.Set("Accept", c.acceptHeader())
+
+	// Set the default headers of the client, which headers of the same
+	// name on the request replace
+	for key, values := range c.header {
+		if _, ok := req.Header[key]; ok {
+			continue
+		}
+		r.Header[key] = append([]string(nil), values...)
+	}
+
+	// Set additional headers from the request
+	for key, values := range req.Header {
+		for _, value := range values {
+			r.Header.Add(key, value)
+		}
+	}
+
+	if req.idempotencyKey != "" && r.Header.Get(idempotencyKeyHeader) == "" {
+		r.Header.Set(idempotencyKeyHeader, req.idempotencyKey)
+	}
+
+	// Log the request headers
+	c.logf(">> headers: %v", r.Header)
+
+	// Attach context to the request
+	return r.WithContext(ctx), nil
+}
+
+// requestHeader returns the headers set on the HTTP request for req by the
+// client, which are its default headers, replaced by the headers of the
+// same name on the request, and the headers of the request.
+func (c *Client) requestHeader(req *Request) http.Header {
+	header := make(http.Header, len(c.header)+len(req.Header))
+	for key, values := range c.header {
+		if _, ok := req.Header[key]; !ok {
+			header[key] = values
+		}
+	}
+	for key, values := range req.Header {
+		header[key] = values
+	}
+	return header
+}
+
 type multipartRequestSpecQuery struct {
 	Operations struct {
-		Query     string      `json:"query"`
-		Variables interface{} `json:"variables"`
+		Query         string      `json:"query"`
+		OperationName string      `json:"operationName,omitempty"`
+		Variables     interface{} `json:"variables"`
 	} `json:"operations"`
 	Map map[string][]string `json:"map"`
+
+	// uploads are the Upload variables, sent as the files of the map
+	// keys they are listed under
+	uploads map[string]Upload
+}
+
+// extractUploads returns value with the Upload values within it replaced
+// by nil, adding each to the uploads and map of the query under the path
+// of the value.
+func (query *multipartRequestSpecQuery) extractUploads(value interface{}, path string) interface{} {
+	switch v := value.(type) {
+	case Upload:
+		key := "upload" + strconv.Itoa(len(query.uploads))
+		if query.uploads == nil {
+			query.uploads = make(map[string]Upload)
+		}
+		query.uploads[key] = v
+		query.Map[key] = []string{path}
+		return nil
+	case *Upload:
+		if v == nil {
+			return nil
+		}
+		return query.extractUploads(*v, path)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fields := make(map[string]interface{}, len(v))
+		for _, key := range keys {
+			fields[key] = query.extractUploads(v[key], path+"."+key)
+		}
+		return fields
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = query.extractUploads(item, path+"."+strconv.Itoa(i))
+		}
+		return items
+	case []Upload:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = query.extractUploads(item, path+"."+strconv.Itoa(i))
+		}
+		return items
+	}
+	return value
 }
 
 func (req *Request) fillMultipartRequestSpecQuery() multipartRequestSpecQuery {
@@ -316,6 +1294,7 @@ func (req *Request) fillMultipartRequestSpecQuery() multipartRequestSpecQuery {
 
 	// Set the query in the operations
 	query.Operations.Query = req.Query()
+	query.Operations.OperationName = req.operationName
 	query.Map = make(map[string][]string)
 
 	// Populate the map with file fields and their corresponding variable paths
@@ -328,13 +1307,19 @@ func (req *Request) fillMultipartRequestSpecQuery() multipartRequestSpecQuery {
 		variables["files"] = files
 	}
 
-	// Merge existing vars into Variables
+	// Merge existing vars into Variables, replacing any uploads with
+	// null and mapping them to the files sent for them
 	if req.vars != nil {
-		for key, value := range req.vars {
+		keys := make([]string, 0, len(req.vars))
+		for key := range req.vars {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys) // number the uploads in a stable order
+		for _, key := range keys {
 			if key == "files" {
 				continue // Avoid overwriting the "files" key
 			}
-			variables[key] = value
+			variables[key] = query.extractUploads(req.vars[key], "variables."+key)
 		}
 	}
 
@@ -375,6 +1360,144 @@ func UseMultipartRequestSpec() ClientOption {
 	}
 }
 
+// UseGET sends queries as HTTP GET requests, with the query and variables
+// encoded as URL query parameters, so that responses can be cached by CDNs
+// and other HTTP caches. Mutations and subscriptions are still sent with POST.
+func UseGET() ClientOption {
+	return func(client *Client) {
+		client.useGET = true
+	}
+}
+
+// UseRawBody sends requests as the bare query document with the
+// application/graphql content type, rather than wrapping it in a JSON
+// envelope, for servers that accept it. Variables are sent as a JSON
+// encoded query parameter.
+func UseRawBody() ClientOption {
+	return func(client *Client) {
+		client.useRawBody = true
+	}
+}
+
+// UseGraphQLResponseJSON accepts responses using the
+// application/graphql-response+json media type defined by the
+// GraphQL over HTTP specification, falling back to application/json
+// for servers that do not support it.
+// https://graphql.github.io/graphql-over-http/draft/#sec-application-graphql-response-json
+func UseGraphQLResponseJSON() ClientOption {
+	return func(client *Client) {
+		client.useGraphQLResponseJSON = true
+	}
+}
+
+// UseIncrementalDelivery accepts multipart/mixed incremental delivery
+// responses, which servers use to send the results of @defer and @stream
+// directives. Run waits for every payload and merges them before decoding
+// the response.
+func UseIncrementalDelivery() ClientOption {
+	return func(client *Client) {
+		client.useIncrementalDelivery = true
+	}
+}
+
+// UseStrictDecoding makes Run return a DecodeError when the data of a
+// response has fields that are not in the response object, so that drift
+// between the response types of the client and the schema of the server
+// is caught, for example in tests.
+func UseStrictDecoding() ClientOption {
+	return func(client *Client) {
+		client.strictDecoding = true
+	}
+}
+
+// WithHeader sets a header sent with every request, such as
+// Authorization or User-Agent. A header of the same name set on a
+// request replaces it.
+func WithHeader(key, value string) ClientOption {
+	return func(client *Client) {
+		if client.header == nil {
+			client.header = make(http.Header)
+		}
+		client.header.Set(key, value)
+	}
+}
+
+// WithHeaders sets headers sent with every request, like WithHeader.
+func WithHeaders(header http.Header) ClientOption {
+	return func(client *Client) {
+		if client.header == nil {
+			client.header = make(http.Header)
+		}
+		for key, values := range header {
+			client.header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+		}
+	}
+}
+
+// WithDefaultVars sets variables sent with every request, such as a
+// tenant ID, locale or API version. Variables set on a request override
+// the defaults of the same name.
+func WithDefaultVars(vars map[string]interface{}) ClientOption {
+	return func(client *Client) {
+		if client.defaultVars == nil {
+			client.defaultVars = make(map[string]interface{}, len(vars))
+		}
+		for key, value := range vars {
+			client.defaultVars[key] = value
+		}
+	}
+}
+
+// requestVars returns the variables of req merged over the default
+// variables of the client.
+func (c *Client) requestVars(req *Request) map[string]interface{} {
+	if len(c.defaultVars) == 0 {
+		return req.vars
+	}
+	vars := make(map[string]interface{}, len(c.defaultVars)+len(req.vars))
+	for key, value := range c.defaultVars {
+		vars[key] = value
+	}
+	for key, value := range req.vars {
+		vars[key] = value
+	}
+	return vars
+}
+
+// RequireData makes Run return ErrNoData when a response has neither
+// data nor errors, such as {} or {"data":null}, which some servers return
+// with a 200 status code, instead of leaving the response object
+// untouched.
+func RequireData() ClientOption {
+	return func(client *Client) {
+		client.requireData = true
+	}
+}
+
+// WithDataDecoder sets the function used to decode the data field of
+// responses into the response object, instead of encoding/json. JSON
+// decoders with the same signature as json.Unmarshal can be used as they
+// are, such as json-iterator or sonic:
+//
+//	NewClient(endpoint, WithDataDecoder(sonic.Unmarshal))
+//
+// UseStrictDecoding has no effect on a custom decoder.
+func WithDataDecoder(decode func(data []byte, v interface{}) error) ClientOption {
+	return func(client *Client) {
+		client.dataDecoder = decode
+	}
+}
+
+// WithMaxResponseBytes limits the size of response bodies to n bytes.
+// Reading a larger body is abandoned and Run returns a
+// ResponseTooLargeError, protecting against unbounded memory use when a
+// server misbehaves.
+func WithMaxResponseBytes(n int64) ClientOption {
+	return func(client *Client) {
+		client.maxResponseBytes = n
+	}
+}
+
 // ImmediatelyCloseReqBody will close the req body immediately after each request body is ready
 func ImmediatelyCloseReqBody() ClientOption {
 	return func(client *Client) {
@@ -386,31 +1509,79 @@ func ImmediatelyCloseReqBody() ClientOption {
 // modify the behaviour of the Client.
 type ClientOption func(*Client)
 
-type graphErr struct {
-	Message string
-}
+type graphResponse struct {
+	Data       interface{}
+	Errors     Errors
+	Extensions map[string]interface{}
 
-func (e graphErr) Error() string {
-	return "graphql: " + e.Message
-}
+	// stream receives the elements of a field of the data one at a time
+	stream *fieldStream
 
-type graphResponse struct {
-	Data   interface{}
-	Errors []graphErr
+	// onRawData receives the data before it is decoded
+	onRawData func(data json.RawMessage)
+
+	// result collects the details of the response for RunResult
+	result *Result
+
+	// hasData is whether the response has data, which is only tracked
+	// when the client requires data
+	hasData bool
+
+	// rateLimit is the rateLimit field of the data, which is only
+	// decoded if parseRateLimit is set
+	parseRateLimit bool
+	rateLimit      *RateLimit
 }
 
 // Request is a GraphQL request.
 type Request struct {
-	q     string
-	vars  map[string]interface{}
-	files []File
+	q             string
+	operationName string
+	vars          map[string]interface{}
+	files         []File
+	fragments     []string
 
 	// Header represent any request headers that will be set
 	// when the request is made.
 	Header http.Header
 
-	body        bytes.Buffer
-	contentType string
+	onExtensions   func(extensions map[string]interface{})
+	onHTTPResponse func(res *http.Response)
+	stream         *fieldStream
+	onRawData      func(data json.RawMessage)
+	onRawResponse  func(body []byte)
+	onWarning      func(warning Warning)
+	onRateLimit    func(limit RateLimit)
+
+	// timeout bounds every run of the request
+	timeout time.Duration
+
+	// directives are added to the operation, and fieldDirectives to
+	// its fields, when it is sent
+	directives      []string
+	fieldDirectives []fieldDirective
+
+	// idempotencyKey is sent in the Idempotency-Key header
+	idempotencyKey string
+	// idempotent is whether the request is safe to send more than once
+	idempotent bool
+
+	// retry overrides the retry policy of the client
+	retry *retryPolicy
+	// noDeduplication sends the request even if an identical query is in
+	// flight
+	noDeduplication bool
+	// cacheTTL is how long the response is cached, zero for the TTL of
+	// the client, or less to not cache it
+	cacheTTL time.Duration
+	// cacheTags tag the cached response, and invalidates are the tags
+	// evicted once the request succeeds
+	cacheTags   []string
+	invalidates []string
+
+	// hash is the hash of q, shared by clones of the request, or nil to
+	// hash q every time
+	hash *documentHash
 }
 
 // NewRequest makes a new Request with the specified string.
@@ -418,12 +1589,74 @@ func NewRequest(q string) *Request {
 	req := &Request{
 		q:      q,
 		Header: make(map[string][]string),
+		hash:   &documentHash{},
 	}
 	return req
 }
 
-// Var sets a variable.
+// Clone returns a copy of the request, which can be modified without
+// affecting req, such as to set the variables of a single call on a base
+// request holding the query, common variables and headers. The values of
+// the variables and the readers of the files are shared.
+func (req *Request) Clone() *Request {
+	clone := &Request{
+		q:               req.q,
+		operationName:   req.operationName,
+		files:           append([]File(nil), req.files...),
+		fragments:       append([]string(nil), req.fragments...),
+		Header:          req.Header.Clone(),
+		onExtensions:    req.onExtensions,
+		onHTTPResponse:  req.onHTTPResponse,
+		stream:          req.stream,
+		onRawData:       req.onRawData,
+		onRawResponse:   req.onRawResponse,
+		onWarning:       req.onWarning,
+		onRateLimit:     req.onRateLimit,
+		timeout:         req.timeout,
+		directives:      append([]string(nil), req.directives...),
+		fieldDirectives: append([]fieldDirective(nil), req.fieldDirectives...),
+		idempotencyKey:  req.idempotencyKey,
+		idempotent:      req.idempotent,
+		retry:           req.retry,
+		noDeduplication: req.noDeduplication,
+		cacheTTL:        req.cacheTTL,
+		cacheTags:       append([]string(nil), req.cacheTags...),
+		invalidates:     append([]string(nil), req.invalidates...),
+		hash:            req.hash,
+	}
+	if clone.Header == nil {
+		clone.Header = make(http.Header)
+	}
+	if req.vars != nil {
+		clone.vars = make(map[string]interface{}, len(req.vars))
+		for key, value := range req.vars {
+			clone.vars[key] = value
+		}
+	}
+	return clone
+}
+
+// NewRequestFrom returns a new request for the client from the template,
+// which is cloned so that it can be reused safely, including by several
+// goroutines at once:
+//
+//	base := graphql.NewRequest(query)
+//	base.Var("tenant", tenant)
+//	base.Header.Set("X-Team", team)
+//
+//	req := client.NewRequestFrom(base)
+//	req.Var("id", id)
+func (c *Client) NewRequestFrom(template *Request) *Request {
+	return template.Clone()
+}
+
+// Var sets a variable. Setting a variable to an omitted Optional removes
+// it, and setting it to nil or Null sends it as null.
 func (req *Request) Var(key string, value interface{}) {
+	if isOmitted(value) {
+		delete(req.vars, key)
+		return
+	}
 	if req.vars == nil {
 		req.vars = make(map[string]interface{})
 	}
@@ -445,6 +1678,64 @@ func (req *Request) Query() string {
 	return req.q
 }
 
+// OperationName sets the name of the operation in the query document to
+// execute, which servers require when the document contains several
+// operations, and which gateways and APM tools use to identify requests.
+// It is sent as operationName with every request format.
+func (req *Request) OperationName(name string) {
+	req.operationName = name
+}
+
+// Timeout bounds every run of the request by d, independently of the
+// context it is run with, including any retries. Running the request
+// fails with a TimeoutError when it takes longer. The WithTimeout option
+// of a call takes precedence.
+func (req *Request) Timeout(d time.Duration) {
+	req.timeout = d
+}
+
+// WithDefaultTimeout bounds calls to Run whose context has no deadline,
+// and whose request and options set no timeout, by d, including any
+// retries, so that a request made without a deadline by mistake cannot
+// hang forever. The call fails with a TimeoutError when it takes longer.
+func WithDefaultTimeout(d time.Duration) ClientOption {
+	return func(client *Client) {
+		client.defaultTimeout = d
+	}
+}
+
+// OnExtensions sets a function called with the extensions of the response,
+// which servers use for information such as tracing, query cost and
+// request IDs. It is called before Run returns, if the response has
+// extensions, including when the response also has errors.
+func (req *Request) OnExtensions(fn func(extensions map[string]interface{})) {
+	req.onExtensions = fn
+}
+
+// OnHTTPResponse sets a function called with the HTTP response to the
+// request, to read its status code, headers and trailers, for example to
+// track rate limits or log request IDs. It is called once the body has
+// been read, so the body must not be used, whatever the status code of
+// the response.
+func (req *Request) OnHTTPResponse(fn func(res *http.Response)) {
+	req.onHTTPResponse = fn
+}
+
+// OnRawData sets a function called with the data field of the response
+// as raw JSON, for forwarding it verbatim, in addition to decoding it into
+// the response object, which may be nil. It has no effect on requests
+// using Stream.
+func (req *Request) OnRawData(fn func(data json.RawMessage)) {
+	req.onRawData = fn
+}
+
+// OnRawResponse sets a function called with the whole body of the
+// response, whatever its content type or status code, in addition to
+// decoding it.
+func (req *Request) OnRawResponse(fn func(body []byte)) {
+	req.onRawResponse = fn
+}
+
 // File sets a file to upload.
 // Files are only supported with a Client that was created with
 // the UseMultipartForm option.
@@ -462,3 +1753,161 @@ type File struct {
 	Name  string
 	R     io.Reader
 }
+
+// operationType returns the type of the first operation in the query
+// document: "query", "mutation" or "subscription". Fragment definitions
+// are skipped, and documents using the shorthand { ... } syntax are
+// queries.
+func operationType(q string) string {
+	typ, _ := findOperation(q, "")
+	return typ
+}
+
+// OperationType returns the type of the operation the request executes:
+// "query", "mutation" or "subscription". The operation is the one named
+// with OperationName, or the first operation of the document, and the
+// type is an empty string if the document has no such operation. Only
+// queries are sent with GET, and only queries and requests marked with
+// MarkIdempotent are retried, hedged or failed over after the server may
+// have received them, since sending other operations twice may not be
+// safe.
+func (req *Request) OperationType() string {
+	typ, _ := findOperation(req.q, req.operationName)
+	return typ
+}
+
+// findOperation returns the type of the operation with the given name in
+// the query document, or of the first operation if name is empty, and the
+// offset of its keyword, which is -1 for documents using the shorthand
+// { ... } syntax. typ is empty if there is no operation with the name.
+func findOperation(q, name string) (typ string, offset int) {
+	for i := 0; i < len(q); {
+		i = skipIgnored(q, i)
+		if i >= len(q) {
+			break
+		}
+		if q[i] == '{' {
+			if name == "" {
+				return "query", -1
+			}
+			i = skipDefinition(q, i)
+			continue
+		}
+		start := i
+		i = skipName(q, i)
+		switch keyword := q[start:i]; keyword {
+		case "query", "mutation", "subscription":
+			if name == "" {
+				return keyword, start
+			}
+			nameStart := skipIgnored(q, i)
+			if q[nameStart:skipName(q, nameStart)] == name {
+				return keyword, start
+			}
+			i = skipDefinition(q, i)
+		case "fragment":
+			i = skipDefinition(q, i)
+		case "":
+			// Skip unexpected punctuation
+			i++
+		}
+	}
+	if name != "" {
+		return "", -1
+	}
+	return "query", -1
+}
+
+// skipName returns the index just past the name starting at i, which is i
+// if there is none.
+func skipName(q string, i int) int {
+	for i < len(q) && isNameChar(q[i]) {
+		i++
+	}
+	return i
+}
+
+// skipIgnored returns the index of the next token in q at or after i,
+// skipping whitespace, commas, comments and byte order marks.
+func skipIgnored(q string, i int) int {
+	for i < len(q) {
+		switch q[i] {
+		case ' ', '\t', '\r', '\n', ',':
+			i++
+		case '#':
+			for i < len(q) && q[i] != '\n' && q[i] != '\r' {
+				i++
+			}
+		default:
+			if !strings.HasPrefix(q[i:], "\ufeff") {
+				return i
+			}
+			i += len("\ufeff")
+		}
+	}
+	return i
+}
+
+// skipDefinition returns the index just past the selection set of the
+// definition continuing at i. Braces in strings, comments and arguments
+// before the selection set do not count.
+func skipDefinition(q string, i int) int {
+	var depth, parens int
+	for i < len(q) {
+		switch q[i] {
+		case '#':
+			i = skipIgnored(q, i)
+			continue
+		case '"':
+			i = skipString(q, i)
+			continue
+		case '(':
+			parens++
+		case ')':
+			parens--
+		case '{':
+			if depth > 0 || parens == 0 {
+				depth++
+			}
+		case '}':
+			if depth > 0 {
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		i++
+	}
+	return i
+}
+
+// skipString returns the index just past the string or block string
+// starting at i.
+func skipString(q string, i int) int {
+	if strings.HasPrefix(q[i:], `"""`) {
+		for i += 3; i < len(q); i++ {
+			if strings.HasPrefix(q[i:], `\"""`) {
+				i += 3
+				continue
+			}
+			if strings.HasPrefix(q[i:], `"""`) {
+				return i + 3
+			}
+		}
+		return i
+	}
+	for i++; i < len(q); i++ {
+		switch q[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return i
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
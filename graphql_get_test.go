@@ -0,0 +1,137 @@
+package graphql
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestDoGET(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.Method, http.MethodGet)
+		is.Equal(r.Header.Get("Content-Type"), "")
+		is.Equal(r.URL.Query().Get("query"), "query {}")
+		is.Equal(r.URL.Query().Get("variables"), `{"username":"matryer"}`)
+		is.Equal(r.URL.Query().Get("key"), "abc") // endpoint query parameters are kept
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL+"?key=abc", UseGET())
+
+	req := NewRequest("query {}")
+	req.Var("username", "matryer")
+
+	var resp struct {
+		Value string
+	}
+	err := client.Run(ctx, req, &resp)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+	is.Equal(resp.Value, "some data")
+}
+
+func TestDoGETMutation(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.Method, http.MethodPost)
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `{"query":"mutation { save }","variables":null}`+"\n")
+		io.WriteString(w, `{"data":{"save":true}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, UseGET())
+	err := client.Run(ctx, NewRequest("mutation { save }"), nil)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+}
+
+func TestDoGETFragmentMutation(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.Method, http.MethodPost)
+		io.WriteString(w, `{"data":{"save":{"id":"1"}}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, UseGET())
+	err := client.Run(ctx, NewRequest(`fragment F on T { id } mutation { save { ...F } }`), nil)
+	is.NoErr(err)
+}
+
+func TestOperationType(t *testing.T) {
+	is := is.New(t)
+	is.Equal(operationType(`{ items }`), "query")
+	is.Equal(operationType(`query Items { items }`), "query")
+	is.Equal(operationType("# comment\n  mutation { save }"), "mutation")
+	is.Equal(operationType(`subscription { changed }`), "subscription")
+	is.Equal(operationType(`mutationish`), "query")
+	is.Equal(operationType(`fragment F on T { id } mutation { save { ...F } }`), "mutation")
+	is.Equal(operationType(`fragment F on T @dir(arg: {a: "}"}) { a { b } } subscription { changed { ...F } }`), "subscription")
+	is.Equal(operationType(`fragment F on T { id(s: """ } mutation """) } query { ...F }`), "query")
+
+	req := NewRequest(`query A { a } mutation B { b } subscription C { c }`)
+	is.Equal(req.OperationType(), "query")
+	req.OperationName("B")
+	is.Equal(req.OperationType(), "mutation")
+	req.OperationName("C")
+	is.Equal(req.OperationType(), "subscription")
+	req.OperationName("D")
+	is.Equal(req.OperationType(), "")
+}
+
+func TestDoGETOperationName(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.Method, http.MethodGet)
+		is.Equal(r.URL.Query().Get("operationName"), "B")
+		io.WriteString(w, `{"data":{"b":true}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	req := NewRequest("query A { a } query B { b }")
+	req.OperationName("B")
+	err := NewClient(srv.URL, UseGET()).Run(ctx, req, nil)
+	is.NoErr(err)
+}
+
+func TestDoGETOperationNameMutation(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.Method, http.MethodPost) // the selected operation is a mutation
+		io.WriteString(w, `{"data":{"save":true}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	req := NewRequest("query A { a } mutation Save { save }")
+	req.OperationName("Save")
+	err := NewClient(srv.URL, UseGET()).Run(ctx, req, nil)
+	is.NoErr(err)
+}
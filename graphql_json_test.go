@@ -2,9 +2,12 @@ package graphql
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -158,3 +161,260 @@ func TestHeader(t *testing.T) {
 
 	is.Equal(resp.Value, "some data")
 }
+
+func TestDoJSONGraphQLResponse(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.Header.Get("Accept"), "application/graphql-response+json, application/json;q=0.9")
+		w.Header().Set("Content-Type", "application/graphql-response+json; charset=utf-8")
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, UseGraphQLResponseJSON())
+	var resp struct {
+		Value string
+	}
+	err := client.Run(ctx, NewRequest("query {}"), &resp)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+	is.Equal(resp.Value, "some data")
+}
+
+func TestDoJSONGraphQLResponseStatus(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/graphql-response+json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, `{}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, UseGraphQLResponseJSON())
+	err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.Equal(err.Error(), "graphql: server returned a non-200 status code: 503")
+}
+
+func TestDoJSONStatusWithoutErrors(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		io.WriteString(w, `{}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.Equal(err.Error(), "graphql: server returned a non-200 status code: 502")
+}
+
+func TestDoJSONOperationName(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `{"query":"query A { a } query B { b }","operationName":"B","variables":null}`+"\n")
+		io.WriteString(w, `{"data":{"b":true}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	req := NewRequest("query A { a } query B { b }")
+	req.OperationName("B")
+	err := NewClient(srv.URL).Run(ctx, req, nil)
+	is.NoErr(err)
+}
+
+func TestClientHeaders(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.Header.Get("Authorization"), "Bearer request")
+		is.Equal(r.Header.Get("User-Agent"), "app/1.0")
+		is.Equal(r.Header.Values("X-Tenant"), []string{"a", "b"})
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL,
+		WithHeader("Authorization", "Bearer client"),
+		WithHeader("User-Agent", "app/1.0"),
+		WithHeaders(http.Header{"x-tenant": {"a", "b"}}),
+	)
+	req := NewRequest("query {}")
+	req.Header.Set("Authorization", "Bearer request")
+	is.NoErr(client.Run(ctx, req, nil))
+}
+
+func TestRequestClone(t *testing.T) {
+	is := is.New(t)
+	base := NewRequest("query ($tenant: ID!, $id: ID!) { item }")
+	base.Var("tenant", "t1")
+	base.Header.Set("X-Team", "a")
+
+	req := base.Clone()
+	req.Var("id", "1")
+	req.Header.Set("X-Team", "b")
+	is.Equal(req.Query(), base.Query())
+	is.Equal(req.Vars(), map[string]interface{}{"tenant": "t1", "id": "1"})
+	is.Equal(base.Vars(), map[string]interface{}{"tenant": "t1"})
+	is.Equal(base.Header.Get("X-Team"), "a")
+	is.Equal(req.Header.Get("X-Team"), "b")
+}
+
+func TestNewRequestFrom(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(r.Header.Get("X-Team"), "a")
+		var body struct {
+			Variables map[string]string
+		}
+		is.NoErr(json.Unmarshal(b, &body))
+		is.Equal(body.Variables["tenant"], "t1")
+		io.WriteString(w, `{"data":{"id":"`+body.Variables["id"]+`"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	base := NewRequest("query ($tenant: ID!, $id: ID!) { id }")
+	base.Var("tenant", "t1")
+	base.Header.Set("X-Team", "a")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			req := client.NewRequestFrom(base)
+			req.Var("id", id)
+			var resp struct{ ID string }
+			is.NoErr(client.Run(ctx, req, &resp))
+			is.Equal(resp.ID, id)
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+	is.Equal(len(base.Vars()), 1)
+}
+
+func TestRunConcurrent(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `{"query":"query { value }","variables":{"id":"1"}}`+"\n")
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	req := NewRequest("query { value }")
+	req.Var("id", "1")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var resp struct{ Value string }
+			is.NoErr(client.Run(ctx, req, &resp))
+			is.Equal(resp.Value, "some data")
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNewClientE(t *testing.T) {
+	is := is.New(t)
+	client, err := NewClientE("https://api.example.com/graphql", WithFailover("unix:///run/api.sock:/graphql"))
+	is.NoErr(err)
+	is.True(client != nil)
+
+	_, err = NewClientE("api.example.com/graphql")
+	is.Equal(err.Error(), `graphql: invalid endpoint "api.example.com/graphql": scheme must be http or https`)
+	_, err = NewClientE("http:///graphql")
+	is.Equal(err.Error(), `graphql: invalid endpoint "http:///graphql": no host`)
+	_, err = NewClientE("unix://")
+	is.Equal(err.Error(), `graphql: invalid endpoint "unix://": no socket path`)
+	_, err = NewClientE("http://a", WithEndpoints("http://b", "ftp://c"))
+	is.Equal(err.Error(), `graphql: invalid endpoint "ftp://c": scheme must be http or https`)
+
+	_, err = NewClientE("http://a", UseMultipartForm(), UseMultipartRequestSpec())
+	is.Equal(err.Error(), "graphql: conflicting options UseMultipartForm, UseMultipartRequestSpec")
+	_, err = NewClientE("http://a", UseGET(), UseRawBody())
+	is.NoErr(err)
+
+	_, err = NewClientE("http://a", WithHTTPClient(&http.Client{Transport: roundTripperFuncMpRS(nil)}), DisableKeepAlives())
+	is.True(err != nil)
+}
+
+func TestClientWith(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		io.WriteString(w, `{"data":{"method":"`+r.Method+`","auth":"`+r.Header.Get("Authorization")+`","agent":"`+r.Header.Get("User-Agent")+`"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	type response struct {
+		Method, Auth, Agent string
+	}
+	client := NewClient(srv.URL, WithHeader("User-Agent", "app/1.0"))
+	admin := client.With(WithHeader("Authorization", "Bearer admin"), UseGET())
+	is.NoErr(admin.RegisterFragment("F", "on Query { a }"))
+
+	resp, err := Do[response](ctx, admin, NewRequest("query {}"))
+	is.NoErr(err)
+	is.Equal(resp, response{Method: http.MethodGet, Auth: "Bearer admin", Agent: "app/1.0"})
+
+	resp, err = Do[response](ctx, client, NewRequest("query {}"))
+	is.NoErr(err)
+	is.Equal(resp, response{Method: http.MethodPost, Agent: "app/1.0"}) // the client is unchanged
+	is.Equal(len(client.fragments.fragments), 0)
+}
+
+func TestClientWithEndpoints(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient("http://127.0.0.1:1")
+	err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.True(err != nil)
+
+	backup := client.With(WithFailover(srv.URL), DisableKeepAlives())
+	is.NoErr(backup.Run(ctx, NewRequest("query {}"), nil))
+	is.True(backup.httpClient != client.httpClient)
+}
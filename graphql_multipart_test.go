@@ -257,8 +257,58 @@ func TestFile(t *testing.T) {
 	is.NoErr(err)
 }
 
+// cancellingReader is an endless file that cancels the upload once it has
+// been read from.
+type cancellingReader struct {
+	cancel func()
+	reads  int
+}
+
+func (r *cancellingReader) Read(p []byte) (int, error) {
+	r.reads++
+	r.cancel()
+	return len(p), nil
+}
+
+func TestFileCancel(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer srv.Close()
+
+	for _, opt := range []ClientOption{UseMultipartForm(), UseMultipartRequestSpec()} {
+		ctx, cancel := context.WithCancel(context.Background())
+		f := &cancellingReader{cancel: cancel}
+		req := NewRequest("mutation ($avatar: Upload) { save(avatar: $avatar) }")
+		req.File("file", "endless.txt", f)
+		err := NewClient(srv.URL, opt).Run(ctx, req, nil)
+		is.Equal(err, context.Canceled)
+		is.Equal(f.reads, 1) // the copy stops as soon as the context is done
+	}
+	is.Equal(calls, 0)
+}
+
 type roundTripperFunc func(req *http.Request) (*http.Response, error)
 
 func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
 	return fn(req)
 }
+
+func TestQueryOperationName(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.FormValue("operationName"), "B")
+		io.WriteString(w, `{"data":{"b":true}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	req := NewRequest("query A { a } query B { b }")
+	req.OperationName("B")
+	err := NewClient(srv.URL, UseMultipartForm()).Run(ctx, req, nil)
+	is.NoErr(err)
+}
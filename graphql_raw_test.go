@@ -0,0 +1,80 @@
+package graphql
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestDoRawBody(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.Method, http.MethodPost)
+		is.Equal(r.Header.Get("Content-Type"), "application/graphql; charset=utf-8")
+		is.Equal(r.URL.Query().Get("variables"), `{"username":"matryer"}`)
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `query {}`)
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, UseRawBody())
+
+	req := NewRequest("query {}")
+	req.Var("username", "matryer")
+
+	var resp struct {
+		Value string
+	}
+	err := client.Run(ctx, req, &resp)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+	is.Equal(resp.Value, "some data")
+}
+
+func TestDoRawBodyNoVariables(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.URL.RawQuery, "")
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `mutation { save }`)
+		io.WriteString(w, `{"data":{"save":true}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, UseRawBody(), UseGET())
+	err := client.Run(ctx, NewRequest("mutation { save }"), nil)
+	is.NoErr(err)
+}
+
+func TestDoRawBodyOperationName(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.URL.Query().Get("operationName"), "B")
+		io.WriteString(w, `{"data":{"b":true}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	req := NewRequest("query A { a } query B { b }")
+	req.OperationName("B")
+	err := NewClient(srv.URL, UseRawBody()).Run(ctx, req, nil)
+	is.NoErr(err)
+}
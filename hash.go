@@ -0,0 +1,50 @@
+package graphql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+// documentHash is the hash of the document of a request, and of its
+// canonical form, each computed once.
+type documentHash struct {
+	once sync.Once
+	sum  string
+
+	canonicalOnce sync.Once
+	canonical     string
+}
+
+// Hash returns the hex-encoded SHA-256 hash of the query document of the
+// request, as used by automatic persisted queries. The hash is computed
+// the first time it is needed and kept, so that large documents are not
+// hashed again every time the request, or a clone of it, is run.
+func (req *Request) Hash() string {
+	if req.hash == nil {
+		return hashDocument(req.q)
+	}
+	req.hash.once.Do(func() {
+		req.hash.sum = hashDocument(req.q)
+	})
+	return req.hash.sum
+}
+
+// canonicalHash returns the hex-encoded SHA-256 hash of the document of
+// the request without its comments and insignificant whitespace and
+// commas, computed once like Hash.
+func (req *Request) canonicalHash() string {
+	if req.hash == nil {
+		return hashDocument(minify(req.q))
+	}
+	req.hash.canonicalOnce.Do(func() {
+		req.hash.canonical = hashDocument(minify(req.q))
+	})
+	return req.hash.canonical
+}
+
+// hashDocument returns the hex-encoded SHA-256 hash of q.
+func hashDocument(q string) string {
+	sum := sha256.Sum256([]byte(q))
+	return hex.EncodeToString(sum[:])
+}
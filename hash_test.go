@@ -0,0 +1,65 @@
+package graphql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestRequestHash(t *testing.T) {
+	is := is.New(t)
+	sum := sha256.Sum256([]byte("query { items }"))
+	req := NewRequest("query { items }")
+	is.Equal(req.Hash(), hex.EncodeToString(sum[:]))
+	is.Equal(req.Hash(), hex.EncodeToString(sum[:]))
+	is.Equal(req.Clone().Hash(), req.Hash())
+	is.Equal(len(req.Hash()), 64)
+	is.True(NewRequest("query { other }").Hash() != req.Hash())
+
+	// Requests made without NewRequest are hashed every time
+	var zero Request
+	is.Equal(zero.Hash(), hashDocument(""))
+}
+
+func TestRequestHashComposed(t *testing.T) {
+	is := is.New(t)
+	var hashes, queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query      string
+			Extensions struct {
+				PersistedQuery persistedQuery
+			}
+		}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		hashes = append(hashes, body.Extensions.PersistedQuery.Sha256Hash)
+		queries = append(queries, body.Query)
+		if body.Query == "" {
+			io.WriteString(w, `{"errors":[{"message":"PersistedQueryNotFound","extensions":{"code":"PERSISTED_QUERY_NOT_FOUND"}}]}`)
+			return
+		}
+		io.WriteString(w, `{"data":{"items":[]}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	// The hash sent is the hash of the document with its fragments
+	client := NewClient(srv.URL, WithPersistedQueries())
+	is.NoErr(client.RegisterFragment("F", "on Item { id }"))
+	req := NewRequest("query { items { ...F } }")
+	is.NoErr(client.Run(ctx, req, nil))
+	is.Equal(len(hashes), 2)
+	is.Equal(hashes[0], hashes[1])
+	is.Equal(hashes[1], hashDocument(queries[1]))
+	is.True(hashes[0] != req.Hash())
+}
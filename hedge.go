@@ -0,0 +1,87 @@
+package graphql
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// sendResult is the outcome of one attempt at sending a request.
+type sendResult struct {
+	attempt int
+	res     *http.Response
+	err     error
+}
+
+// sendHedged sends req, and if no response has arrived after the hedging
+// delay, sends it a second time. The first response to arrive is used and
+// the other attempt is cancelled. Only queries and requests marked
+// idempotent are hedged, since sending other operations twice may not be
+// safe.
+func (c *Client) sendHedged(ctx context.Context, req *execution) (*http.Response, error) {
+	if c.hedgeDelay <= 0 || !req.resendable() {
+		return c.send(ctx, req)
+	}
+	results := make(chan sendResult, 2)
+	var cancels []context.CancelFunc
+	launch := func() {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		attempt := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			res, err := c.send(attemptCtx, req)
+			results <- sendResult{attempt: attempt, res: res, err: err}
+		}()
+	}
+	launch()
+	// The hedging delay is measured with the clock of the client
+	timerCtx, stopTimer := context.WithCancel(ctx)
+	defer stopTimer()
+	hedge := make(chan struct{})
+	go func() {
+		if c.clock.Sleep(timerCtx, c.hedgeDelay) {
+			close(hedge)
+		}
+	}()
+	var result sendResult
+	select {
+	case result = <-results:
+	case <-hedge:
+		c.logf(">> hedging request after %v", c.hedgeDelay)
+		launch()
+		result = <-results
+		if result.err != nil {
+			// Wait for the other attempt rather than failing early
+			cancels[result.attempt]()
+			result = <-results
+			break
+		}
+		// Cancel the losing attempt straight away, and discard its
+		// response once it returns
+		cancels[1-result.attempt]()
+		go func() {
+			if loser := <-results; loser.res != nil {
+				loser.res.Body.Close()
+			}
+		}()
+	}
+	cancel := cancels[result.attempt]
+	if result.err != nil {
+		cancel()
+		return result.res, result.err
+	}
+	// Keep the context of the winning attempt alive until its body is closed
+	result.res.Body = &releaseBody{ReadCloser: result.res.Body, release: cancel}
+	return result.res, nil
+}
+
+// WithHedging sends a second, duplicate request when no response to a
+// query has arrived after delay, and uses whichever response arrives
+// first, cancelling the other request. Setting delay to around the 95th
+// percentile latency of the server reduces tail latency at the cost of a
+// few extra requests. Mutations and subscriptions are never hedged.
+func WithHedging(delay time.Duration) ClientOption {
+	return func(client *Client) {
+		client.hedgeDelay = delay
+	}
+}
@@ -0,0 +1,97 @@
+package graphql
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestHedging(t *testing.T) {
+	is := is.New(t)
+	var calls int32
+	cancelled := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the server only notices a closed connection once the body
+		// has been read
+		io.ReadAll(r.Body)
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// the first request is slow and cancelled once the hedged
+			// request has won
+			<-r.Context().Done()
+			close(cancelled)
+			return
+		}
+		io.WriteString(w, `{"data":{"value":"hedged"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithHedging(10*time.Millisecond))
+	var resp struct {
+		Value string
+	}
+	err := client.Run(ctx, NewRequest("query {}"), &resp)
+	is.NoErr(err)
+	is.Equal(resp.Value, "hedged")
+	is.Equal(atomic.LoadInt32(&calls), int32(2))
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("slow request was not cancelled")
+	}
+}
+
+func TestHedgingClock(t *testing.T) {
+	is := is.New(t)
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		io.WriteString(w, `{"data":{"value":"hedged"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	// The delay is waited with the clock of the client, without sleeping
+	clock := newFakeClock()
+	client := NewClient(srv.URL, WithClock(clock), WithHedging(time.Hour))
+	var resp struct {
+		Value string
+	}
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), &resp))
+	is.Equal(resp.Value, "hedged")
+	is.Equal(atomic.LoadInt32(&calls), int32(2))
+	is.Equal(clock.slept, time.Hour)
+}
+
+func TestHedgingMutation(t *testing.T) {
+	is := is.New(t)
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(30 * time.Millisecond)
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithHedging(time.Millisecond))
+	err := client.Run(ctx, NewRequest("mutation { save }"), nil)
+	is.NoErr(err)
+	is.Equal(atomic.LoadInt32(&calls), int32(1))
+}
@@ -0,0 +1,43 @@
+package graphql
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// idempotencyKeyHeader is the header idempotency keys are sent in.
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyKey sets the key sent in the Idempotency-Key header of the
+// request, so that servers supporting idempotency keys can tell retries
+// of a mutation from new mutations, and execute it only once. Each call
+// to Run of a mutation without a key is given a random key, which is the
+// same for every attempt the call makes.
+func (req *Request) IdempotencyKey(key string) {
+	req.idempotencyKey = key
+}
+
+// MarkIdempotent marks the request as safe to send more than once, such
+// as a mutation that upserts a record, so that it is retried, hedged and
+// failed over like a query. Other mutations and subscriptions are never
+// sent twice.
+func (req *Request) MarkIdempotent() {
+	req.idempotent = true
+}
+
+// resendable reports whether the request is safe to send more than once,
+// because it is a query or has been marked idempotent.
+func (req *Request) resendable() bool {
+	return req.idempotent || req.OperationType() == "query"
+}
+
+// newIdempotencyKey returns a random version 4 UUID.
+func newIdempotencyKey() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("graphql: failed to generate idempotency key: %w", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
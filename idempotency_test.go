@@ -0,0 +1,76 @@
+package graphql
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	is := is.New(t)
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	is.NoErr(client.Run(ctx, NewRequest("query { a }"), nil))
+	is.Equal(keys, []string{""}) // queries are not given keys
+
+	req := NewRequest("mutation { save }")
+	is.NoErr(client.Run(ctx, req, nil))
+	is.NoErr(client.Run(ctx, req, nil))
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	is.True(uuid.MatchString(keys[1]))
+	is.True(uuid.MatchString(keys[2]))
+	is.True(keys[1] != keys[2]) // each call is a new mutation
+
+	req.IdempotencyKey("order-1")
+	is.NoErr(client.Run(ctx, req, nil))
+	is.Equal(keys[3], "order-1")
+
+	req.Header.Set("Idempotency-Key", "header")
+	is.NoErr(client.Run(ctx, req, nil))
+	is.Equal(keys[4], "header")
+}
+
+func TestMarkIdempotent(t *testing.T) {
+	is := is.New(t)
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if len(keys)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"data":{"upsert":true}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithRetry(2, time.Millisecond))
+	req := NewRequest("mutation { upsert }")
+	is.True(client.Run(ctx, req, nil) != nil)
+	is.Equal(len(keys), 1) // mutations are not retried
+
+	keys = nil
+	req.MarkIdempotent()
+	is.NoErr(client.Run(ctx, req.Clone(), nil))
+	is.Equal(len(keys), 2)
+	is.Equal(keys[0], keys[1]) // the retry has the key of the first attempt
+}
@@ -0,0 +1,121 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// inflightGroup tracks the queries in flight, so that identical queries
+// run at the same time are sent once.
+type inflightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*inflightCall
+}
+
+// inflightCall is a query in flight, whose result is shared by the calls
+// waiting for it.
+type inflightCall struct {
+	done chan struct{}
+	// data is the data of the response, and err the error of the call
+	data json.RawMessage
+	err  error
+}
+
+// NoDeduplication sends the request even if an identical query is in
+// flight, for clients created with WithInflightDeduplication.
+func (req *Request) NoDeduplication() {
+	req.noDeduplication = true
+}
+
+// sharesInflight reports whether the call to Run of req may share the
+// result of an identical query in flight.
+func (c *Client) sharesInflight(req *Request, resp interface{}, opts []RunOption) bool {
+	if c.inflight == nil || req.noDeduplication || len(opts) > 0 || len(req.files) > 0 || req.stream != nil {
+		return false
+	}
+	if _, ok := resp.(*Target); ok {
+		return false
+	}
+	return req.OperationType() == "query"
+}
+
+// requestKey returns the key identifying the query of req, which is its
+// CanonicalKey with the variables of the client, and the headers sent
+// with it, including the default headers of the client, so that clients
+// made with Client.With sending other credentials do not share responses.
+func (c *Client) requestKey(req *Request) (string, bool) {
+	vars, err := c.encodeVars(c.requestVars(req))
+	if err != nil {
+		return "", false
+	}
+	key, err := canonicalKey(req, vars, headerKey(c.requestHeader(req)))
+	return key, err == nil
+}
+
+// runShared runs the query of req, or waits for an identical query in
+// flight, decoding the data into resp.
+func (c *Client) runShared(ctx context.Context, req *Request, resp interface{}) error {
+	key, ok := c.requestKey(req)
+	if !ok {
+		return c.runCall(ctx, req, resp, nil)
+	}
+	c.inflight.mu.Lock()
+	if call, ok := c.inflight.calls[key]; ok {
+		c.inflight.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded) {
+			// The call waited for gave up, which this call need not
+			return c.runCall(ctx, req, resp, nil)
+		}
+		c.logf(">> shared the result of an identical query in flight")
+		var errs Errors
+		if call.err != nil && !errors.As(call.err, &errs) {
+			return call.err
+		}
+		if err := c.decodeData(call.data, resp); err != nil {
+			return &DecodeError{Err: err}
+		}
+		return call.err
+	}
+	call := &inflightCall{done: make(chan struct{})}
+	c.inflight.calls[key] = call
+	c.inflight.mu.Unlock()
+
+	// Capture the data of the response to share it
+	leader := req.Clone()
+	leader.onRawData = func(data json.RawMessage) {
+		call.data = data
+		if req.onRawData != nil {
+			req.onRawData(data)
+		}
+	}
+	call.err = c.runCall(ctx, leader, resp, nil)
+
+	c.inflight.mu.Lock()
+	delete(c.inflight.calls, key)
+	c.inflight.mu.Unlock()
+	close(call.done)
+	return call.err
+}
+
+// WithInflightDeduplication sends identical queries run at the same time
+// once, sharing the result between the calls to Run, which each decode
+// the data into their own response object. Queries are identical if they
+// have the same CanonicalKey, which covers their directives, and send the
+// same headers, including the default headers of the client, since the
+// queries in flight are shared with the clients made with Client.With.
+// Only the
+// functions set on the request sent, such as with OnExtensions, are
+// called. Requests with Request.NoDeduplication, and calls to Run with
+// other options, are always sent.
+func WithInflightDeduplication() ClientOption {
+	return func(client *Client) {
+		client.inflight = &inflightGroup{calls: make(map[string]*inflightCall)}
+	}
+}
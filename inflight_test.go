@@ -0,0 +1,170 @@
+package graphql
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestWithInflightDeduplication(t *testing.T) {
+	is := is.New(t)
+	var calls int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		io.WriteString(w, `{"data":{"item":"shared"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithInflightDeduplication())
+	var wg sync.WaitGroup
+	items := make([]string, 5)
+	for i := range items {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var resp struct{ Item string }
+			is.NoErr(client.Run(ctx, NewRequest("query { item }"), &resp))
+			items[i] = resp.Item
+		}(i)
+	}
+	// Wait for the first query to reach the server before releasing it
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	is.Equal(atomic.LoadInt32(&calls), int32(1))
+	is.Equal(items, []string{"shared", "shared", "shared", "shared", "shared"})
+
+	// Queries run one after another are each sent
+	is.NoErr(client.Run(ctx, NewRequest("query { item }"), nil))
+	is.Equal(atomic.LoadInt32(&calls), int32(2))
+}
+
+func TestWithInflightDeduplicationDistinct(t *testing.T) {
+	is := is.New(t)
+	var calls int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		io.WriteString(w, `{"data":{"item":"a"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithInflightDeduplication())
+	reqs := []*Request{NewRequest("query { item }"), NewRequest("query { item }"), NewRequest("query { item }")}
+	reqs[1].Header.Set("Authorization", "other")
+	reqs[2].NoDeduplication()
+	var wg sync.WaitGroup
+	for _, req := range reqs {
+		wg.Add(1)
+		go func(req *Request) {
+			defer wg.Done()
+			is.NoErr(client.Run(ctx, req, nil))
+		}(req)
+	}
+	for atomic.LoadInt32(&calls) < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	is.Equal(atomic.LoadInt32(&calls), int32(3))
+}
+
+func TestWithInflightDeduplicationClients(t *testing.T) {
+	is := is.New(t)
+	var calls int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		io.WriteString(w, `{"data":{"item":"`+r.Header.Get("Authorization")+`"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	base := NewClient(srv.URL, WithInflightDeduplication())
+	admin := base.With(WithHeader("Authorization", "admin"))
+	user := base.With(WithHeader("Authorization", "user"))
+	skipped := NewRequest("query { item }")
+	skipped.FieldDirective("item", "@include(if: false)")
+	runs := []struct {
+		client *Client
+		req    *Request
+		want   string
+	}{
+		{admin, NewRequest("query { item }"), "admin"},
+		{user, NewRequest("query { item }"), "user"},
+		{admin, skipped, "admin"},
+	}
+	var wg sync.WaitGroup
+	for _, run := range runs {
+		wg.Add(1)
+		go func(client *Client, req *Request, want string) {
+			defer wg.Done()
+			var resp struct{ Item string }
+			is.NoErr(client.Run(ctx, req, &resp))
+			is.Equal(resp.Item, want)
+		}(run.client, run.req, run.want)
+	}
+	for atomic.LoadInt32(&calls) < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	is.Equal(atomic.LoadInt32(&calls), int32(3))
+}
+
+func TestWithInflightDeduplicationErrors(t *testing.T) {
+	is := is.New(t)
+	var calls int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		io.WriteString(w, `{"data":{"item":"partial"},"errors":[{"message":"no other"}]}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithInflightDeduplication())
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var resp struct{ Item string }
+			err := client.Run(ctx, NewRequest("query { item other }"), &resp)
+			is.Equal(err.Error(), "graphql: no other")
+			is.Equal(resp.Item, "partial")
+		}()
+	}
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	is.Equal(atomic.LoadInt32(&calls), int32(1))
+}
@@ -0,0 +1,150 @@
+package graphql
+
+import "sync"
+
+// cacheIndex indexes the keys of cached responses, or of the root fields
+// of a NormalizedCache, by the labels of the requests that cached them,
+// which are their operation names and tags, to evict them by label.
+type cacheIndex struct {
+	mu   sync.Mutex
+	keys map[string]map[string]bool
+}
+
+func newCacheIndex() *cacheIndex {
+	return &cacheIndex{keys: make(map[string]map[string]bool)}
+}
+
+// add indexes key by labels.
+func (x *cacheIndex) add(labels []string, key string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, label := range labels {
+		keys, ok := x.keys[label]
+		if !ok {
+			keys = make(map[string]bool)
+			x.keys[label] = keys
+		}
+		keys[key] = true
+	}
+}
+
+// take removes the keys indexed by label from the index, returning them.
+func (x *cacheIndex) take(label string) []string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	keys := make([]string, 0, len(x.keys[label]))
+	for key := range x.keys[label] {
+		keys = append(keys, key)
+	}
+	delete(x.keys, label)
+	return keys
+}
+
+// operationLabel and tagLabel return the labels indexing the responses
+// to the operation with the given name, and to requests with the tag.
+func operationLabel(name string) string { return "operation:" + name }
+func tagLabel(tag string) string        { return "tag:" + tag }
+
+// cacheLabels returns the labels indexing the response to req.
+func cacheLabels(req *Request) []string {
+	var labels []string
+	if name := requestOperationName(req); name != "" {
+		labels = append(labels, operationLabel(name))
+	}
+	for _, tag := range req.cacheTags {
+		labels = append(labels, tagLabel(tag))
+	}
+	return labels
+}
+
+// requestOperationName returns the name of the operation of req, which
+// is the name in its document if req does not set one.
+func requestOperationName(req *Request) string {
+	if req.operationName != "" {
+		return req.operationName
+	}
+	if op, _, err := operationDefinition(req.q, ""); err == nil {
+		return op.name
+	}
+	return ""
+}
+
+// CacheTags tags the response to the request in the caches of the
+// client, so that it can be evicted with Client.EvictTag, or by running
+// a request with InvalidatesTags.
+func (req *Request) CacheTags(tags ...string) {
+	req.cacheTags = append(req.cacheTags, tags...)
+}
+
+// InvalidatesTags evicts the responses tagged with tags from the caches
+// of the client once the request succeeds, so that mutations can evict
+// the responses they make stale:
+//
+//	req := graphql.NewRequest(`mutation { renameUser(id: 1, name: "Amy") { id } }`)
+//	req.InvalidatesTags("users")
+func (req *Request) InvalidatesTags(tags ...string) {
+	req.invalidates = append(req.invalidates, tags...)
+}
+
+// EvictOperation evicts the responses to queries with the operation name
+// from the caches of the client, set with WithCache and
+// WithNormalizedCache. Only the responses cached by the client, and
+// clients made from it, are known to it.
+func (c *Client) EvictOperation(name string) {
+	c.evict(operationLabel(name))
+}
+
+// EvictTag evicts the responses to requests tagged with tag, set with
+// Request.CacheTags, from the caches of the client.
+func (c *Client) EvictTag(tag string) {
+	c.evict(tagLabel(tag))
+}
+
+// evict evicts the responses indexed by label from the caches of the
+// client.
+func (c *Client) evict(label string) {
+	if c.cache != nil {
+		for _, key := range c.cache.index.take(label) {
+			if err := c.cache.store.Delete(key); err != nil {
+				c.logf("!! failed to write cache: %v", err)
+			}
+		}
+	}
+	if c.normalized != nil {
+		c.normalized.evictLabel(label)
+	}
+}
+
+// Evict removes the object with the type and id from the cache, so that
+// queries selecting it are sent again. It reports whether the object was
+// cached.
+func (nc *NormalizedCache) Evict(typename, id string) bool {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	key := typename + ":" + id
+	_, ok := nc.entities[key]
+	delete(nc.entities, key)
+	return ok
+}
+
+// EvictOperation removes the root fields cached by queries with the
+// operation name from the cache. The objects they returned are kept.
+func (nc *NormalizedCache) EvictOperation(name string) {
+	nc.evictLabel(operationLabel(name))
+}
+
+// EvictTag removes the root fields cached by requests tagged with tag,
+// set with Request.CacheTags, from the cache.
+func (nc *NormalizedCache) EvictTag(tag string) {
+	nc.evictLabel(tagLabel(tag))
+}
+
+// evictLabel removes the root fields indexed by label.
+func (nc *NormalizedCache) evictLabel(label string) {
+	keys := nc.index.take(label)
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	for _, key := range keys {
+		delete(nc.entities[rootQuery], key)
+	}
+}
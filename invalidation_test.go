@@ -0,0 +1,124 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestCacheIndex(t *testing.T) {
+	is := is.New(t)
+	x := newCacheIndex()
+	x.add([]string{"operation:Users", "tag:users"}, "a")
+	x.add([]string{"tag:users"}, "b")
+	is.Equal(len(x.take("operation:Users")), 1)
+	is.Equal(len(x.take("operation:Users")), 0)
+	is.Equal(len(x.take("tag:users")), 2)
+}
+
+func TestCacheLabels(t *testing.T) {
+	is := is.New(t)
+	req := NewRequest("query Users { users }")
+	req.CacheTags("users", "list")
+	is.Equal(cacheLabels(req), []string{"operation:Users", "tag:users", "tag:list"})
+	is.Equal(cacheLabels(req.Clone()), []string{"operation:Users", "tag:users", "tag:list"})
+	is.Equal(cacheLabels(NewRequest("query { users }")), nil)
+}
+
+func TestEvictResponseCache(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		io.WriteString(w, `{"data":{"item":"a"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithCache(time.Minute))
+	users := func() *Request {
+		req := NewRequest("query Users { item }")
+		req.CacheTags("users")
+		return req
+	}
+	is.NoErr(client.Run(ctx, users(), nil))
+	is.NoErr(client.Run(ctx, NewRequest("query Posts { item }"), nil))
+	is.Equal(calls, 2)
+
+	client.EvictOperation("Users")
+	is.NoErr(client.Run(ctx, users(), nil))
+	is.NoErr(client.Run(ctx, NewRequest("query Posts { item }"), nil))
+	is.Equal(calls, 3)
+
+	// Clients made from the client share the index
+	client.With(WithCacheControl()).EvictTag("users")
+	is.NoErr(client.Run(ctx, users(), nil))
+	is.Equal(calls, 4)
+
+	// Tags are evicted once mutations invalidating them succeed
+	mutation := NewRequest("mutation { rename }")
+	mutation.InvalidatesTags("users")
+	is.NoErr(client.Run(ctx, mutation, nil))
+	is.NoErr(client.Run(ctx, users(), nil))
+	is.Equal(calls, 6)
+}
+
+func TestEvictNormalizedCache(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var body struct{ Query string }
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		switch body.Query {
+		case `mutation { rename { id } }`:
+			io.WriteString(w, `{"errors":[{"message":"failed"}]}`)
+		default:
+			io.WriteString(w, `{"data":{"user":{"__typename":"User","id":"1","name":"Ann"}}}`)
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	cache := NewNormalizedCache()
+	client := NewClient(srv.URL, WithNormalizedCache(cache))
+	user := func() *Request {
+		req := NewRequest(`query User { user { id name } }`)
+		req.CacheTags("user")
+		return req
+	}
+	is.NoErr(client.Run(ctx, user(), nil))
+	is.NoErr(client.Run(ctx, user(), nil))
+	is.Equal(calls, 1)
+
+	// Evicting the object leaves a dangling reference, which misses
+	is.True(cache.Evict("User", "1"))
+	is.True(!cache.Evict("User", "1"))
+	is.NoErr(client.Run(ctx, user(), nil))
+	is.Equal(calls, 2)
+
+	cache.EvictOperation("User")
+	is.NoErr(client.Run(ctx, user(), nil))
+	is.Equal(calls, 3)
+
+	// Failed mutations invalidate nothing
+	mutation := NewRequest(`mutation { rename { id } }`)
+	mutation.InvalidatesTags("user")
+	is.True(client.Run(ctx, mutation, nil) != nil)
+	is.NoErr(client.Run(ctx, user(), nil))
+	is.Equal(calls, 4)
+
+	client.EvictTag("user")
+	is.NoErr(client.Run(ctx, user(), nil))
+	is.Equal(calls, 5)
+}
@@ -0,0 +1,202 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned by clients created with WithRateLimit and
+// RejectOverLimit when a request would exceed the rate limit.
+var ErrRateLimited = errors.New("graphql: client rate limit exceeded")
+
+// ErrConcurrencyLimited is returned by clients created with
+// WithMaxConcurrency and RejectOverLimit when the maximum number of
+// requests are already in flight.
+var ErrConcurrencyLimited = errors.New("graphql: client concurrency limit exceeded")
+
+// rateLimiter is a token bucket holding up to burst tokens, refilled at
+// rate tokens per second. Each request takes a token. A zero rate does not
+// limit requests.
+type rateLimiter struct {
+	// limit is the rate set for the client, which adapting the rate to
+	// the server never exceeds, or zero if none was
+	limit float64
+	burst float64
+
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{limit: rps, rate: rps, burst: float64(burst), tokens: float64(burst)}
+}
+
+// refill adds the tokens refilled since the last refill. The limiter must
+// be locked.
+func (l *rateLimiter) refill(now time.Time) {
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+}
+
+// adapt sets the rate to spread the requests the server has left, as
+// reported by limit at now, evenly until it resets, within the rate set
+// for the client. Once the server is out of requests, the next waits for
+// the reset.
+func (l *rateLimiter) adapt(limit RateLimit, now time.Time) {
+	if limit.Reset.IsZero() {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(now)
+	l.rate = l.limit
+	until := limit.Reset.Sub(now).Seconds()
+	if until <= 0 {
+		return
+	}
+	rate := limit.Remaining / until
+	if limit.Remaining < 1 {
+		rate = 1 / until
+		if l.tokens > 0 {
+			l.tokens = 0
+		}
+	}
+	if l.limit <= 0 || rate < l.limit {
+		l.rate = rate
+	}
+}
+
+// wait takes a token, waiting with clock until one is available unless
+// reject is set,
+// in which case ErrRateLimited is returned if there is none. The error of
+// ctx is returned if it is done first, and ErrRateLimited if it would be
+// done before a token is available.
+func (l *rateLimiter) wait(ctx context.Context, clock Clock, reject bool) error {
+	l.mu.Lock()
+	if l.rate <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	l.refill(clock.Now())
+	if l.tokens >= 1 {
+		l.tokens--
+		l.mu.Unlock()
+		return nil
+	}
+	if reject {
+		l.mu.Unlock()
+		return ErrRateLimited
+	}
+	// Take the token now, so that waiting requests are queued behind each
+	// other, and wait until it has been refilled
+	delay := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	l.tokens--
+	l.mu.Unlock()
+	if deadline, ok := ctx.Deadline(); ok && deadline.Sub(clock.Now()) < delay {
+		l.release()
+		return ErrRateLimited
+	}
+	if !clock.Sleep(ctx, delay) {
+		l.release()
+		return ctx.Err()
+	}
+	return nil
+}
+
+// release returns a token taken by a request that was not sent.
+func (l *rateLimiter) release() {
+	l.mu.Lock()
+	l.tokens++
+	l.mu.Unlock()
+}
+
+// WithRateLimit limits the client to sending rps requests per second on
+// average, allowing bursts of up to burst requests, so that bursts of
+// calls to Run are smoothed out before reaching servers with strict rate
+// limits. Each attempt at a request counts, including retries. Requests
+// over the limit wait until they can be sent, or fail with
+// ErrRateLimited if the context would be done first, or straight away
+// with RejectOverLimit.
+func WithRateLimit(rps float64, burst int) ClientOption {
+	return func(client *Client) {
+		client.rateLimiter = newRateLimiter(rps, burst)
+	}
+}
+
+// concurrencyLimiter is a semaphore limiting the number of requests in
+// flight.
+type concurrencyLimiter chan struct{}
+
+// acquire takes a slot, waiting until one is free unless reject is set, in
+// which case ErrConcurrencyLimited is returned if there is none. The error
+// of ctx is returned if it is done first.
+func (l concurrencyLimiter) acquire(ctx context.Context, reject bool) error {
+	select {
+	case l <- struct{}{}:
+		return nil
+	default:
+	}
+	if reject {
+		return ErrConcurrencyLimited
+	}
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a slot taken with acquire.
+func (l concurrencyLimiter) release() {
+	<-l
+}
+
+// WithMaxConcurrency limits the client to n requests in flight at once, so
+// that worker pools sharing the client cannot flood the server. Further
+// requests wait for one to finish, or fail with ErrConcurrencyLimited with
+// RejectOverLimit. A request holds its slot for each attempt, until its
+// response has been read.
+func WithMaxConcurrency(n int) ClientOption {
+	return func(client *Client) {
+		if n < 1 {
+			n = 1
+		}
+		client.concurrencyLimiter = make(concurrencyLimiter, n)
+	}
+}
+
+// WithAdaptiveRateLimit adapts the rate at which the client sends requests
+// to the X-RateLimit-Remaining and X-RateLimit-Reset headers of responses,
+// or the rateLimit field GitHub returns, spreading the requests the server
+// has left evenly until it resets, so that long running jobs slow down
+// steadily rather than alternating between bursts and 429 responses. Once
+// the server has no requests left, the next request waits for the reset.
+// The rate never exceeds any set with WithRateLimit, and requests are not
+// limited until the server has reported its rate limit.
+func WithAdaptiveRateLimit() ClientOption {
+	return func(client *Client) {
+		client.adaptiveRateLimit = true
+	}
+}
+
+// RejectOverLimit fails requests over the limits set with WithRateLimit and
+// WithMaxConcurrency straight away, rather than waiting until they can be
+// sent.
+func RejectOverLimit() ClientOption {
+	return func(client *Client) {
+		client.rejectOverLimit = true
+	}
+}
@@ -0,0 +1,177 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestWithRateLimit(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		calls++
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithRateLimit(20, 2))
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	}
+	// The burst is sent straight away, and the rest at 20 per second
+	is.True(time.Since(start) >= 90*time.Millisecond)
+	is.Equal(calls, 4)
+
+	// Requests that cannot be sent before the deadline fail
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.True(errors.Is(err, ErrRateLimited))
+	is.Equal(calls, 4)
+}
+
+func TestRejectOverLimit(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithRateLimit(1, 1), RejectOverLimit(), WithRetry(3, time.Millisecond))
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	err := client.Run(ctx, NewRequest("query {}"), nil, WithRetries(3))
+	is.Equal(err, ErrRateLimited)
+	is.True(!client.IsRetriable(err))
+}
+
+func TestWithMaxConcurrency(t *testing.T) {
+	is := is.New(t)
+	var inFlight, maxInFlight int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithMaxConcurrency(2))
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- client.Run(ctx, NewRequest("query {}"), nil)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		is.NoErr(err)
+	}
+	is.Equal(atomic.LoadInt32(&maxInFlight), int32(2))
+}
+
+func TestWithMaxConcurrencyReject(t *testing.T) {
+	is := is.New(t)
+	started, done := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		close(started)
+		<-done
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithMaxConcurrency(1), RejectOverLimit())
+	first := make(chan error, 1)
+	go func() {
+		first <- client.Run(ctx, NewRequest("query {}"), nil)
+	}()
+	<-started
+	err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.Equal(err, ErrConcurrencyLimited)
+	close(done)
+	is.NoErr(<-first)
+
+	// Requests waiting for a slot give up when their context is done
+	client = NewClient(srv.URL, WithMaxConcurrency(1))
+	client.concurrencyLimiter <- struct{}{}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = client.Run(ctx, NewRequest("query {}"), nil)
+	is.True(errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestWithAdaptiveRateLimit(t *testing.T) {
+	is := is.New(t)
+	var remaining int
+	var reset string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-RateLimit-Reset", reset)
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	// 10 requests left for the next second paces requests 100ms apart
+	remaining, reset = 10, "1"
+	client := NewClient(srv.URL, WithAdaptiveRateLimit())
+	start := time.Now()
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	is.True(time.Since(start) < 50*time.Millisecond) // not limited before the server reports its limit
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	is.True(time.Since(start) >= 90*time.Millisecond)
+	is.True(client.rateLimiter.rate > 9 && client.rateLimiter.rate < 11)
+
+	// The rate set for the client is not exceeded
+	remaining, reset = 1000, strconv.FormatInt(time.Now().Add(2*time.Second).Unix(), 10)
+	client = NewClient(srv.URL, WithAdaptiveRateLimit(), WithRateLimit(50, 1))
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	is.Equal(client.rateLimiter.rate, float64(50))
+
+	// Once the server is out of requests, the next waits for the reset
+	remaining = 0
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	is.Equal(client.Run(ctx, NewRequest("query {}"), nil), ErrRateLimited)
+}
@@ -0,0 +1,102 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// PersistedManifest maps the names of the operations persisted on a
+// server to their IDs, which are usually the hashes of their documents.
+type PersistedManifest map[string]string
+
+// LoadPersistedManifest reads a persisted operations manifest from r. The
+// manifest is either a JSON object mapping operation names to IDs, or an
+// Apollo persisted query manifest:
+//
+//	{
+//		"format": "apollo-persisted-query-manifest",
+//		"version": 1,
+//		"operations": [{"id": "...", "name": "Items", "type": "query", "body": "..."}]
+//	}
+func LoadPersistedManifest(r io.Reader) (PersistedManifest, error) {
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("graphql: failed to decode persisted manifest: %w", err)
+	}
+	if operations, ok := raw["operations"]; ok {
+		var apollo struct {
+			Operations []struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"operations"`
+		}
+		if err := json.Unmarshal(operations, &apollo.Operations); err != nil {
+			return nil, fmt.Errorf("graphql: failed to decode persisted manifest: %w", err)
+		}
+		manifest := make(PersistedManifest, len(apollo.Operations))
+		for _, op := range apollo.Operations {
+			if op.Name == "" || op.ID == "" {
+				return nil, errors.New("graphql: persisted manifest has an operation without a name or ID")
+			}
+			manifest[op.Name] = op.ID
+		}
+		return manifest, nil
+	}
+	manifest := make(PersistedManifest, len(raw))
+	for name, value := range raw {
+		var id string
+		if err := json.Unmarshal(value, &id); err != nil {
+			return nil, fmt.Errorf("graphql: persisted manifest has a non-string ID for operation %s", name)
+		}
+		manifest[name] = id
+	}
+	return manifest, nil
+}
+
+// NotPersistedError is returned by clients requiring persisted queries,
+// with RequirePersistedQueries, for operations not in their manifest.
+type NotPersistedError struct {
+	// Operation is the name of the operation, if it has one.
+	Operation string
+}
+
+func (e *NotPersistedError) Error() string {
+	if e.Operation == "" {
+		return "graphql: cannot send unnamed operation, it is not in the persisted manifest"
+	}
+	return "graphql: cannot send operation " + e.Operation + ", it is not in the persisted manifest"
+}
+
+// persistedID returns the ID of the operation of req in the manifest of
+// the client. Operations without a name in req are looked up by the name
+// in their document.
+func (c *Client) persistedID(req *Request) (name, id string, ok bool) {
+	name = requestOperationName(req)
+	if name == "" {
+		return "", "", false
+	}
+	id, ok = c.manifest[name]
+	return name, id, ok
+}
+
+// WithPersistedManifest sends the operations in manifest by ID alone, in
+// the persistedQuery extension of the request, leaving the server to look
+// up their documents. Other operations are sent as usual, unless the
+// client also has RequirePersistedQueries. Like WithPersistedQueries,
+// operations are sent by ID with the JSON and GET transports.
+func WithPersistedManifest(manifest PersistedManifest) ClientOption {
+	return func(client *Client) {
+		client.manifest = manifest
+	}
+}
+
+// RequirePersistedQueries refuses to send operations that are not in the
+// manifest of the client, set with WithPersistedManifest, failing with a
+// NotPersistedError, for servers accepting only persisted operations.
+func RequirePersistedQueries() ClientOption {
+	return func(client *Client) {
+		client.requirePersisted = true
+	}
+}
@@ -0,0 +1,89 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestLoadPersistedManifest(t *testing.T) {
+	is := is.New(t)
+	manifest, err := LoadPersistedManifest(strings.NewReader(`{"Items":"abc","Save":"def"}`))
+	is.NoErr(err)
+	is.Equal(manifest, PersistedManifest{"Items": "abc", "Save": "def"})
+
+	manifest, err = LoadPersistedManifest(strings.NewReader(`{
+		"format": "apollo-persisted-query-manifest",
+		"version": 1,
+		"operations": [{"id": "abc", "name": "Items", "type": "query", "body": "query Items { items }"}]
+	}`))
+	is.NoErr(err)
+	is.Equal(manifest, PersistedManifest{"Items": "abc"})
+
+	_, err = LoadPersistedManifest(strings.NewReader(`{"Items":1}`))
+	is.True(err != nil)
+	_, err = LoadPersistedManifest(strings.NewReader(`{"operations":[{"id":"abc"}]}`))
+	is.True(err != nil)
+}
+
+func TestWithPersistedManifest(t *testing.T) {
+	is := is.New(t)
+	var bodies []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		bodies = append(bodies, body)
+		io.WriteString(w, `{"data":{"items":[]}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithPersistedManifest(PersistedManifest{"Items": "abc"}))
+	is.NoErr(client.Run(ctx, NewRequest("query Items { items }"), nil))
+	is.NoErr(client.Run(ctx, NewRequest("query Other { items }"), nil))
+	is.Equal(len(bodies), 2)
+	_, hasQuery := bodies[0]["query"]
+	is.True(!hasQuery)
+	is.Equal(bodies[0]["extensions"], map[string]interface{}{
+		"persistedQuery": map[string]interface{}{"version": float64(1), "sha256Hash": "abc"},
+	})
+	is.Equal(bodies[1]["query"], "query Other { items }")
+	is.Equal(bodies[1]["extensions"], nil)
+}
+
+func TestRequirePersistedQueries(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		io.WriteString(w, `{"data":{"items":[]}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithPersistedManifest(PersistedManifest{"Items": "abc"}), RequirePersistedQueries())
+	req := NewRequest("query A { items } query Items { items }")
+	req.OperationName("Items")
+	is.NoErr(client.Run(ctx, req, nil))
+
+	err := client.Run(ctx, NewRequest("query Other { items }"), nil)
+	var notPersisted *NotPersistedError
+	is.True(errors.As(err, &notPersisted))
+	is.Equal(notPersisted.Operation, "Other")
+	is.Equal(err.Error(), "graphql: cannot send operation Other, it is not in the persisted manifest")
+	err = client.Run(ctx, NewRequest("{ items }"), nil)
+	is.Equal(err.Error(), "graphql: cannot send unnamed operation, it is not in the persisted manifest")
+	is.Equal(calls, 1)
+}
@@ -0,0 +1,602 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// rootQuery is the key of the entity holding the root fields of queries
+// in a NormalizedCache.
+const rootQuery = "ROOT_QUERY"
+
+// errNotNormalizable is returned when parsing operations the normalized
+// cache cannot handle, such as those with directives on their fields.
+var errNotNormalizable = errors.New("graphql: operation cannot be normalized")
+
+// NormalizedCache caches the objects of responses by their __typename and
+// id, merging the fields of the objects returned by every operation, so
+// that queries can be answered from the objects fetched by others. The
+// zero value is not usable; make caches with NewNormalizedCache.
+type NormalizedCache struct {
+	mu sync.Mutex
+	// entities holds the fields of each object by the key of the object,
+	// such as User:1. The values of the fields are json.RawMessage for
+	// scalars and null, entityRef for objects with keys,
+	// map[string]interface{} for other objects, and []interface{} for
+	// lists. Fields with arguments are stored by their name and
+	// arguments, such as user({"id":"1"}).
+	entities map[string]map[string]interface{}
+	// index indexes the root fields by the labels of the requests that
+	// cached them
+	index *cacheIndex
+}
+
+// entityRef is a reference to an object in a NormalizedCache.
+type entityRef string
+
+// NewNormalizedCache makes a new, empty NormalizedCache.
+func NewNormalizedCache() *NormalizedCache {
+	return &NormalizedCache{entities: make(map[string]map[string]interface{}), index: newCacheIndex()}
+}
+
+// Len returns the number of objects in the cache, including the root
+// query.
+func (nc *NormalizedCache) Len() int {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	return len(nc.entities)
+}
+
+// selection is a field selected by an operation, with the fields of any
+// fragments it spreads flattened into the selection set.
+type selection struct {
+	// key is the response name of the field, which is its alias if it
+	// has one
+	key  string
+	name string
+	// args are the arguments of the field, with any variables replaced
+	// by their values
+	args     map[string]interface{}
+	children []selection
+	// hasChildren is whether the field has a selection set
+	hasChildren bool
+	// typeCondition is the type condition of the fragment the field is
+	// selected in, if any
+	typeCondition string
+}
+
+// storageKey returns the key the field is stored by in an object.
+func (s selection) storageKey() string {
+	if len(s.args) == 0 {
+		return s.name
+	}
+	// The keys of maps are encoded in order
+	b, err := json.Marshal(s.args)
+	if err != nil {
+		return s.name
+	}
+	return s.name + "(" + string(b) + ")"
+}
+
+// entityKey returns the key of the object, made of its __typename and id,
+// or an empty string if it has neither.
+func entityKey(obj map[string]json.RawMessage) string {
+	var typename string
+	if err := json.Unmarshal(obj["__typename"], &typename); err != nil || typename == "" {
+		return ""
+	}
+	id := bytes.TrimSpace(obj["id"])
+	if len(id) == 0 || string(id) == "null" {
+		return ""
+	}
+	return typename + ":" + strings.Trim(string(id), `"`)
+}
+
+// normalizedSelections returns the fields selected by the operation of
+// req, which has the query q with the registered fragments it uses, and
+// the variables vars, which are not modified.
+func normalizedSelections(q, operation string, vars map[string]interface{}) ([]selection, error) {
+	op, defs, err := operationDefinition(q, operation)
+	if err != nil {
+		return nil, err
+	}
+	varDefs, _ := variableDefinitions(q, operation)
+	// The defaults are added to a copy, since vars may be the variables
+	// of the request
+	withDefaults, copied := vars, false
+	for _, def := range varDefs {
+		if _, ok := vars[def.name]; ok || def.defaultValue == "" {
+			continue
+		}
+		value, _, err := parseValue(def.defaultValue, 0, nil)
+		if err != nil {
+			return nil, err
+		}
+		if !copied {
+			withDefaults, copied = make(map[string]interface{}, len(vars)+1), true
+			for name, value := range vars {
+				withDefaults[name] = value
+			}
+		}
+		withDefaults[def.name] = value
+	}
+	vars = withDefaults
+	p := &selectionParser{q: q, vars: vars, fragments: make(map[string]definition), visiting: make(map[string]bool)}
+	for _, def := range defs {
+		if def.keyword == "fragment" {
+			p.fragments[def.name] = def
+		}
+	}
+	return p.selections(selectionSetStart(q, op.start), "")
+}
+
+// selectionParser parses the selection sets of an operation.
+type selectionParser struct {
+	q         string
+	vars      map[string]interface{}
+	fragments map[string]definition
+	// visiting holds the fragments being parsed, to reject cycles
+	visiting map[string]bool
+}
+
+// selections returns the fields of the selection set starting at i, in a
+// fragment with the type condition cond, if any.
+func (p *selectionParser) selections(i int, cond string) ([]selection, error) {
+	q := p.q
+	if i >= len(q) || q[i] != '{' {
+		return nil, errNotNormalizable
+	}
+	var sels []selection
+	for i = skipIgnored(q, i+1); i < len(q) && q[i] != '}'; i = skipIgnored(q, i) {
+		if strings.HasPrefix(q[i:], "...") {
+			j := skipIgnored(q, i+3)
+			name := q[j:skipName(q, j)]
+			fragmentCond := cond
+			var start int
+			if name != "" && name != "on" {
+				// A fragment spread
+				def, ok := p.fragments[name]
+				if !ok || p.visiting[name] {
+					return nil, errNotNormalizable
+				}
+				i = skipName(q, j)
+				if skipDirectives(q, i) != i {
+					return nil, errNotNormalizable
+				}
+				k := skipIgnored(q, skipName(q, skipIgnored(q, skipName(q, def.start))))
+				k = skipIgnored(q, skipName(q, k)) // on
+				fragmentCond = q[k:skipName(q, k)]
+				start = selectionSetStart(q, def.start)
+				p.visiting[name] = true
+				children, err := p.selections(start, fragmentCond)
+				delete(p.visiting, name)
+				if err != nil {
+					return nil, err
+				}
+				sels = append(sels, children...)
+				continue
+			}
+			if name == "on" {
+				j = skipIgnored(q, skipName(q, j))
+				fragmentCond = q[j:skipName(q, j)]
+				j = skipName(q, j)
+			}
+			if skipDirectives(q, j) != j {
+				return nil, errNotNormalizable
+			}
+			j = skipIgnored(q, j)
+			children, err := p.selections(j, fragmentCond)
+			if err != nil {
+				return nil, err
+			}
+			sels = append(sels, children...)
+			i = skipDefinition(q, j)
+			continue
+		}
+		start := i
+		i = skipName(q, i)
+		if i == start {
+			return nil, errNotNormalizable
+		}
+		sel := selection{key: q[start:i], name: q[start:i], typeCondition: cond}
+		if j := skipIgnored(q, i); j < len(q) && q[j] == ':' {
+			j = skipIgnored(q, j+1)
+			i = skipName(q, j)
+			sel.name = q[j:i]
+		}
+		if j := skipIgnored(q, i); j < len(q) && q[j] == '(' {
+			args, end, err := p.arguments(j)
+			if err != nil {
+				return nil, err
+			}
+			sel.args, i = args, end
+		}
+		if skipDirectives(q, i) != i {
+			return nil, errNotNormalizable
+		}
+		if j := skipIgnored(q, i); j < len(q) && q[j] == '{' {
+			children, err := p.selections(j, "")
+			if err != nil {
+				return nil, err
+			}
+			sel.children, sel.hasChildren = children, true
+			i = skipDefinition(q, j)
+		}
+		sels = append(sels, sel)
+	}
+	return sels, nil
+}
+
+// arguments returns the arguments starting at i, and the index just past
+// them.
+func (p *selectionParser) arguments(i int) (map[string]interface{}, int, error) {
+	q := p.q
+	args := make(map[string]interface{})
+	for i = skipIgnored(q, i+1); i < len(q) && q[i] != ')'; i = skipIgnored(q, i) {
+		start := i
+		i = skipName(q, i)
+		name := q[start:i]
+		i = skipIgnored(q, i)
+		if name == "" || i >= len(q) || q[i] != ':' {
+			return nil, 0, errNotNormalizable
+		}
+		value, end, err := parseValue(q, skipIgnored(q, i+1), p.vars)
+		if err != nil {
+			return nil, 0, err
+		}
+		args[name], i = value, end
+	}
+	if i >= len(q) {
+		return nil, 0, errNotNormalizable
+	}
+	return args, i + 1, nil
+}
+
+// parseValue returns the GraphQL value starting at i in q, with variables
+// replaced by their values in vars, and the index just past it. Enum
+// values are returned as strings, and numbers as json.Number.
+func parseValue(q string, i int, vars map[string]interface{}) (interface{}, int, error) {
+	if i >= len(q) {
+		return nil, i, errNotNormalizable
+	}
+	switch q[i] {
+	case '$':
+		end := skipName(q, i+1)
+		return vars[q[i+1:end]], end, nil
+	case '"':
+		end := skipString(q, i)
+		raw := q[i:end]
+		if strings.HasPrefix(raw, `"""`) {
+			return strings.TrimSuffix(raw[3:], `"""`), end, nil
+		}
+		var s string
+		if err := json.Unmarshal([]byte(raw), &s); err != nil {
+			return raw, end, nil
+		}
+		return s, end, nil
+	case '[':
+		list := []interface{}{}
+		for i = skipIgnored(q, i+1); i < len(q) && q[i] != ']'; i = skipIgnored(q, i) {
+			value, end, err := parseValue(q, i, vars)
+			if err != nil {
+				return nil, i, err
+			}
+			list, i = append(list, value), end
+		}
+		return list, i + 1, nil
+	case '{':
+		obj := make(map[string]interface{})
+		for i = skipIgnored(q, i+1); i < len(q) && q[i] != '}'; i = skipIgnored(q, i) {
+			start := i
+			i = skipName(q, i)
+			name := q[start:i]
+			i = skipIgnored(q, i)
+			if name == "" || i >= len(q) || q[i] != ':' {
+				return nil, i, errNotNormalizable
+			}
+			value, end, err := parseValue(q, skipIgnored(q, i+1), vars)
+			if err != nil {
+				return nil, i, err
+			}
+			obj[name], i = value, end
+		}
+		return obj, i + 1, nil
+	}
+	end := i
+	for end < len(q) && (isNameChar(q[end]) || strings.IndexByte("-+.", q[end]) >= 0) {
+		end++
+	}
+	token := q[i:end]
+	switch {
+	case token == "":
+		return nil, i, errNotNormalizable
+	case token == "true", token == "false":
+		return token == "true", end, nil
+	case token == "null":
+		return nil, end, nil
+	case token[0] == '-' || token[0] >= '0' && token[0] <= '9':
+		return json.Number(token), end, nil
+	}
+	return token, end, nil
+}
+
+// write merges the data of a response to an operation selecting sels
+// into the cache, with the root fields of queries stored in the root
+// query, indexed by labels.
+func (nc *NormalizedCache) write(data json.RawMessage, sels []selection, query bool, labels []string) error {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	fields, err := nc.writeObject(obj, sels)
+	if err != nil {
+		return err
+	}
+	if query {
+		nc.merge(rootQuery, fields)
+		for key := range fields {
+			if key != "__typename" {
+				nc.index.add(labels, key)
+			}
+		}
+	}
+	return nil
+}
+
+// writeObject returns the fields of the response object obj selected
+// by sels, storing the objects with keys that it holds. The cache must be
+// locked.
+func (nc *NormalizedCache) writeObject(obj map[string]json.RawMessage, sels []selection) (map[string]interface{}, error) {
+	fields := make(map[string]interface{}, len(sels)+1)
+	if typename, ok := obj["__typename"]; ok {
+		fields["__typename"] = typename
+	}
+	for _, sel := range sels {
+		raw, ok := obj[sel.key]
+		if !ok {
+			continue // a field of a fragment of another type
+		}
+		value, err := nc.writeValue(raw, sel)
+		if err != nil {
+			return nil, err
+		}
+		key := sel.storageKey()
+		fields[key] = mergeField(fields[key], value)
+	}
+	return fields, nil
+}
+
+// writeValue returns the value to store for the response value raw of
+// the field sel.
+func (nc *NormalizedCache) writeValue(raw json.RawMessage, sel selection) (interface{}, error) {
+	raw = bytes.TrimSpace(raw)
+	if !sel.hasChildren || len(raw) == 0 || raw[0] != '[' && raw[0] != '{' {
+		return append(json.RawMessage(nil), raw...), nil
+	}
+	if raw[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, err
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			value, err := nc.writeValue(item, sel)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = value
+		}
+		return list, nil
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	fields, err := nc.writeObject(obj, sel.children)
+	if err != nil {
+		return nil, err
+	}
+	if key := entityKey(obj); key != "" {
+		nc.merge(key, fields)
+		return entityRef(key), nil
+	}
+	return fields, nil
+}
+
+// merge merges fields into the object with key. The cache must be
+// locked.
+func (nc *NormalizedCache) merge(key string, fields map[string]interface{}) {
+	entity, ok := nc.entities[key]
+	if !ok {
+		nc.entities[key] = fields
+		return
+	}
+	for name, value := range fields {
+		entity[name] = mergeField(entity[name], value)
+	}
+}
+
+// mergeField returns the value of a field stored as existing, with value
+// merged into it. Objects without keys are merged field by field, and
+// other values replace the existing value.
+func mergeField(existing, value interface{}) interface{} {
+	existingObj, ok := existing.(map[string]interface{})
+	obj, ok2 := value.(map[string]interface{})
+	if !ok || !ok2 {
+		return value
+	}
+	merged := make(map[string]interface{}, len(existingObj)+len(obj))
+	for name, v := range existingObj {
+		merged[name] = v
+	}
+	for name, v := range obj {
+		merged[name] = mergeField(merged[name], v)
+	}
+	return merged
+}
+
+// read returns the data of a query selecting sels answered from the
+// cache, and false if any of the fields is not cached.
+func (nc *NormalizedCache) read(sels []selection) (json.RawMessage, bool) {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	root, ok := nc.entities[rootQuery]
+	if !ok {
+		return nil, false
+	}
+	data, ok := nc.readObject(root, sels)
+	if !ok {
+		return nil, false
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// readObject returns the response object for the fields sels of the
+// stored object fields. The cache must be locked.
+func (nc *NormalizedCache) readObject(fields map[string]interface{}, sels []selection) (map[string]interface{}, bool) {
+	out := make(map[string]interface{}, len(sels))
+	for _, sel := range sels {
+		if sel.typeCondition != "" {
+			// Fields of fragments are only answered for objects of the
+			// type of the fragment, since the cache does not know which
+			// types implement interfaces
+			var typename string
+			raw, _ := fields["__typename"].(json.RawMessage)
+			if json.Unmarshal(raw, &typename) != nil || typename != sel.typeCondition {
+				return nil, false
+			}
+		}
+		stored, ok := fields[sel.storageKey()]
+		if !ok {
+			return nil, false
+		}
+		value, ok := nc.readValue(stored, sel)
+		if !ok {
+			return nil, false
+		}
+		out[sel.key] = mergeField(out[sel.key], value)
+	}
+	return out, true
+}
+
+// readValue returns the response value of the field sel for the stored
+// value. The cache must be locked.
+func (nc *NormalizedCache) readValue(stored interface{}, sel selection) (interface{}, bool) {
+	switch stored := stored.(type) {
+	case json.RawMessage:
+		if sel.hasChildren && string(stored) != "null" {
+			return nil, false
+		}
+		return stored, true
+	case entityRef:
+		entity, ok := nc.entities[string(stored)]
+		if !ok {
+			return nil, false
+		}
+		return nc.readObject(entity, sel.children)
+	case map[string]interface{}:
+		return nc.readObject(stored, sel.children)
+	case []interface{}:
+		list := make([]interface{}, len(stored))
+		for i, item := range stored {
+			value, ok := nc.readValue(item, sel)
+			if !ok {
+				return nil, false
+			}
+			list[i] = value
+		}
+		return list, true
+	}
+	return nil, false
+}
+
+// normalizedSelectionsFor returns the fields selected by the operation of
+// req, and false if the cache cannot handle it.
+func (c *Client) normalizedSelectionsFor(req *Request) ([]selection, bool) {
+	q, err := c.composeQuery(req)
+	if err != nil {
+		return nil, false
+	}
+	vars, err := c.encodeVars(c.requestVars(req))
+	if err != nil {
+		return nil, false
+	}
+	sels, err := normalizedSelections(q, req.operationName, vars)
+	if err != nil {
+		c.logf(">> not using the normalized cache: %v", err)
+		return nil, false
+	}
+	return sels, true
+}
+
+// runNormalized answers the query of req from the normalized cache, or
+// runs the operation of req and merges its response into the cache.
+func (c *Client) runNormalized(ctx context.Context, req *Request, resp interface{}, opts []RunOption) error {
+	sels, ok := c.normalizedSelectionsFor(req)
+	if !ok {
+		return c.runRemote(ctx, req, resp, opts)
+	}
+	query := req.OperationType() == "query"
+	if query && req.cacheTTL >= 0 {
+		settings, err := applyRunOptions(resp, opts)
+		if err != nil {
+			return err
+		}
+		if settings.result == nil {
+			if data, ok := c.normalized.read(sels); ok {
+				c.logf("<< answered from the normalized cache")
+				if err := c.decodeData(data, settings.data); err != nil {
+					return &DecodeError{Err: err}
+				}
+				return nil
+			}
+		}
+	}
+
+	// Capture the data of the response to merge it into the cache
+	var data json.RawMessage
+	fetch := req.Clone()
+	fetch.onRawData = func(raw json.RawMessage) {
+		data = raw
+		if req.onRawData != nil {
+			req.onRawData(raw)
+		}
+	}
+	err := c.runRemote(ctx, fetch, resp, opts)
+	var errs Errors
+	if (err == nil || errors.As(err, &errs)) && hasData(data) {
+		if writeErr := c.normalized.write(data, sels, query && err == nil, cacheLabels(req)); writeErr != nil {
+			c.logf("!! failed to normalize response: %v", writeErr)
+		}
+	}
+	return err
+}
+
+// WithNormalizedCache caches the objects of the responses to queries and
+// mutations in cache, by their __typename and id, merging the objects
+// returned by every operation, and answers queries whose every field is
+// in the cache without sending them, like the normalized caches of
+// JavaScript clients. Objects are only stored by key if the operation
+// selects their __typename and id, which WithTypename selects for every
+// object.
+//
+// The objects of responses with errors are merged into the cache, but
+// their root fields are not. Queries with directives on their fields or
+// fragment spreads, and fields of fragments on interfaces or unions, are
+// not answered from the cache, nor are requests with a CacheTTL of zero
+// or less, or queries run with RunResult.
+func WithNormalizedCache(cache *NormalizedCache) ClientOption {
+	return func(client *Client) {
+		client.normalized = cache
+	}
+}
@@ -0,0 +1,185 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestParseValue(t *testing.T) {
+	is := is.New(t)
+	vars := map[string]interface{}{"id": "1"}
+	value, end, err := parseValue(`{a: [1, -2.5e3, "x\n"], b: $id, c: ENUM, d: null, e: true} rest`, 0, vars)
+	is.NoErr(err)
+	is.Equal(value, map[string]interface{}{
+		"a": []interface{}{json.Number("1"), json.Number("-2.5e3"), "x\n"},
+		"b": "1",
+		"c": "ENUM",
+		"d": nil,
+		"e": true,
+	})
+	is.Equal(end, len(`{a: [1, -2.5e3, "x\n"], b: $id, c: ENUM, d: null, e: true}`))
+	value, _, err = parseValue(`"""block "quoted" """`, 0, nil)
+	is.NoErr(err)
+	is.Equal(value, `block "quoted" `)
+}
+
+func TestNormalizedSelections(t *testing.T) {
+	is := is.New(t)
+	q := `query ($id: ID!, $first: Int = 10) {
+		me: user(id: $id) { ...F posts(first: $first) { title } }
+	}
+	fragment F on User { id name }`
+	sels, err := normalizedSelections(q, "", map[string]interface{}{"id": "1"})
+	is.NoErr(err)
+	is.Equal(len(sels), 1)
+	is.Equal(sels[0].key, "me")
+	is.Equal(sels[0].storageKey(), `user({"id":"1"})`)
+	children := sels[0].children
+	is.Equal(len(children), 3)
+	is.Equal(children[0].name, "id")
+	is.Equal(children[0].typeCondition, "User")
+	is.Equal(children[2].storageKey(), `posts({"first":10})`)
+
+	_, err = normalizedSelections(`query { user @cached { id } }`, "", nil)
+	is.Equal(err, errNotNormalizable)
+	_, err = normalizedSelections(`query { ...A } fragment A on Query { ...A }`, "", nil)
+	is.Equal(err, errNotNormalizable)
+}
+
+func TestWithNormalizedCache(t *testing.T) {
+	is := is.New(t)
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Query string }
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		queries = append(queries, body.Query)
+		switch body.Query {
+		case `query { user(id: "1") { __typename id name } }`:
+			io.WriteString(w, `{"data":{"user":{"__typename":"User","id":"1","name":"Ann"}}}`)
+		case `query { users { __typename id name } }`:
+			io.WriteString(w, `{"data":{"users":[{"__typename":"User","id":"1","name":"Ann"},{"__typename":"User","id":"2","name":"Bob"}]}}`)
+		case `mutation { rename(id: "1", name: "Amy") { __typename id name } }`:
+			io.WriteString(w, `{"data":{"rename":{"__typename":"User","id":"1","name":"Amy"}}}`)
+		default:
+			io.WriteString(w, `{"data":{"user":{"__typename":"User","id":"1","email":"ann@example.com"}}}`)
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	cache := NewNormalizedCache()
+	client := NewClient(srv.URL, WithNormalizedCache(cache))
+	type user struct {
+		ID   string
+		Name string
+	}
+	var resp struct{ User user }
+	is.NoErr(client.Run(ctx, NewRequest(`query { user(id: "1") { __typename id name } }`), &resp))
+	is.Equal(resp.User, user{ID: "1", Name: "Ann"})
+
+	// The query is answered from the cache, as are queries selecting
+	// fewer fields, with other aliases
+	resp.User = user{}
+	is.NoErr(client.Run(ctx, NewRequest(`query { user(id: "1") { __typename id name } }`), &resp))
+	is.Equal(resp.User, user{ID: "1", Name: "Ann"})
+	var aliased struct{ Me user }
+	is.NoErr(client.Run(ctx, NewRequest(`query { me: user(id: "1") { name } }`), &aliased))
+	is.Equal(aliased.Me.Name, "Ann")
+	is.Equal(len(queries), 1)
+
+	// Fields not in the cache are sent
+	is.NoErr(client.Run(ctx, NewRequest(`query { user(id: "1") { email } }`), nil))
+	is.Equal(len(queries), 2)
+
+	// Objects returned by mutations are merged into the cache
+	is.NoErr(client.Run(ctx, NewRequest(`mutation { rename(id: "1", name: "Amy") { __typename id name } }`), nil))
+	resp.User = user{}
+	is.NoErr(client.Run(ctx, NewRequest(`query { user(id: "1") { __typename id name } }`), &resp))
+	is.Equal(resp.User, user{ID: "1", Name: "Amy"})
+	is.Equal(len(queries), 3)
+
+	// As are the objects of lists
+	var list struct{ Users []user }
+	is.NoErr(client.Run(ctx, NewRequest(`query { users { __typename id name } }`), &list))
+	is.NoErr(client.Run(ctx, NewRequest(`query { users { __typename id name } }`), &list))
+	is.Equal(list.Users, []user{{ID: "1", Name: "Ann"}, {ID: "2", Name: "Bob"}})
+	is.Equal(len(queries), 4)
+	resp.User = user{}
+	is.NoErr(client.Run(ctx, NewRequest(`query { user(id: "1") { __typename id name } }`), &resp))
+	is.Equal(resp.User.Name, "Ann") // the list refreshed the object
+	is.Equal(cache.Len(), 3)
+
+	// Requests opting out of caching are sent
+	req := NewRequest(`query { user(id: "1") { __typename id name } }`)
+	req.CacheTTL(0)
+	is.NoErr(client.Run(ctx, req, nil))
+	is.Equal(len(queries), 5)
+}
+
+func TestWithNormalizedCacheVariableDefaults(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"users":[{"__typename":"User","id":"1"}]}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithNormalizedCache(NewNormalizedCache()))
+	req := NewRequest(`query ($a: Int, $limit: Int = 10) { users(a: $a, limit: $limit) { __typename id } }`)
+	req.Var("a", 1)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			is.NoErr(client.Run(ctx, req, nil))
+		}()
+	}
+	wg.Wait()
+	// The defaults are not added to the variables of the request
+	is.Equal(req.Vars(), map[string]interface{}{"a": 1})
+}
+
+func TestWithNormalizedCacheFragments(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		io.WriteString(w, `{"data":{"node":{"__typename":"User","id":"1","name":"Ann"}}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithNormalizedCache(NewNormalizedCache()))
+	q := `query ($id: ID!) { node(id: $id) { __typename id ...on User { name } } }`
+	for i := 0; i < 2; i++ {
+		req := NewRequest(q)
+		req.Var("id", "1")
+		var resp struct {
+			Node struct{ Name string }
+		}
+		is.NoErr(client.Run(ctx, req, &resp))
+		is.Equal(resp.Node.Name, "Ann")
+	}
+	is.Equal(calls, 1)
+
+	// Other variables are other fields
+	req := NewRequest(q)
+	req.Var("id", "2")
+	is.NoErr(client.Run(ctx, req, nil))
+	is.Equal(calls, 2)
+}
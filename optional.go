@@ -0,0 +1,94 @@
+package graphql
+
+import "encoding/json"
+
+// Null is a variable value sent as null, even as a field of a struct
+// given to VarsFromStruct with the omitempty option, where nil values are
+// omitted. Servers may treat a variable that is null differently from
+// one that is omitted, such as clearing a field rather than leaving it
+// unchanged.
+var Null = NullValue{}
+
+// NullValue is the type of Null.
+type NullValue struct{}
+
+// MarshalJSON encodes the value as null.
+func (NullValue) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
+// Optional is a value that is either omitted, explicitly null, or set,
+// to tell the three apart where GraphQL does. The zero value is omitted:
+// a struct field that is omitted is left out by VarsFromStruct, and
+// passing it to Request.Var removes the variable.
+type Optional[T any] struct {
+	value T
+	state optionalState
+}
+
+type optionalState uint8
+
+const (
+	optionalOmitted optionalState = iota
+	optionalNull
+	optionalSet
+)
+
+// Some returns an Optional set to v.
+func Some[T any](v T) Optional[T] {
+	return Optional[T]{value: v, state: optionalSet}
+}
+
+// NullOf returns an Optional that is explicitly null.
+func NullOf[T any]() Optional[T] {
+	return Optional[T]{state: optionalNull}
+}
+
+// Get returns the value, and whether it is set.
+func (o Optional[T]) Get() (T, bool) {
+	return o.value, o.state == optionalSet
+}
+
+// IsNull reports whether the value is explicitly null.
+func (o Optional[T]) IsNull() bool {
+	return o.state == optionalNull
+}
+
+// IsOmitted reports whether the value is omitted.
+func (o Optional[T]) IsOmitted() bool {
+	return o.state == optionalOmitted
+}
+
+// MarshalJSON encodes the value, or null if it is not set.
+func (o Optional[T]) MarshalJSON() ([]byte, error) {
+	if o.state != optionalSet {
+		return []byte("null"), nil
+	}
+	return json.Marshal(o.value)
+}
+
+// UnmarshalJSON decodes the value, so Optional fields of response objects
+// tell null values apart from missing ones too.
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*o = NullOf[T]()
+		return nil
+	}
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*o = Some(v)
+	return nil
+}
+
+// omittable is implemented by values that may be omitted.
+type omittable interface {
+	IsOmitted() bool
+}
+
+// isOmitted reports whether value is an omitted Optional.
+func isOmitted(value interface{}) bool {
+	o, ok := value.(omittable)
+	return ok && o.IsOmitted()
+}
@@ -0,0 +1,46 @@
+package graphql
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestOptional(t *testing.T) {
+	is := is.New(t)
+	vars := struct {
+		Name     Optional[string]   `graphql:"name"`
+		Nickname Optional[string]   `graphql:"nickname"`
+		Age      Optional[int]      `graphql:"age"`
+		Avatar   interface{}        `graphql:"avatar,omitempty"`
+		Bio      interface{}        `graphql:"bio,omitempty"`
+		Tags     Optional[[]string] `graphql:"tags,omitempty"`
+	}{
+		Name:     Some("Mat"),
+		Nickname: NullOf[string](),
+		Bio:      Null,
+		Tags:     NullOf[[]string](),
+	}
+	req := NewRequest("mutation {}")
+	is.NoErr(req.VarsFromStruct(vars))
+	b, err := json.Marshal(req.Vars())
+	is.NoErr(err)
+	is.Equal(string(b), `{"bio":null,"name":"Mat","nickname":null,"tags":null}`)
+
+	req.Var("name", Optional[string]{})
+	_, ok := req.Vars()["name"]
+	is.True(!ok) // omitted values remove the variable
+
+	var resp struct {
+		A Optional[int]
+		B Optional[int]
+		C Optional[int]
+	}
+	is.NoErr(json.Unmarshal([]byte(`{"A":1,"B":null}`), &resp))
+	a, ok := resp.A.Get()
+	is.True(ok)
+	is.Equal(a, 1)
+	is.True(resp.B.IsNull())
+	is.True(resp.C.IsOmitted())
+}
@@ -0,0 +1,81 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+)
+
+// persistedQuery is the persistedQuery extension of a request sent as an
+// automatic persisted query.
+type persistedQuery struct {
+	Version    int    `json:"version"`
+	Sha256Hash string `json:"sha256Hash"`
+}
+
+// persistedQueries holds the state of automatic persisted queries for a
+// client.
+type persistedQueries struct {
+	mu sync.Mutex
+	// unsupported is set once the server reports it does not support
+	// persisted queries
+	unsupported bool
+}
+
+// supported reports whether the server may support persisted queries.
+func (p *persistedQueries) supported() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.unsupported
+}
+
+// disable stops the client sending persisted queries.
+func (p *persistedQueries) disable() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.unsupported = true
+}
+
+// runPersisted sends req as an automatic persisted query, with the hash
+// of its document in place of the document, sending the document with
+// the hash to register it if the server does not know the hash, and
+// without the hash if the server does not support persisted queries.
+func (c *Client) runPersisted(ctx context.Context, req *execution, transport Transport, newResponse func() *graphResponse) error {
+	if !c.persistedQueries.supported() {
+		return c.dispatch(ctx, req, transport, newResponse())
+	}
+	hash := req.Hash()
+	ex := *req
+	ex.persistedQuery = &persistedQuery{Version: 1, Sha256Hash: hash}
+	ex.omitQuery = true
+	err := c.dispatch(ctx, &ex, transport, newResponse())
+	switch {
+	case HasErrorCode(err, CodePersistedQueryNotFound):
+		c.logf(">> registering persisted query %s", hash)
+		ex = *req
+		ex.persistedQuery = &persistedQuery{Version: 1, Sha256Hash: hash}
+		return c.dispatch(ctx, &ex, transport, newResponse())
+	case HasErrorCode(err, CodePersistedQueryNotSupported):
+		c.logf(">> persisted queries not supported, sending documents")
+		c.persistedQueries.disable()
+		ex = *req
+		return c.dispatch(ctx, &ex, transport, newResponse())
+	}
+	return err
+}
+
+// WithPersistedQueries sends queries and mutations as automatic
+// persisted queries, sending the SHA-256 hash of the document in the
+// persistedQuery extension rather than the document, which saves sending
+// large documents every time. When the server does not know a hash,
+// reporting PERSISTED_QUERY_NOT_FOUND, the request is sent again with the
+// document to register it. A server reporting
+// PERSISTED_QUERY_NOT_SUPPORTED is sent documents from then on.
+//
+// Persisted queries are sent with the JSON and GET transports, where
+// queries sent by hash alone can be cached by HTTP caches. Requests sent
+// as raw bodies or multipart forms are sent as they are.
+func WithPersistedQueries() ClientOption {
+	return func(client *Client) {
+		client.persistedQueries = &persistedQueries{}
+	}
+}
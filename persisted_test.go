@@ -0,0 +1,109 @@
+package graphql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestWithPersistedQueries(t *testing.T) {
+	is := is.New(t)
+	query := "query { items }"
+	sum := sha256.Sum256([]byte(query))
+	hash := hex.EncodeToString(sum[:])
+	registered := make(map[string]string)
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		bodies = append(bodies, string(b))
+		var body struct {
+			Query      *string
+			Extensions struct {
+				PersistedQuery persistedQuery
+			}
+		}
+		is.NoErr(json.Unmarshal(b, &body))
+		is.Equal(body.Extensions.PersistedQuery.Version, 1)
+		is.Equal(body.Extensions.PersistedQuery.Sha256Hash, hash)
+		if body.Query != nil {
+			registered[hash] = *body.Query
+		} else if _, ok := registered[hash]; !ok {
+			io.WriteString(w, `{"errors":[{"message":"PersistedQueryNotFound","extensions":{"code":"PERSISTED_QUERY_NOT_FOUND"}}]}`)
+			return
+		}
+		io.WriteString(w, `{"data":{"items":["a"]}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithPersistedQueries())
+	var resp struct {
+		Items []string
+	}
+	is.NoErr(client.Run(ctx, NewRequest(query), &resp))
+	is.Equal(resp.Items, []string{"a"})
+	is.Equal(len(bodies), 2) // sent by hash, then registered
+	is.Equal(bodies[0], `{"variables":null,"extensions":{"persistedQuery":{"version":1,"sha256Hash":"`+hash+`"}}}`+"\n")
+	is.Equal(registered[hash], query)
+
+	resp.Items = nil
+	is.NoErr(client.Run(ctx, NewRequest(query), &resp))
+	is.Equal(resp.Items, []string{"a"})
+	is.Equal(len(bodies), 3) // sent by hash alone
+}
+
+func TestWithPersistedQueriesGET(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.Method, http.MethodGet)
+		is.Equal(r.URL.Query().Get("query"), "")
+		is.True(r.URL.Query().Get("extensions") != "")
+		io.WriteString(w, `{"data":{"items":[]}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, UseGET(), WithPersistedQueries())
+	is.NoErr(client.Run(ctx, NewRequest("query { items }"), nil))
+}
+
+func TestWithPersistedQueriesNotSupported(t *testing.T) {
+	is := is.New(t)
+	var calls, persisted int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var body struct {
+			Extensions map[string]interface{}
+		}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		if body.Extensions != nil {
+			persisted++
+			io.WriteString(w, `{"errors":[{"message":"PersistedQueryNotSupported","extensions":{"code":"PERSISTED_QUERY_NOT_SUPPORTED"}}]}`)
+			return
+		}
+		io.WriteString(w, `{"data":{"items":[]}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithPersistedQueries())
+	is.NoErr(client.Run(ctx, NewRequest("query { items }"), nil))
+	is.NoErr(client.Run(ctx, NewRequest("query { items }"), nil))
+	is.Equal(calls, 3)
+	is.Equal(persisted, 1) // the client stops sending persisted queries
+}
@@ -0,0 +1,45 @@
+package graphql
+
+import (
+	"context"
+	"time"
+)
+
+// PingStatus is the status of the server checked with Client.Ping.
+type PingStatus struct {
+	// StatusCode is the HTTP status code of the response, or zero if
+	// none was received.
+	StatusCode int
+	// Typename is the name of the root query type reported by the
+	// server, such as Query.
+	Typename string
+	// Latency is how long the check took.
+	Latency time.Duration
+}
+
+// Ping checks that the server is up and answers GraphQL requests, by
+// running the query { __typename }, which every server can answer, once
+// without retries. It is meant for readiness probes, and for checking the
+// endpoint when starting up:
+//
+//	if _, err := client.Ping(ctx); err != nil {
+//		log.Fatalf("graphql server unavailable: %v", err)
+//	}
+//
+// The status is returned even if the check fails, with what was received.
+// The error is ErrNoData if the server answered without the name of the
+// root query type.
+func (c *Client) Ping(ctx context.Context) (*PingStatus, error) {
+	var data struct {
+		Typename string `json:"__typename"`
+	}
+	result, err := c.RunResult(ctx, NewRequest("query { __typename }"), &data, WithRetries(0))
+	status := &PingStatus{StatusCode: result.StatusCode, Typename: data.Typename, Latency: result.Duration}
+	if err == nil && len(result.Errors) > 0 {
+		err = result.Errors
+	}
+	if err == nil && data.Typename == "" {
+		err = ErrNoData
+	}
+	return status, err
+}
@@ -0,0 +1,72 @@
+package graphql
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestPing(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `{"query":"query { __typename }","variables":null}`+"\n")
+		io.WriteString(w, `{"data":{"__typename":"Query"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	status, err := NewClient(srv.URL, WithRetry(3, time.Millisecond)).Ping(ctx)
+	is.NoErr(err)
+	is.Equal(status.StatusCode, http.StatusOK)
+	is.Equal(status.Typename, "Query")
+	is.True(status.Latency > 0)
+	is.Equal(calls, 1)
+}
+
+func TestPingFailure(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		calls++
+		switch calls {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			io.WriteString(w, `{"errors":[{"message":"unauthorized"}]}`)
+		default:
+			io.WriteString(w, `{"data":{}}`)
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithRetry(3, time.Millisecond))
+	status, err := client.Ping(ctx)
+	httpErr, ok := err.(*HTTPError)
+	is.True(ok)
+	is.Equal(httpErr.StatusCode, http.StatusServiceUnavailable)
+	is.Equal(status.StatusCode, http.StatusServiceUnavailable)
+	is.Equal(calls, 1) // without retries
+
+	status, err = client.Ping(ctx)
+	_, ok = err.(Errors)
+	is.True(ok)
+	is.Equal(status.StatusCode, http.StatusOK)
+
+	_, err = client.Ping(ctx)
+	is.Equal(err, ErrNoData)
+}
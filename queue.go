@@ -0,0 +1,332 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QueuedRequest is a request held in a Queue until it can be sent.
+type QueuedRequest struct {
+	// ID identifies the request in the queue. Requests are sent in the
+	// order of their IDs.
+	ID string `json:"id"`
+	// Query, OperationName, Variables and Header are those of the
+	// request, with the variables encoded as JSON.
+	Query         string                     `json:"query"`
+	OperationName string                     `json:"operationName,omitempty"`
+	Variables     map[string]json.RawMessage `json:"variables,omitempty"`
+	Header        http.Header                `json:"header,omitempty"`
+	// IdempotencyKey is sent with every attempt at the request, so that
+	// servers can tell it apart from new mutations if it is sent again
+	// after it succeeded, such as when the process stops before it is
+	// removed from the store.
+	IdempotencyKey string `json:"idempotencyKey"`
+	// Enqueued is when the request was added to the queue.
+	Enqueued time.Time `json:"enqueued"`
+}
+
+// request returns the Request to send for the queued request.
+func (r QueuedRequest) request() *Request {
+	req := NewRequest(r.Query)
+	req.OperationName(r.OperationName)
+	for key, value := range r.Variables {
+		req.Var(key, value)
+	}
+	for key, values := range r.Header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	req.IdempotencyKey(r.IdempotencyKey)
+	return req
+}
+
+// QueueStore persists the requests of a Queue, so that they survive the
+// process stopping before they can be sent. Implementations must be safe
+// for use by multiple goroutines.
+type QueueStore interface {
+	// Add stores a request.
+	Add(req QueuedRequest) error
+	// List returns the stored requests, in any order.
+	List() ([]QueuedRequest, error)
+	// Remove deletes the stored request with the ID, if any.
+	Remove(id string) error
+}
+
+// MemoryQueueStore is a QueueStore holding the requests in memory, for
+// queues that need not survive the process stopping.
+type MemoryQueueStore struct {
+	mu       sync.Mutex
+	requests map[string]QueuedRequest
+}
+
+// NewMemoryQueueStore makes a new, empty MemoryQueueStore.
+func NewMemoryQueueStore() *MemoryQueueStore {
+	return &MemoryQueueStore{requests: make(map[string]QueuedRequest)}
+}
+
+// Add stores a request.
+func (s *MemoryQueueStore) Add(req QueuedRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[req.ID] = req
+	return nil
+}
+
+// List returns the stored requests.
+func (s *MemoryQueueStore) List() ([]QueuedRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := make([]QueuedRequest, 0, len(s.requests))
+	for _, req := range s.requests {
+		requests = append(requests, req)
+	}
+	return requests, nil
+}
+
+// Remove deletes the stored request with the ID.
+func (s *MemoryQueueStore) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.requests, id)
+	return nil
+}
+
+// FileQueueStore is a QueueStore keeping each request in a JSON file in a
+// directory.
+type FileQueueStore struct {
+	dir string
+}
+
+// NewFileQueueStore returns a FileQueueStore keeping the requests in dir,
+// which is created if it does not exist.
+func NewFileQueueStore(dir string) (*FileQueueStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("graphql: failed to create queue directory: %w", err)
+	}
+	return &FileQueueStore{dir: dir}, nil
+}
+
+// Add writes the request to a file, replacing it atomically so that a
+// partly written request is never read.
+func (s *FileQueueStore) Add(req QueuedRequest) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("graphql: failed to encode queued request: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("graphql: failed to store queued request: %w", err)
+	}
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(s.dir, req.ID+".json"))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("graphql: failed to store queued request: %w", err)
+	}
+	return nil
+}
+
+// List reads the stored requests.
+func (s *FileQueueStore) List() ([]QueuedRequest, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("graphql: failed to list queued requests: %w", err)
+	}
+	var requests []QueuedRequest
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(s.dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue // removed since it was listed
+		}
+		if err != nil {
+			return nil, fmt.Errorf("graphql: failed to read queued request: %w", err)
+		}
+		var req QueuedRequest
+		if err := json.Unmarshal(b, &req); err != nil {
+			return nil, fmt.Errorf("graphql: failed to decode queued request %s: %w", name, err)
+		}
+		requests = append(requests, req)
+	}
+	return requests, nil
+}
+
+// Remove deletes the file of the request with the ID.
+func (s *FileQueueStore) Remove(id string) error {
+	err := os.Remove(filepath.Join(s.dir, id+".json"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("graphql: failed to remove queued request: %w", err)
+	}
+	return nil
+}
+
+// Queue holds mutations that cannot be sent yet, such as by clients at the
+// edge that are often offline, in a QueueStore, and sends them in order
+// when connectivity returns:
+//
+//	store, err := graphql.NewFileQueueStore("/var/lib/app/queue")
+//	...
+//	queue := graphql.NewQueue(client, store)
+//	go queue.Run(ctx, 30*time.Second)
+//	...
+//	_, err = queue.Enqueue(req)
+//
+// Requests are sent with Client.Run. A request failing with an error
+// classified as retriable by the client, such as a network failure, stops
+// the flush and stays in the queue to be sent on the next. Requests
+// failing with other errors are removed and passed to the function set
+// with OnFailure. Queues are safe for use by multiple goroutines.
+type Queue struct {
+	client    *Client
+	store     QueueStore
+	onFailure func(req QueuedRequest, err error)
+
+	// mu guards last, the ID of the last request enqueued, and flushing
+	// serialises flushes
+	mu       sync.Mutex
+	last     int64
+	flushing sync.Mutex
+}
+
+// NewQueue makes a new Queue sending the requests held in store with
+// client, including any requests stored by an earlier process.
+func NewQueue(client *Client, store QueueStore) *Queue {
+	return &Queue{client: client, store: store}
+}
+
+// OnFailure sets a function called with the requests removed from the
+// queue because they failed with an error that is not retriable, such as
+// a GraphQL error.
+func (q *Queue) OnFailure(fn func(req QueuedRequest, err error)) {
+	q.onFailure = fn
+}
+
+// Enqueue adds req to the queue, returning its ID. The variables of req
+// are encoded when it is added, so later changes to them do not affect
+// it. Requests with files cannot be queued.
+func (q *Queue) Enqueue(req *Request) (string, error) {
+	if len(req.files) > 0 {
+		return "", errors.New("graphql: cannot queue requests with files")
+	}
+	vars, err := q.client.encodeVars(req.vars)
+	if err != nil {
+		return "", err
+	}
+	queued := QueuedRequest{
+		Query:          req.q,
+		OperationName:  req.operationName,
+		Header:         req.Header.Clone(),
+		IdempotencyKey: req.idempotencyKey,
+		Enqueued:       time.Now(),
+	}
+	if len(vars) > 0 {
+		queued.Variables = make(map[string]json.RawMessage, len(vars))
+		for key, value := range vars {
+			b, err := json.Marshal(value)
+			if err != nil {
+				return "", fmt.Errorf("graphql: failed to encode variable %s: %w", key, err)
+			}
+			queued.Variables[key] = b
+		}
+	}
+	if queued.IdempotencyKey == "" {
+		if queued.IdempotencyKey, err = newIdempotencyKey(); err != nil {
+			return "", err
+		}
+	}
+	queued.ID = q.nextID(queued.Enqueued)
+	if err := q.store.Add(queued); err != nil {
+		return "", err
+	}
+	return queued.ID, nil
+}
+
+// nextID returns the ID of a request enqueued at now, which sorts after
+// the IDs of the requests enqueued before it.
+func (q *Queue) nextID(now time.Time) string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	id := now.UnixNano()
+	if id <= q.last {
+		id = q.last + 1
+	}
+	q.last = id
+	return fmt.Sprintf("%020d", id)
+}
+
+// Len returns the number of requests in the queue.
+func (q *Queue) Len() (int, error) {
+	requests, err := q.store.List()
+	return len(requests), err
+}
+
+// Flush sends the requests in the queue in order, returning how many were
+// sent successfully. It stops at the first request failing with a
+// retriable error, which is returned, leaving it and the requests after
+// it in the queue.
+func (q *Queue) Flush(ctx context.Context) (int, error) {
+	q.flushing.Lock()
+	defer q.flushing.Unlock()
+	requests, err := q.store.List()
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].ID < requests[j].ID
+	})
+	var sent int
+	for _, queued := range requests {
+		err := q.client.Run(ctx, queued.request(), nil)
+		if err != nil && (ctx.Err() != nil || q.client.IsRetriable(err)) {
+			return sent, err
+		}
+		if err := q.store.Remove(queued.ID); err != nil {
+			return sent, err
+		}
+		if err != nil {
+			q.client.logf(">> dropping queued request %s: %v", queued.ID, err)
+			if q.onFailure != nil {
+				q.onFailure(queued, err)
+			}
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// Run flushes the queue every interval until ctx is done, returning the
+// error of ctx.
+func (q *Queue) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := q.Flush(ctx); err != nil && ctx.Err() == nil {
+			q.client.logf(">> queue flush stopped: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestQueue(t *testing.T) {
+	is := is.New(t)
+	var bodies []string
+	var keys []string
+	offline := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if offline {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		bodies = append(bodies, string(b))
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		is.Equal(r.Header.Get("X-Device"), "sensor-1")
+		if len(bodies) == 2 {
+			io.WriteString(w, `{"errors":[{"message":"invalid reading"}]}`)
+			return
+		}
+		io.WriteString(w, `{"data":{"record":true}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	store, err := NewFileQueueStore(t.TempDir())
+	is.NoErr(err)
+	queue := NewQueue(NewClient(srv.URL), store)
+	var failed []QueuedRequest
+	queue.OnFailure(func(req QueuedRequest, err error) {
+		failed = append(failed, req)
+		_, ok := err.(Errors)
+		is.True(ok)
+	})
+	for i := 1; i <= 3; i++ {
+		req := NewRequest("mutation ($value: Int!) { record(value: $value) }")
+		req.Var("value", i)
+		req.Header.Set("X-Device", "sensor-1")
+		_, err := queue.Enqueue(req)
+		is.NoErr(err)
+	}
+
+	// Nothing is sent while offline
+	sent, err := queue.Flush(ctx)
+	is.True(err != nil)
+	is.Equal(sent, 0)
+	n, err := queue.Len()
+	is.NoErr(err)
+	is.Equal(n, 3)
+
+	// The requests survive the queue being recreated, and are sent in
+	// order once back online
+	offline = false
+	queue2 := NewQueue(NewClient(srv.URL), store)
+	queue2.onFailure = queue.onFailure
+	sent, err = queue2.Flush(ctx)
+	is.NoErr(err)
+	is.Equal(sent, 2)
+	is.Equal(len(bodies), 3)
+	for i, body := range bodies {
+		var payload struct{ Variables struct{ Value int } }
+		is.NoErr(json.Unmarshal([]byte(body), &payload))
+		is.Equal(payload.Variables.Value, i+1)
+		is.True(keys[i] != "")
+	}
+	is.Equal(len(failed), 1) // the request the server rejected is dropped
+	is.Equal(string(failed[0].Variables["value"]), "2")
+	n, err = queue2.Len()
+	is.NoErr(err)
+	is.Equal(n, 0)
+}
+
+func TestQueueRun(t *testing.T) {
+	is := is.New(t)
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		io.WriteString(w, `{"data":{}}`)
+		close(done)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	queue := NewQueue(NewClient(srv.URL), NewMemoryQueueStore())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- queue.Run(ctx, 5*time.Millisecond)
+	}()
+	_, err := queue.Enqueue(NewRequest("mutation { save }"))
+	is.NoErr(err)
+	<-done
+	cancel()
+	is.True(errors.Is(<-errs, context.Canceled))
+}
+
+func TestQueueFiles(t *testing.T) {
+	is := is.New(t)
+	queue := NewQueue(NewClient(""), NewMemoryQueueStore())
+	req := NewRequest("mutation { upload }")
+	req.File("file", "a.txt", nil)
+	_, err := queue.Enqueue(req)
+	is.True(err != nil)
+}
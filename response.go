@@ -0,0 +1,611 @@
+package graphql
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// ContentTypeError is returned when the server responds with a media type
+// that cannot be decoded as a GraphQL response.
+type ContentTypeError struct {
+	// ContentType is the Content-Type header of the response.
+	ContentType string
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("graphql: unexpected response content type %q", e.ContentType)
+}
+
+// decodeResponse decodes the body of res into gr according to the media
+// type of the response. Successful responses without a body, such as
+// 204 No Content responses some gateways send for mutations, have no
+// data rather than failing to decode.
+func (c *Client) decodeResponse(res *http.Response, body *bufio.Reader, gr *graphResponse) error {
+	if res.StatusCode >= 200 && res.StatusCode <= 299 && isEmptyBody(body) {
+		return nil
+	}
+	contentType := res.Header.Get("Content-Type")
+	if contentType == "" {
+		// Assume JSON for servers that do not set a content type
+		body, ok := decodeCharset(body, "")
+		if !ok {
+			return &ContentTypeError{ContentType: contentType, StatusCode: res.StatusCode}
+		}
+		return c.decodeJSON(body, gr)
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return &ContentTypeError{ContentType: contentType, StatusCode: res.StatusCode}
+	}
+	if mediaType == "multipart/mixed" {
+		return c.decodeIncremental(body, params["boundary"], gr)
+	}
+	body, ok := decodeCharset(body, params["charset"])
+	if !ok {
+		return &ContentTypeError{ContentType: contentType, StatusCode: res.StatusCode}
+	}
+	if isJSONMediaType(mediaType) || mediaType == "text/plain" && looksLikeJSON(body) {
+		return c.decodeJSON(body, gr)
+	}
+	return &ContentTypeError{ContentType: contentType, StatusCode: res.StatusCode}
+}
+
+// decodeJSON decodes a JSON response body into gr as it is read from r,
+// without holding the whole body in memory. Servers that stream the
+// response may send errors that occur after the data has been written in
+// further objects at the end of the body, which are merged into gr.
+func (c *Client) decodeJSON(r io.Reader, gr *graphResponse) error {
+	dec := json.NewDecoder(r)
+	if err := c.decodeEnvelope(dec, gr); err != nil {
+		return err
+	}
+	for {
+		err := c.decodeEnvelope(dec, gr)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid content after the response: %w", err)
+		}
+	}
+}
+
+// decodeEnvelope decodes the next response object from dec into gr.
+func (c *Client) decodeEnvelope(dec *json.Decoder, gr *graphResponse) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		// Match the fields case insensitively, like encoding/json
+		name, _ := key.(string)
+		switch strings.ToLower(name) {
+		case "data":
+			err = c.decodeDataFrom(dec, gr)
+		case "errors":
+			var errs Errors
+			err = dec.Decode(&errs)
+			gr.Errors = append(gr.Errors, errs...)
+		case "extensions":
+			err = dec.Decode(&gr.Extensions)
+		default:
+			err = dec.Decode(new(json.RawMessage))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// decodeDataFrom decodes the data field of a response from dec into gr,
+// streaming the elements of the field of gr.stream, if any.
+func (c *Client) decodeDataFrom(dec *json.Decoder, gr *graphResponse) error {
+	if gr.stream != nil {
+		return c.decodeStream(dec, gr)
+	}
+	_, split := gr.Data.(targets)
+	if gr.Data == nil || split || c.dataDecoder != nil || c.scalars != nil || c.strictDecoding || gr.onRawData != nil || c.requireData || gr.parseRateLimit {
+		var data json.RawMessage
+		if err := dec.Decode(&data); err != nil {
+			return err
+		}
+		gr.hasData = hasData(data)
+		if gr.parseRateLimit {
+			gr.rateLimit = dataRateLimit(data)
+		}
+		if gr.onRawData != nil {
+			gr.onRawData(data)
+		}
+		return c.decodeData(data, gr.Data)
+	}
+	return dec.Decode(gr.Data)
+}
+
+// decodeStream decodes the data field of a response from dec, passing the
+// elements of the streamed field to its callback one at a time. The other
+// fields of the data are decoded into gr.Data.
+func (c *Client) decodeStream(dec *json.Decoder, gr *graphResponse) error {
+	tok, err := dec.Token()
+	if err != nil || tok == nil {
+		// The data is null
+		return err
+	}
+	if tok != json.Delim('{') {
+		return fmt.Errorf("expected data to be an object, found %v", tok)
+	}
+	fields := make(map[string]json.RawMessage)
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		gr.hasData = true
+		name, _ := key.(string)
+		if name != gr.stream.field {
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return err
+			}
+			fields[name] = value
+			continue
+		}
+		if tok, err = dec.Token(); err != nil || tok == nil {
+			continue
+		}
+		if tok != json.Delim('[') {
+			return fmt.Errorf("expected %s to be a list, found %v", name, tok)
+		}
+		for dec.More() {
+			var item json.RawMessage
+			if err := dec.Decode(&item); err != nil {
+				return err
+			}
+			if err := gr.stream.fn(item); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return c.decodeData(data, gr.Data)
+}
+
+// errorsTrailer is the trailer in which servers that stream the response
+// send errors that occur after the body has been written.
+const errorsTrailer = "Graphql-Errors"
+
+// trailerErrors returns the errors in the trailers of a response, which
+// are a JSON list of errors. Values that are not are returned as the
+// message of an error.
+func trailerErrors(trailer http.Header) Errors {
+	var errs Errors
+	for _, value := range trailer.Values(errorsTrailer) {
+		var list Errors
+		if err := json.Unmarshal([]byte(value), &list); err != nil {
+			list = Errors{{Message: value}}
+		}
+		errs = append(errs, list...)
+	}
+	return errs
+}
+
+// hasData reports whether data holds any fields, so is neither null nor
+// an empty object.
+func hasData(data json.RawMessage) bool {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || string(data) == "null" {
+		return false
+	}
+	if data[0] == '{' {
+		return len(bytes.TrimSpace(data[1:len(data)-1])) > 0
+	}
+	return true
+}
+
+// expectDelim reads the next token from dec, which must be delim.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return &unexpectedTokenError{want: delim, found: tok}
+	}
+	return nil
+}
+
+// unexpectedTokenError is returned when the JSON of a response does not
+// have the structure of a GraphQL response.
+type unexpectedTokenError struct {
+	want  json.Delim
+	found json.Token
+}
+
+func (e *unexpectedTokenError) Error() string {
+	return fmt.Sprintf("expected %v, found %v", e.want, e.found)
+}
+
+// isMalformed reports whether err, returned when decoding a response,
+// means the body is not a valid JSON response object.
+func isMalformed(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var tokenErr *unexpectedTokenError
+	return err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &syntaxErr) || errors.As(err, &tokenErr)
+}
+
+// decodeData decodes the data field of a response into v.
+func (c *Client) decodeData(data json.RawMessage, v interface{}) error {
+	if v == nil || len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	if targets, ok := v.(targets); ok {
+		return c.decodeTargets(data, targets)
+	}
+	if c.dataDecoder != nil {
+		return c.dataDecoder(data, v)
+	}
+	if c.scalars != nil {
+		return c.scalars.decodeData(data, v, c.strictDecoding)
+	}
+	return unmarshalJSON(data, v, c.strictDecoding)
+}
+
+// Target is a response object for a single top-level field of the
+// response data, created by Into.
+type Target struct {
+	field string
+	v     interface{}
+}
+
+// Into decodes the top-level field of the response data with the given
+// name, or alias, into v. Pass targets to Run in place of the response
+// object to split the data of a query selecting several fields across
+// separate response objects. Targets whose field is missing from the data
+// are left untouched.
+func Into(field string, v interface{}) *Target {
+	return &Target{field: field, v: v}
+}
+
+func (t *Target) applyRun(settings *runSettings) {
+	settings.targets = append(settings.targets, t)
+}
+
+// targets are the Into targets of a call to Run.
+type targets []*Target
+
+// decodeTargets decodes the fields of data into their targets.
+func (c *Client) decodeTargets(data json.RawMessage, targets targets) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for _, target := range targets {
+		value, ok := fields[target.field]
+		if !ok {
+			continue
+		}
+		if err := c.decodeData(value, target.v); err != nil {
+			return fmt.Errorf("field %s: %w", target.field, err)
+		}
+	}
+	return nil
+}
+
+// isJSONMediaType reports whether mediaType can be decoded as JSON.
+func isJSONMediaType(mediaType string) bool {
+	if mediaType == mediaTypeJSON || mediaType == mediaTypeGraphQLResponse {
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+json")
+}
+
+// isEmptyBody reports whether body is empty or holds only whitespace.
+func isEmptyBody(body *bufio.Reader) bool {
+	for n := 1; n <= 512; n++ {
+		b, _ := body.Peek(n)
+		if len(b) < n {
+			return true
+		}
+		switch b[n-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return false
+	}
+	return false
+}
+
+// looksLikeJSON reports whether body appears to be a JSON object. net/http
+// sniffs plain text for JSON bodies written without a content type, so
+// plain text responses are decoded only if they look like JSON.
+func looksLikeJSON(body *bufio.Reader) bool {
+	for n := 1; n <= 512; n++ {
+		b, _ := body.Peek(n)
+		if len(b) < n {
+			return false
+		}
+		switch b[n-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '{':
+			return true
+		}
+		return false
+	}
+	return false
+}
+
+// headBuffer keeps the first max bytes written to it.
+type headBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *headBuffer) Write(p []byte) (int, error) {
+	room := b.max - b.buf.Len()
+	if len(p) > room {
+		b.truncated = true
+		b.buf.Write(p[:room])
+	} else {
+		b.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+// Bytes returns the bytes kept.
+func (b *headBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// String returns the bytes kept, noting if there were more.
+func (b *headBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "..."
+	}
+	return b.buf.String()
+}
+
+// maxBytesReader reads from r until the limit is exceeded.
+type maxBytesReader struct {
+	r         io.Reader
+	remaining int64
+	limit     int64
+}
+
+func (r *maxBytesReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		// Check whether the body ends at the limit
+		var b [1]byte
+		if n, err := r.r.Read(b[:]); n == 0 {
+			return 0, err
+		}
+		return 0, &ResponseTooLargeError{Limit: r.limit}
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.r.Read(p)
+	r.remaining -= int64(n)
+	return n, err
+}
+
+// readRecorder records the first error other than io.EOF returned by r,
+// so that failures to read the body can be told apart from bodies that
+// cannot be decoded.
+type readRecorder struct {
+	r   io.Reader
+	err error
+}
+
+func (r *readRecorder) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF && r.err == nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// fieldStream receives the elements of a list field of the response data.
+type fieldStream struct {
+	field string
+	fn    func(item json.RawMessage) error
+}
+
+// Stream sets a function called with each element of the list in the
+// given top-level field of the response data, such as items in
+// { items { id } }, as it is decoded. The elements are not decoded into
+// the response object, so that large lists are never held in memory at
+// once; the other fields of the data still are. If fn returns an error,
+// Run stops reading the response and returns a DecodeError wrapping it.
+func (req *Request) Stream(field string, fn func(item json.RawMessage) error) {
+	req.stream = &fieldStream{field: field, fn: fn}
+}
+
+// incrementalPayload is a single part of a multipart incremental delivery
+// response, used by servers supporting the @defer and @stream directives.
+// Both the current format, where subsequent payloads are listed under
+// incremental, and the earlier format, where each payload carries its own
+// path, are supported.
+type incrementalPayload struct {
+	Data        json.RawMessage        `json:"data"`
+	Items       []json.RawMessage      `json:"items"`
+	Path        []interface{}          `json:"path"`
+	Errors      Errors                 `json:"errors"`
+	Extensions  map[string]interface{} `json:"extensions"`
+	Incremental []incrementalPayload   `json:"incremental"`
+	HasNext     bool                   `json:"hasNext"`
+}
+
+// decodeIncremental reads every part of a multipart/mixed incremental
+// delivery response, merging the deferred and streamed results into the
+// initial data before decoding it into gr.
+func (c *Client) decodeIncremental(r io.Reader, boundary string, gr *graphResponse) error {
+	if boundary == "" {
+		return errors.New("multipart response has no boundary")
+	}
+	var data interface{}
+	hasNext := true
+	mr := multipart.NewReader(r, boundary)
+	for initial := true; hasNext; {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read multipart response: %w", err)
+		}
+		var payload incrementalPayload
+		if err := json.NewDecoder(part).Decode(&payload); err != nil {
+			if err == io.EOF {
+				// Skip empty parts, which some servers use as a heartbeat
+				continue
+			}
+			return err
+		}
+		if initial {
+			initial = false
+			if data, err = decodeValue(payload.Data); err != nil {
+				return err
+			}
+			gr.Errors = append(gr.Errors, payload.Errors...)
+			payload.Data, payload.Errors = nil, nil
+		}
+		if payload.Path != nil {
+			payload.Incremental = append(payload.Incremental, payload)
+		}
+		for key, value := range payload.Extensions {
+			if gr.Extensions == nil {
+				gr.Extensions = make(map[string]interface{})
+			}
+			gr.Extensions[key] = value
+		}
+		for _, inc := range payload.Incremental {
+			gr.Errors = append(gr.Errors, inc.Errors...)
+			if data, err = applyIncremental(data, inc); err != nil {
+				return err
+			}
+		}
+		hasNext = payload.HasNext
+	}
+	if hasNext {
+		return errors.New("multipart response ended before the final payload")
+	}
+	if data == nil {
+		return nil
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	gr.hasData = hasData(b)
+	return c.decodeData(b, gr.Data)
+}
+
+// applyIncremental merges a deferred or streamed payload into data.
+func applyIncremental(data interface{}, inc incrementalPayload) (interface{}, error) {
+	switch {
+	case inc.Items != nil:
+		// The last path element of a streamed payload is the index of
+		// the first item, the rest locates the list
+		if len(inc.Path) == 0 {
+			return data, nil
+		}
+		var items []interface{}
+		for _, raw := range inc.Items {
+			item, err := decodeValue(raw)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return patchValue(data, inc.Path[:len(inc.Path)-1], func(v interface{}) interface{} {
+			list, _ := v.([]interface{})
+			return append(list, items...)
+		}), nil
+	case inc.Data != nil:
+		patch, err := decodeValue(inc.Data)
+		if err != nil {
+			return nil, err
+		}
+		return patchValue(data, inc.Path, func(v interface{}) interface{} {
+			return mergeValues(v, patch)
+		}), nil
+	}
+	return data, nil
+}
+
+// decodeValue decodes raw into a generic value, preserving numbers exactly.
+func decodeValue(raw json.RawMessage) (interface{}, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// patchValue replaces the value at path within v with the result of fn.
+// Paths that do not exist in v are ignored.
+func patchValue(v interface{}, path []interface{}, fn func(interface{}) interface{}) interface{} {
+	if len(path) == 0 {
+		return fn(v)
+	}
+	switch node := v.(type) {
+	case map[string]interface{}:
+		if key, ok := path[0].(string); ok {
+			node[key] = patchValue(node[key], path[1:], fn)
+		}
+	case []interface{}:
+		if i, ok := path[0].(float64); ok && int(i) >= 0 && int(i) < len(node) {
+			node[int(i)] = patchValue(node[int(i)], path[1:], fn)
+		}
+	}
+	return v
+}
+
+// mergeValues deeply merges the fields of src into dst.
+func mergeValues(dst, src interface{}) interface{} {
+	dstMap, ok := dst.(map[string]interface{})
+	if !ok {
+		return src
+	}
+	srcMap, ok := src.(map[string]interface{})
+	if !ok {
+		return src
+	}
+	for key, value := range srcMap {
+		dstMap[key] = mergeValues(dstMap[key], value)
+	}
+	return dstMap
+}
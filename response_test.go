@@ -0,0 +1,482 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestDoUnexpectedContentType(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<html><body>Hello</body></html>`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	err := client.Run(ctx, NewRequest("query {}"), nil)
+	ctErr, ok := err.(*ContentTypeError)
+	is.True(ok)
+	is.Equal(ctErr.ContentType, "text/html")
+	is.Equal(ctErr.StatusCode, http.StatusOK)
+}
+
+func TestDoEmptyBody(t *testing.T) {
+	is := is.New(t)
+	var status int
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	for _, test := range []struct {
+		status int
+		body   string
+	}{
+		{http.StatusNoContent, ""},
+		{http.StatusOK, ""},
+		{http.StatusAccepted, " \n"},
+	} {
+		status, body = test.status, test.body
+		var resp struct{ Save bool }
+		is.NoErr(client.Run(ctx, NewRequest("mutation { save }"), &resp))
+		is.Equal(resp.Save, false)
+	}
+
+	// Data can still be required
+	status, body = http.StatusNoContent, ""
+	err := NewClient(srv.URL, RequireData()).Run(ctx, NewRequest("mutation { save }"), nil)
+	is.Equal(err, ErrNoData)
+
+	// Failed responses without a body are errors
+	status = http.StatusBadGateway
+	err = client.Run(ctx, NewRequest("mutation { save }"), nil)
+	httpErr, ok := err.(*HTTPError)
+	is.True(ok)
+	is.Equal(httpErr.StatusCode, http.StatusBadGateway)
+}
+
+func TestDoIncrementalDelivery(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.Header.Get("Accept"), "multipart/mixed;deferSpec=20220824, application/json; charset=utf-8")
+		w.Header().Set("Content-Type", `multipart/mixed; boundary="-"`)
+		io.WriteString(w, "\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n"+
+			`{"data":{"user":{"name":"Mat"},"repos":[{"name":"is"}]},"hasNext":true}`+
+			"\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n"+
+			`{"incremental":[{"data":{"email":"mat@example.com"},"path":["user"]},{"items":[{"name":"moq"}],"path":["repos",1]}],"hasNext":true}`+
+			"\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n"+
+			`{"incremental":[{"errors":[{"message":"no bio"}],"path":["user"]}],"hasNext":false}`+
+			"\r\n-----\r\n")
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, UseIncrementalDelivery())
+	var resp struct {
+		User struct {
+			Name  string
+			Email string
+		}
+		Repos []struct {
+			Name string
+		}
+	}
+	err := client.Run(ctx, NewRequest("query {}"), &resp)
+	is.Equal(err.Error(), "graphql: no bio")
+	is.Equal(resp.User.Name, "Mat")
+	is.Equal(resp.User.Email, "mat@example.com")
+	is.Equal(len(resp.Repos), 2)
+	is.Equal(resp.Repos[1].Name, "moq")
+}
+
+func TestDoPlainTextNotJSON(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, `upstream connect error`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	err := client.Run(ctx, NewRequest("query {}"), nil)
+	ctErr, ok := err.(*ContentTypeError)
+	is.True(ok)
+	is.Equal(ctErr.ContentType, "text/plain; charset=utf-8")
+}
+
+func TestDoIncrementalDeliveryTruncated(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", `multipart/mixed; boundary="-"`)
+		io.WriteString(w, "\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n"+
+			`{"data":{"user":{"name":"Mat"}},"hasNext":true}`+
+			"\r\n-----\r\n")
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, UseIncrementalDelivery())
+	err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.Equal(err.Error(), "graphql: failed to decode response: multipart response ended before the final payload")
+}
+
+func TestOnExtensions(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{
+			"data": {"value": "some data"},
+			"errors": [{"message": "partial"}],
+			"extensions": {"requestId": "abc123", "cost": {"requested": 10}}
+		}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	req := NewRequest("query {}")
+	var extensions map[string]interface{}
+	req.OnExtensions(func(ext map[string]interface{}) {
+		extensions = ext
+	})
+	err := client.Run(ctx, req, nil)
+	is.Equal(err.Error(), "graphql: partial")
+	is.Equal(extensions["requestId"], "abc123")
+	is.Equal(extensions["cost"], map[string]interface{}{"requested": float64(10)})
+}
+
+func TestOnHTTPResponse(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Cost")
+		w.Header().Set("X-Request-Id", "abc123")
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, `{"errors":[{"message":"slow down"}]}`)
+		w.Header().Set("X-Cost", "42")
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	req := NewRequest("query {}")
+	var statusCode int
+	var requestID, cost string
+	req.OnHTTPResponse(func(res *http.Response) {
+		statusCode = res.StatusCode
+		requestID = res.Header.Get("X-Request-Id")
+		cost = res.Trailer.Get("X-Cost")
+	})
+	err := client.Run(ctx, req, nil)
+	is.Equal(err.Error(), "graphql: slow down")
+	is.Equal(statusCode, http.StatusTooManyRequests)
+	is.Equal(requestID, "abc123")
+	is.Equal(cost, "42")
+}
+
+func TestStrictDecoding(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"value":"some data","renamed":"other"},"hasNext":false}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var resp struct {
+		Value string
+	}
+	err := NewClient(srv.URL).Run(ctx, NewRequest("query {}"), &resp)
+	is.NoErr(err)
+	is.Equal(resp.Value, "some data")
+
+	err = NewClient(srv.URL, UseStrictDecoding()).Run(ctx, NewRequest("query {}"), &resp)
+	is.Equal(err.Error(), `graphql: failed to decode response: json: unknown field "renamed"`)
+}
+
+func TestDataDecoder(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"value":"some data"},"errors":[{"message":"partial"}]}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var decoded string
+	client := NewClient(srv.URL, WithDataDecoder(func(data []byte, v interface{}) error {
+		decoded = string(data)
+		*(v.(*string)) = "custom"
+		return nil
+	}))
+	var resp string
+	err := client.Run(ctx, NewRequest("query {}"), &resp)
+	is.Equal(err.Error(), "graphql: partial") // errors are still decoded
+	is.Equal(decoded, `{"value":"some data"}`)
+	is.Equal(resp, "custom")
+}
+
+func TestStream(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"total":3,"items":[{"id":1},{"id":2},{"id":3}]}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	req := NewRequest("query {}")
+	var ids []int
+	req.Stream("items", func(item json.RawMessage) error {
+		var v struct {
+			ID int
+		}
+		if err := json.Unmarshal(item, &v); err != nil {
+			return err
+		}
+		ids = append(ids, v.ID)
+		return nil
+	})
+	var resp struct {
+		Total int
+		Items []interface{}
+	}
+	err := client.Run(ctx, req, &resp)
+	is.NoErr(err)
+	is.Equal(ids, []int{1, 2, 3})
+	is.Equal(resp.Total, 3)
+	is.Equal(len(resp.Items), 0) // streamed items are not decoded
+}
+
+func TestStreamStop(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"items":[1,2,3]}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	req := NewRequest("query {}")
+	var calls int
+	req.Stream("items", func(item json.RawMessage) error {
+		calls++
+		return io.ErrShortBuffer
+	})
+	err := client.Run(ctx, req, nil)
+	is.True(errors.Is(err, io.ErrShortBuffer))
+	is.Equal(calls, 1)
+}
+
+func TestOnRawData(t *testing.T) {
+	is := is.New(t)
+	body := `{"data":{"value":"some data"},"extensions":{"id":1}}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	req := NewRequest("query {}")
+	var rawData json.RawMessage
+	var rawBody []byte
+	req.OnRawData(func(data json.RawMessage) {
+		rawData = data
+	})
+	req.OnRawResponse(func(b []byte) {
+		rawBody = b
+	})
+	var resp struct {
+		Value string
+	}
+	err := client.Run(ctx, req, &resp)
+	is.NoErr(err)
+	is.Equal(string(rawData), `{"value":"some data"}`)
+	is.Equal(string(rawBody), body)
+	is.Equal(resp.Value, "some data")
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	is := is.New(t)
+	body := `{"data":{"value":"some data"}}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	// a body of exactly the limit is fine
+	client := NewClient(srv.URL, WithMaxResponseBytes(int64(len(body))))
+	err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+
+	client = NewClient(srv.URL, WithMaxResponseBytes(10))
+	err = client.Run(ctx, NewRequest("query {}"), nil)
+	var tooLarge *ResponseTooLargeError
+	is.True(errors.As(err, &tooLarge))
+	is.Equal(tooLarge.Limit, int64(10))
+	is.Equal(err.Error(), "graphql: response body larger than 10 bytes")
+}
+
+func TestInto(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"user":{"name":"Mat"},"repos":[{"name":"is"},{"name":"moq"}],"other":1}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	var user struct {
+		Name string
+	}
+	var repos []struct {
+		Name string
+	}
+	missing := "untouched"
+	err := client.Run(ctx, NewRequest("query {}"), Into("user", &user), Into("repos", &repos), Into("missing", &missing))
+	is.NoErr(err)
+	is.Equal(user.Name, "Mat")
+	is.Equal(len(repos), 2)
+	is.Equal(repos[1].Name, "moq")
+	is.Equal(missing, "untouched")
+
+	var resp struct{}
+	err = client.Run(ctx, NewRequest("query {}"), &resp, Into("user", &user))
+	is.Equal(err.Error(), "graphql: cannot decode into both a response object and Into targets")
+}
+
+func TestRequireData(t *testing.T) {
+	is := is.New(t)
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, RequireData())
+	for _, body = range []string{`{}`, `{"data":null}`, `{"data": { }}`} {
+		err := client.Run(ctx, NewRequest("query {}"), nil)
+		is.Equal(err, ErrNoData) // body
+		err = NewClient(srv.URL).Run(ctx, NewRequest("query {}"), nil)
+		is.NoErr(err) // body
+	}
+
+	body = `{"data":null,"errors":[{"message":"failed"}]}`
+	err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.Equal(err.Error(), "graphql: failed")
+
+	body = `{"data":{"value":"some data"}}`
+	var resp struct {
+		Value string
+	}
+	err = client.Run(ctx, NewRequest("query {}"), &resp)
+	is.NoErr(err)
+	is.Equal(resp.Value, "some data")
+}
+
+func TestLateErrors(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"items":[1,2]}}`)
+		w.(http.Flusher).Flush()
+		io.WriteString(w, "\n"+`{"errors":[{"message":"stream interrupted"}],"extensions":{"cost":3}}`+"\n")
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	req := NewRequest("query {}")
+	var extensions map[string]interface{}
+	req.OnExtensions(func(ext map[string]interface{}) {
+		extensions = ext
+	})
+	var resp struct {
+		Items []int
+	}
+	err := NewClient(srv.URL).Run(ctx, req, &resp)
+	is.Equal(err.Error(), "graphql: stream interrupted")
+	is.Equal(resp.Items, []int{1, 2})
+	is.Equal(extensions["cost"], float64(3))
+}
+
+func TestLateErrorsInvalid(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"items":[1,2]}}upstream reset`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	err := NewClient(srv.URL).Run(ctx, NewRequest("query {}"), nil)
+	var malformedErr *MalformedResponseError
+	is.True(errors.As(err, &malformedErr))
+}
+
+func TestTrailerErrors(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "GraphQL-Errors")
+		io.WriteString(w, `{"data":{"items":[1,2]}}`)
+		w.Header().Add("GraphQL-Errors", `[{"message":"stream interrupted","path":["items",2]}]`)
+		w.Header().Add("GraphQL-Errors", `upstream reset`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	errs, err := NewClient(srv.URL).RunPartial(ctx, NewRequest("query {}"), nil)
+	is.NoErr(err)
+	is.Equal(len(errs), 2)
+	is.Equal(errs[0].PathString(), "items.2")
+	is.Equal(errs[1].Message, "upstream reset")
+}
@@ -0,0 +1,55 @@
+package graphql
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Result is everything about the response to a request run with
+// RunResult.
+type Result struct {
+	// Data is the response object the data was decoded into.
+	Data interface{}
+	// Errors are the errors returned by the server alongside the data,
+	// if any.
+	Errors Errors
+	// Extensions are the extensions of the response, if any.
+	Extensions map[string]interface{}
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Header holds the headers of the response.
+	Header http.Header
+	// Trailer holds the trailers of the response, if any.
+	Trailer http.Header
+	// Duration is how long the request took, from sending it to
+	// decoding the response.
+	Duration time.Duration
+}
+
+// resultOption collects the details of the response into a Result.
+type resultOption struct {
+	result *Result
+}
+
+func (o resultOption) applyRun(settings *runSettings) {
+	settings.result = o.result
+}
+
+// RunResult executes the query like RunPartial, returning the details of
+// the response in a Result. The errors returned by the server are in the
+// Errors of the result, and err is only non-nil if the request failed. The
+// result is never nil, and holds whatever was received before the request
+// failed.
+func (c *Client) RunResult(ctx context.Context, req *Request, resp interface{}, opts ...RunOption) (*Result, error) {
+	result := &Result{Data: resp}
+	opts = append(opts[:len(opts):len(opts)], resultOption{result: result})
+	start := time.Now()
+	err := c.Run(ctx, req, resp, opts...)
+	result.Duration = time.Since(start)
+	if errs, ok := err.(Errors); ok {
+		result.Errors = errs
+		return result, nil
+	}
+	return result, err
+}
@@ -0,0 +1,63 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestRunResult(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "abc123")
+		io.WriteString(w, `{
+			"data": {"user": {"name": "Mat", "avatar": null}},
+			"errors": [{"message": "no avatar", "path": ["user", "avatar"]}],
+			"extensions": {"cost": 3}
+		}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	var resp struct {
+		User struct {
+			Name string
+		}
+	}
+	result, err := client.RunResult(ctx, NewRequest("query {}"), &resp)
+	is.NoErr(err)
+	is.Equal(result.Data, &resp)
+	is.Equal(resp.User.Name, "Mat")
+	is.Equal(len(result.Errors), 1)
+	is.Equal(len(result.Errors.ForPath("user", "avatar")), 1)
+	is.Equal(result.Extensions["cost"], float64(3))
+	is.Equal(result.StatusCode, http.StatusOK)
+	is.Equal(result.Header.Get("X-Request-Id"), "abc123")
+	is.True(result.Duration > 0)
+}
+
+func TestRunResultFailure(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	result, err := NewClient(srv.URL).RunResult(ctx, NewRequest("query {}"), nil)
+	var httpErr *HTTPError
+	is.True(errors.As(err, &httpErr))
+	is.Equal(result.StatusCode, http.StatusBadGateway)
+	is.Equal(len(result.Errors), 0)
+}
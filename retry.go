@@ -0,0 +1,232 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxBackoff bounds the delay between two attempts at a request.
+const maxBackoff = 30 * time.Second
+
+// defaultBackoff is the delay before the first retry of requests retried
+// with WithRetries, when the client has no retry policy.
+const defaultBackoff = 100 * time.Millisecond
+
+// WithRetry retries queries that fail with a transient error, as
+// classified by IsRetriable, such as a connection reset, a 429, 502 or
+// 503 response or a THROTTLED GraphQL error, making up to maxAttempts
+// attempts in all. The delay before each retry starts at backoff and
+// doubles after each attempt, up to 30 seconds, with random jitter so that
+// clients failing together do not retry together, unless another Backoff
+// is set with WithBackoff. When a 429 or 503 response has a Retry-After
+// header, the delay it sets is used instead. No retry is made that would
+// not start before the deadline of the context.
+//
+// Mutations and subscriptions are not retried, since sending them twice
+// may not be safe, unless marked with Request.MarkIdempotent.
+func WithRetry(maxAttempts int, backoff time.Duration) ClientOption {
+	return func(client *Client) {
+		client.retries = maxAttempts - 1
+		client.backoff = backoff
+	}
+}
+
+// retryPolicy is how a request is retried, in place of the retry policy
+// of the client.
+type retryPolicy struct {
+	// retries is how many times the request may be retried
+	retries int
+	// backoff decides the delay before each retry, or is nil to keep the
+	// backoff of the client
+	backoff Backoff
+}
+
+// Retry retries the request, if it is a query or marked idempotent, up to
+// maxAttempts attempts in all with a delay starting at backoff, in place
+// of the retry policy of the client set with WithRetry. A zero backoff
+// keeps the backoff of the client. The WithRetries option of a call takes
+// precedence over the number of attempts.
+//
+//	req.Retry(5, 50*time.Millisecond) // a read that is cheap to retry
+func (req *Request) Retry(maxAttempts int, backoff time.Duration) {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	req.retry = &retryPolicy{retries: maxAttempts - 1}
+	if backoff > 0 {
+		req.retry.backoff = ExponentialBackoff(backoff, maxBackoff)
+	}
+}
+
+// NoRetry stops the request from being retried, whatever the retry policy
+// of the client.
+func (req *Request) NoRetry() {
+	req.Retry(1, 0)
+}
+
+// retryPolicy returns the retry policy of req, given the retries set by
+// the options of the call, which are negative if unset.
+func (c *Client) retryPolicy(req *Request, retries int) retryPolicy {
+	policy := retryPolicy{retries: c.retries, backoff: c.backoffStrategy}
+	if policy.backoff == nil {
+		policy.backoff = ExponentialBackoff(c.backoff, maxBackoff)
+	}
+	if req.retry != nil {
+		policy.retries = req.retry.retries
+		if req.retry.backoff != nil {
+			policy.backoff = req.retry.backoff
+		}
+	}
+	if retries >= 0 {
+		policy.retries = retries
+	}
+	return policy
+}
+
+// delay returns the delay before retrying a request that has failed
+// attempts times, the last time with err, given the delay before the
+// previous retry. The server can set the delay with a Retry-After header
+// on 429 and 503 responses.
+func (p retryPolicy) delay(attempts int, previous time.Duration, err error) time.Duration {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && (httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode == http.StatusServiceUnavailable) {
+		if d, ok := httpErr.RetryAfter(); ok {
+			return d
+		}
+	}
+	return p.backoff.Delay(attempts, previous, err)
+}
+
+// sleep waits for d with clock, returning false without waiting if ctx
+// would be done before then by clock, or as soon as ctx is done.
+func sleep(ctx context.Context, clock Clock, d time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && deadline.Sub(clock.Now()) < d {
+		return false
+	}
+	return clock.Sleep(ctx, d)
+}
+
+// retryBudgetReserve is the number of retries a retry budget allows
+// before any requests have been made, and the most it can save up.
+const retryBudgetReserve = 10
+
+// retryBudget limits the retries of a client to a fraction of its
+// requests. Each request adds ratio to the balance, up to the reserve, and
+// each retry takes one from it.
+type retryBudget struct {
+	ratio float64
+
+	mu      sync.Mutex
+	balance float64
+	// latency is the moving average of the duration of attempts
+	latency time.Duration
+}
+
+// deposit adds to the balance for a new request.
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.balance += b.ratio
+	if b.balance > retryBudgetReserve {
+		b.balance = retryBudgetReserve
+	}
+}
+
+// observe records the duration of an attempt.
+func (b *retryBudget) observe(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.latency == 0 {
+		b.latency = d
+	} else {
+		b.latency += (d - b.latency) / 8
+	}
+}
+
+// withdraw takes a retry from the balance, returning false if there is
+// none left, or if the retry would not be expected to finish before ctx
+// is done after waiting for delay from now.
+func (b *retryBudget) withdraw(ctx context.Context, now time.Time, delay time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if deadline, ok := ctx.Deadline(); ok && deadline.Sub(now) < delay+b.latency {
+		return false
+	}
+	if b.balance < 1 {
+		return false
+	}
+	b.balance--
+	return true
+}
+
+// WithRetryBudget limits the retries made by the client to a fraction of
+// its requests, such as 0.1 for one retry per ten requests, so that
+// retrying cannot multiply the load on a server that is already
+// struggling. Up to 10 retries can be saved up for bursts of failures. The
+// client also keeps track of how long attempts take, and does not retry
+// when the attempt would not be expected to finish before the deadline of
+// the context.
+func WithRetryBudget(ratio float64) ClientOption {
+	return func(client *Client) {
+		client.retryBudget = &retryBudget{ratio: ratio, balance: retryBudgetReserve}
+	}
+}
+
+// fileRewinder rewinds the files of a request, including its Upload
+// variables, to where they were before the first attempt, so that they
+// can be sent again.
+type fileRewinder struct {
+	files []File
+	// offsets are the offsets of the files, or -1 for those that are not
+	// an io.Seeker
+	offsets []int64
+}
+
+// newFileRewinder returns a fileRewinder for the files of req, or nil if
+// it has none.
+func newFileRewinder(req *Request) *fileRewinder {
+	files := append([]File(nil), req.files...)
+	uploads := req.fillMultipartRequestSpecQuery()
+	for i := 0; i < len(uploads.uploads); i++ {
+		key := "upload" + strconv.Itoa(i)
+		upload := uploads.uploads[key]
+		files = append(files, File{Field: uploads.Map[key][0], Name: upload.Name, R: upload.R})
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	r := &fileRewinder{files: files, offsets: make([]int64, len(files))}
+	for i, file := range files {
+		r.offsets[i] = -1
+		if seeker, ok := file.R.(io.Seeker); ok {
+			if offset, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+				r.offsets[i] = offset
+			}
+		}
+	}
+	return r
+}
+
+// rewind rewinds the files after an attempt failed with err, returning a
+// RewindError if one cannot be rewound.
+func (r *fileRewinder) rewind(err error) error {
+	if r == nil {
+		return nil
+	}
+	for i, file := range r.files {
+		if r.offsets[i] < 0 {
+			return &RewindError{Field: file.Field, Name: file.Name, Err: err}
+		}
+	}
+	for i, file := range r.files {
+		if _, seekErr := file.R.(io.Seeker).Seek(r.offsets[i], io.SeekStart); seekErr != nil {
+			return &RewindError{Field: file.Field, Name: file.Name, SeekErr: seekErr, Err: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,300 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestWithRetry(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		calls++
+		switch calls {
+		case 1:
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			io.WriteString(w, `{"errors":[{"message":"slow down","extensions":{"code":"THROTTLED"}}]}`)
+		default:
+			io.WriteString(w, `{"data":{"value":"some data"}}`)
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithRetry(3, time.Millisecond))
+	var resp struct{ Value string }
+	is.NoErr(client.Run(ctx, NewRequest("query { value }"), &resp))
+	is.Equal(calls, 3)
+	is.Equal(resp.Value, "some data")
+
+	calls = 0
+	err := client.Run(ctx, NewRequest("query { value }"), &resp, WithRetries(0))
+	is.True(err != nil)
+	is.Equal(calls, 1) // the call disables the retries of the client
+
+	calls = 0
+	err = client.Run(ctx, NewRequest("mutation { value }"), nil)
+	is.True(err != nil)
+	is.Equal(calls, 1) // mutations are not retried
+}
+
+func TestRequestRetry(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithRetry(3, time.Millisecond))
+	req := NewRequest("query {}")
+	req.NoRetry()
+	is.True(client.Run(ctx, req, nil) != nil)
+	is.Equal(calls, 1) // the request opts out of the retries of the client
+
+	calls = 0
+	req = NewRequest("query {}")
+	req.Retry(5, 0)
+	is.True(client.Run(ctx, req, nil) != nil)
+	is.Equal(calls, 5) // with the backoff of the client
+
+	calls = 0
+	is.True(client.Run(ctx, req.Clone(), nil, WithRetries(1)) != nil)
+	is.Equal(calls, 2) // the call takes precedence
+
+	calls = 0
+	req = NewRequest("query {}")
+	req.Retry(2, time.Millisecond)
+	is.True(NewClient(srv.URL).Run(ctx, req, nil) != nil)
+	is.Equal(calls, 2) // without a client retry policy
+}
+
+func TestWithRetryBudget(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithRetry(4, time.Microsecond), WithRetryBudget(0.5))
+	for i := 0; i < 4; i++ {
+		is.True(client.Run(ctx, NewRequest("query {}"), nil) != nil)
+	}
+	// The reserve and the deposits of the first requests allow 11 retries
+	is.Equal(calls, 4+11)
+
+	calls = 0
+	is.True(client.Run(ctx, NewRequest("query {}"), nil) != nil)
+	is.True(client.Run(ctx, NewRequest("query {}"), nil) != nil)
+	is.Equal(calls, 3) // one retry for two requests
+}
+
+func TestSleepClock(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+
+	// Waits are measured against the clock, not the real time
+	clock := &fakeClock{now: deadline.Add(-time.Hour)}
+	is.True(sleep(ctx, clock, 10*time.Minute))
+	is.Equal(clock.slept, 10*time.Minute)
+	clock = &fakeClock{now: deadline.Add(-time.Second)}
+	is.True(!sleep(ctx, clock, 10*time.Second))
+	is.Equal(clock.slept, time.Duration(0))
+}
+
+func TestRetryBudgetLatency(t *testing.T) {
+	is := is.New(t)
+	budget := &retryBudget{ratio: 1, balance: retryBudgetReserve}
+	budget.observe(100 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	is.True(!budget.withdraw(ctx, time.Now(), 0)) // the attempt would not finish in time
+	is.True(budget.withdraw(context.Background(), time.Now(), 0))
+	is.Equal(budget.balance, float64(retryBudgetReserve-1))
+
+	// The time left is measured from the time given, such as the time of
+	// the clock of a client
+	deadline, _ := ctx.Deadline()
+	is.True(budget.withdraw(ctx, deadline.Add(-time.Second), 0))
+	is.True(!budget.withdraw(ctx, deadline, 0))
+}
+
+func TestRetryRewindsFiles(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.NoErr(r.ParseMultipartForm(1 << 20))
+		file, _, err := r.FormFile("file")
+		is.NoErr(err)
+		b, err := io.ReadAll(file)
+		is.NoErr(err)
+		is.Equal(string(b), "content") // every attempt sends the whole file
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, UseMultipartForm(), WithRetry(2, time.Millisecond))
+	req := NewRequest("query {}")
+	r := strings.NewReader("skipped content")
+	r.Seek(8, io.SeekStart)
+	req.File("file", "a.txt", r)
+	is.NoErr(client.Run(ctx, req, nil))
+	is.Equal(calls, 2)
+
+	// Files that cannot be rewound stop the request from being retried
+	calls = 0
+	req = NewRequest("query {}")
+	req.File("file", "a.txt", io.MultiReader(strings.NewReader("content")))
+	err := client.Run(ctx, req, nil)
+	var rewindErr *RewindError
+	is.True(errors.As(err, &rewindErr))
+	is.Equal(rewindErr.Field, "file")
+	is.Equal(rewindErr.Name, "a.txt")
+	var httpErr *HTTPError
+	is.True(errors.As(err, &httpErr))
+	is.Equal(httpErr.StatusCode, http.StatusServiceUnavailable)
+	is.True(!IsRetriable(err))
+	is.Equal(calls, 1)
+}
+
+func TestRetryRewindsUploads(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, UseMultipartRequestSpec(), WithRetry(2, time.Millisecond))
+	req := NewRequest("query ($avatar: Upload!) { check(avatar: $avatar) }")
+	req.Var("avatar", Upload{Name: "a.png", R: io.LimitReader(strings.NewReader("png"), 3)})
+	err := client.Run(ctx, req, nil)
+	var rewindErr *RewindError
+	is.True(errors.As(err, &rewindErr))
+	is.Equal(rewindErr.Field, "variables.avatar")
+	is.Equal(calls, 1)
+}
+
+func TestWithRetryDeadline(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithRetry(3, time.Second))
+	start := time.Now()
+	err := client.Run(ctx, NewRequest("query {}"), nil)
+	httpErr, ok := err.(*HTTPError)
+	is.True(ok)
+	is.Equal(httpErr.StatusCode, http.StatusServiceUnavailable)
+	is.Equal(calls, 1)                               // the retry would not start before the deadline
+	is.True(time.Since(start) < 50*time.Millisecond) // without waiting for the deadline
+}
+
+func TestRetryDelay(t *testing.T) {
+	is := is.New(t)
+	client := NewClient("", WithRetry(10, 100*time.Millisecond))
+	policy := client.retryPolicy(NewRequest("{ a }"), -1)
+	for attempts, max := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 20: maxBackoff} {
+		for i := 0; i < 10; i++ {
+			delay := policy.delay(attempts, 0, nil)
+			is.True(delay >= max/2)
+			is.True(delay <= max)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	var last time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		calls++
+		if calls == 1 {
+			last = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		is.True(time.Since(last) >= time.Second) // the server set the delay
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithRetry(2, time.Millisecond))
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	is.Equal(calls, 2)
+
+	// The delay is bounded by the context
+	calls = 0
+	ctx, cancel = context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	err := client.Run(ctx, NewRequest("query {}"), nil)
+	is.True(err != nil)
+	is.Equal(calls, 1)
+}
+
+func TestHTTPErrorRetryAfter(t *testing.T) {
+	is := is.New(t)
+	err := &HTTPError{Header: http.Header{"Retry-After": {"120"}}}
+	d, ok := err.RetryAfter()
+	is.True(ok)
+	is.Equal(d, 2*time.Minute)
+
+	err.Header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	d, ok = err.RetryAfter()
+	is.True(ok)
+	is.True(d > 59*time.Minute && d <= time.Hour)
+
+	err.Header.Set("Retry-After", "soon")
+	_, ok = err.RetryAfter()
+	is.True(!ok)
+}
@@ -0,0 +1,61 @@
+package graphql
+
+import "time"
+
+// Transport is the way a request is sent to the server.
+type Transport int
+
+const (
+	// defaultTransport is the transport set by the options of the client
+	defaultTransport Transport = iota
+	// JSON sends the request as a JSON body.
+	JSON
+	// GET sends queries as the query parameters of a GET request, and
+	// other operations in the body, as a raw body if the client uses
+	// UseRawBody and as JSON otherwise.
+	GET
+	// RawBody sends the document as the body.
+	RawBody
+	// MultipartForm sends the request as the fields of a multipart form.
+	MultipartForm
+	// MultipartRequestSpec sends the request following the GraphQL
+	// multipart request specification.
+	MultipartRequestSpec
+)
+
+// runOption is a RunOption modifying the settings of a call.
+type runOption func(settings *runSettings)
+
+func (o runOption) applyRun(settings *runSettings) {
+	o(settings)
+}
+
+// WithTimeout bounds a call to Run by d, including any retries, in place
+// of any timeout set with Request.Timeout. The call fails with a
+// TimeoutError when it takes longer.
+func WithTimeout(d time.Duration) RunOption {
+	return runOption(func(settings *runSettings) {
+		settings.timeout = d
+	})
+}
+
+// WithRetries retries a query up to n more times while it fails with an
+// error the client classifies as retriable, in place of the retries set by
+// the request or the client, and with their backoff. Other operations
+// are not retried, since sending them twice may not be safe, unless
+// marked with Request.MarkIdempotent.
+func WithRetries(n int) RunOption {
+	return runOption(func(settings *runSettings) {
+		settings.retries = n
+	})
+}
+
+// WithTransport sends the request with transport, in place of the
+// transport set by the options of the client.
+//
+//	err := client.Run(ctx, req, &resp, graphql.WithTransport(graphql.GET))
+func WithTransport(transport Transport) RunOption {
+	return runOption(func(settings *runSettings) {
+		settings.transport = transport
+	})
+}
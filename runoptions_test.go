@@ -0,0 +1,177 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestWithTimeout(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	err := client.Run(ctx, NewRequest("query {}"), nil, WithTimeout(50*time.Millisecond))
+	var timeoutErr *TimeoutError
+	is.True(errors.As(err, &timeoutErr))
+	is.Equal(timeoutErr.Timeout, 50*time.Millisecond)
+	is.True(errors.Is(err, context.DeadlineExceeded))
+	is.NoErr(ctx.Err()) // the context of the caller is not done
+}
+
+func TestRequestTimeout(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(100 * time.Millisecond):
+			io.WriteString(w, `{"data":{}}`)
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	req := NewRequest("query {}")
+	req.Timeout(20 * time.Millisecond)
+	err := client.Run(ctx, req, nil)
+	var timeoutErr *TimeoutError
+	is.True(errors.As(err, &timeoutErr))
+	is.Equal(timeoutErr.Timeout, 20*time.Millisecond)
+
+	// The timeout of the call takes precedence
+	is.NoErr(client.Run(ctx, req, nil, WithTimeout(time.Second)))
+
+	// The caller giving up is not a timeout of the request
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req.Timeout(time.Second)
+	err = client.Run(ctx, req, nil)
+	is.True(errors.Is(err, context.DeadlineExceeded))
+	is.True(!errors.As(err, &timeoutErr))
+}
+
+func TestWithDefaultTimeout(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(100 * time.Millisecond):
+			io.WriteString(w, `{"data":{}}`)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, WithDefaultTimeout(20*time.Millisecond))
+	err := client.Run(context.Background(), NewRequest("query {}"), nil)
+	var timeoutErr *TimeoutError
+	is.True(errors.As(err, &timeoutErr))
+	is.Equal(timeoutErr.Timeout, 20*time.Millisecond)
+
+	// Deadlines of the caller and timeouts of the request take precedence
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	req := NewRequest("query {}")
+	req.Timeout(time.Second)
+	is.NoErr(client.Run(context.Background(), req, nil))
+}
+
+func TestWithRetries(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	var resp struct{ Value string }
+	err := client.Run(ctx, NewRequest("query { value }"), &resp, WithRetries(2))
+	is.NoErr(err)
+	is.Equal(calls, 3)
+	is.Equal(resp.Value, "some data")
+
+	calls = 0
+	err = client.Run(ctx, NewRequest("query { value }"), &resp, WithRetries(1))
+	is.True(err != nil)
+	is.Equal(calls, 2)
+
+	calls = 0
+	err = client.Run(ctx, NewRequest("mutation { value }"), nil, WithRetries(2))
+	is.True(err != nil)
+	is.Equal(calls, 1) // mutations are not retried
+}
+
+func TestWithRetriesPermanentError(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		calls++
+		io.WriteString(w, `{"errors":[{"message":"invalid","extensions":{"code":"GRAPHQL_VALIDATION_FAILED"}}]}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	err := NewClient(srv.URL).Run(ctx, NewRequest("query {}"), nil, WithRetries(2))
+	is.True(HasErrorCode(err, CodeGraphQLValidationFailed))
+	is.Equal(calls, 1)
+}
+
+func TestWithTransport(t *testing.T) {
+	is := is.New(t)
+	var method string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		method = r.Method
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil, WithTransport(GET)))
+	is.Equal(method, http.MethodGet)
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+	is.Equal(method, http.MethodPost)
+
+	client = NewClient(srv.URL, UseGET())
+	is.NoErr(client.Run(ctx, NewRequest("query {}"), nil, WithTransport(JSON)))
+	is.Equal(method, http.MethodPost)
+
+	req := NewRequest("mutation {}")
+	req.File("file", "a.txt", nil)
+	err := client.Run(ctx, req, nil)
+	is.True(err != nil) // files need a multipart transport
+}
@@ -0,0 +1,333 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithScalarDecoder registers a function decoding the JSON values of a
+// custom scalar into values of type T, used for every value of type T in
+// the response object, such as time.Time for a DateTime scalar or
+// *big.Int for a BigInt scalar, instead of the decoding of encoding/json:
+//
+//	client := graphql.NewClient(endpoint,
+//		graphql.WithScalarDecoder(graphql.DecodeBigInt),
+//		graphql.WithScalarDecoder(graphql.DateTimeDecoder("2006-01-02 15:04:05")),
+//	)
+//
+// Null values leave the value untouched. Registered decoders have no
+// effect on clients using WithDataDecoder.
+func WithScalarDecoder[T any](decode func(data json.RawMessage) (T, error)) ClientOption {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	return func(client *Client) {
+		if client.scalars == nil {
+			client.scalars = &scalarRegistry{decoders: make(map[reflect.Type]func(json.RawMessage, reflect.Value) error)}
+		}
+		client.scalars.decoders[typ] = func(data json.RawMessage, v reflect.Value) error {
+			value, err := decode(data)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(&value).Elem())
+			return nil
+		}
+	}
+}
+
+// DecodeBigInt decodes a BigInt scalar, sent either as a JSON number or
+// as a string, for use with WithScalarDecoder.
+func DecodeBigInt(data json.RawMessage) (*big.Int, error) {
+	s := string(bytes.TrimSpace(data))
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, err
+		}
+	}
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid BigInt %s", data)
+	}
+	return n, nil
+}
+
+// DateTimeDecoder returns a function decoding a date and time scalar sent
+// as a string in any of the given layouts, tried in order, for use with
+// WithScalarDecoder. Without layouts, RFC 3339 is used.
+func DateTimeDecoder(layouts ...string) func(data json.RawMessage) (time.Time, error) {
+	if len(layouts) == 0 {
+		layouts = []string{time.RFC3339Nano}
+	}
+	return func(data json.RawMessage) (time.Time, error) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return time.Time{}, err
+		}
+		var err error
+		for _, layout := range layouts {
+			var t time.Time
+			if t, err = time.Parse(layout, s); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, err
+	}
+}
+
+// scalarRegistry decodes response data using the decoders registered with
+// WithScalarDecoder.
+type scalarRegistry struct {
+	decoders map[reflect.Type]func(data json.RawMessage, v reflect.Value) error
+	// uses caches whether values of a type contain registered types
+	uses sync.Map
+}
+
+// clone returns a copy of the registry, which may be nil.
+func (r *scalarRegistry) clone() *scalarRegistry {
+	if r == nil {
+		return nil
+	}
+	clone := &scalarRegistry{decoders: make(map[reflect.Type]func(json.RawMessage, reflect.Value) error, len(r.decoders))}
+	for typ, decode := range r.decoders {
+		clone.decoders[typ] = decode
+	}
+	return clone
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// decodeData decodes data into v, which must be a non-nil pointer.
+func (r *scalarRegistry) decodeData(data json.RawMessage, v interface{}, strict bool) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return unmarshalJSON(data, v, strict)
+	}
+	return r.decode(data, rv.Elem(), strict)
+}
+
+// decode decodes data into the addressable value v, using the registered
+// decoders for the values of registered types within it, and
+// encoding/json for the rest.
+func (r *scalarRegistry) decode(data json.RawMessage, v reflect.Value, strict bool) error {
+	t := v.Type()
+	null := string(bytes.TrimSpace(data)) == "null"
+	if decode, ok := r.decoders[t]; ok {
+		if null {
+			return nil
+		}
+		return decode(data, v)
+	}
+	if !r.contains(t) {
+		return unmarshalJSON(data, v.Addr().Interface(), strict)
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		if null {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return r.decode(data, v.Elem(), strict)
+	case reflect.Slice, reflect.Array:
+		if null {
+			if t.Kind() == reflect.Slice {
+				v.Set(reflect.Zero(t))
+			}
+			return nil
+		}
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return err
+		}
+		if t.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(t, len(items), len(items)))
+		}
+		for i := 0; i < v.Len(); i++ {
+			if i >= len(items) {
+				v.Index(i).Set(reflect.Zero(t.Elem()))
+				continue
+			}
+			if err := r.decode(items[i], v.Index(i), strict); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		if null {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+		}
+		for key, raw := range fields {
+			elem := reflect.New(t.Elem()).Elem()
+			if err := r.decode(raw, elem, strict); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), elem)
+		}
+		return nil
+	case reflect.Struct:
+		if null {
+			return nil
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return err
+		}
+		for name, raw := range fields {
+			index := findField(t, name)
+			if index == nil {
+				if strict {
+					return fmt.Errorf("json: unknown field %q", name)
+				}
+				continue
+			}
+			field := fieldByIndex(v, index)
+			if !field.IsValid() {
+				continue
+			}
+			if err := r.decode(raw, field, strict); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return unmarshalJSON(data, v.Addr().Interface(), strict)
+}
+
+// contains reports whether values of type t contain values of registered
+// types that encoding/json would decode.
+func (r *scalarRegistry) contains(t reflect.Type) bool {
+	if uses, ok := r.uses.Load(t); ok {
+		return uses.(bool)
+	}
+	uses := r.containsType(t, make(map[reflect.Type]bool))
+	r.uses.Store(t, uses)
+	return uses
+}
+
+func (r *scalarRegistry) containsType(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if _, ok := r.decoders[t]; ok {
+		return true
+	}
+	if visiting[t] || t.Implements(unmarshalerType) || reflect.PointerTo(t).Implements(unmarshalerType) {
+		return false
+	}
+	visiting[t] = true
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return r.containsType(t.Elem(), visiting)
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && r.containsType(t.Elem(), visiting)
+	case reflect.Struct:
+		for _, field := range structFields(t) {
+			if r.containsType(t.FieldByIndex(field.index).Type, visiting) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// structField is a field of a struct decoded from JSON.
+type structField struct {
+	name  string
+	index []int
+}
+
+// structFields returns the fields of t that encoding/json decodes,
+// including the fields of embedded structs that are not shadowed.
+func structFields(t reflect.Type) []structField {
+	var fields, embedded []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for _, inner := range structFields(ft) {
+				embedded = append(embedded, structField{name: inner.name, index: append([]int{i}, inner.index...)})
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, structField{name: name, index: []int{i}})
+	}
+	for _, field := range embedded {
+		if findFieldIn(fields, field.name, true) == nil {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// findField returns the index of the field of t for the JSON key name,
+// matched exactly or else case insensitively, like encoding/json.
+func findField(t reflect.Type, name string) []int {
+	fields := structFields(t)
+	if index := findFieldIn(fields, name, true); index != nil {
+		return index
+	}
+	return findFieldIn(fields, name, false)
+}
+
+func findFieldIn(fields []structField, name string, exact bool) []int {
+	for _, field := range fields {
+		if field.name == name || !exact && strings.EqualFold(field.name, name) {
+			return field.index
+		}
+	}
+	return nil
+}
+
+// fieldByIndex returns the field of v with the given index, allocating
+// any nil embedded struct pointers on the way. It returns the zero Value
+// if a pointer to an unexported struct is nil, which cannot be set.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// unmarshalJSON decodes data into v with encoding/json.
+func unmarshalJSON(data json.RawMessage, v interface{}, strict bool) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
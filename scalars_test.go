@@ -0,0 +1,86 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+type scalarsEmbedded struct {
+	CreatedAt time.Time
+}
+
+func TestScalarDecoder(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{
+			"balance": "123456789012345678901234567890",
+			"createdAt": "2024-01-02 03:04:05",
+			"updatedAt": null,
+			"history": [{"at": "2024-01-03 00:00:00", "amount": 12}],
+			"byDay": {"mon": "2024-01-01 00:00:00"},
+			"name": "Mat"
+		}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL,
+		WithScalarDecoder(DecodeBigInt),
+		WithScalarDecoder(DateTimeDecoder("2006-01-02 15:04:05")),
+	)
+	var resp struct {
+		scalarsEmbedded
+		Balance   *big.Int
+		UpdatedAt *time.Time
+		History   []struct {
+			At     time.Time `json:"at"`
+			Amount *big.Int
+		}
+		ByDay map[string]time.Time
+		Name  string
+	}
+	err := client.Run(ctx, NewRequest("query {}"), &resp)
+	is.NoErr(err)
+	is.Equal(resp.Balance.String(), "123456789012345678901234567890")
+	is.Equal(resp.CreatedAt, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	is.True(resp.UpdatedAt == nil)
+	is.Equal(len(resp.History), 1)
+	is.Equal(resp.History[0].At, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC))
+	is.Equal(resp.History[0].Amount.Int64(), int64(12))
+	is.Equal(resp.ByDay["mon"], time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	is.Equal(resp.Name, "Mat")
+}
+
+func TestScalarDecoderError(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"balance":"lots","other":1}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var resp struct {
+		Balance *big.Int
+	}
+	client := NewClient(srv.URL, WithScalarDecoder(DecodeBigInt))
+	err := client.Run(ctx, NewRequest("query {}"), &resp)
+	is.Equal(err.Error(), "graphql: failed to decode response: invalid BigInt \"lots\"")
+
+	client = NewClient(srv.URL, UseStrictDecoding(), WithScalarDecoder(func(data json.RawMessage) (*big.Int, error) {
+		return big.NewInt(1), nil
+	}))
+	err = client.Run(ctx, NewRequest("query {}"), &resp)
+	is.Equal(err.Error(), `graphql: failed to decode response: json: unknown field "other"`)
+}
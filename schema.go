@@ -0,0 +1,295 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// introspectionQuery is the standard introspection query, selecting the
+// fields every server supports.
+const introspectionQuery = `query IntrospectionQuery {
+	__schema {
+		queryType { name }
+		mutationType { name }
+		subscriptionType { name }
+		types { ...FullType }
+		directives {
+			name
+			description
+			locations
+			args { ...InputValue }
+		}
+	}
+}
+
+fragment FullType on __Type {
+	kind
+	name
+	description
+	fields(includeDeprecated: true) {
+		name
+		description
+		args { ...InputValue }
+		type { ...TypeRef }
+		isDeprecated
+		deprecationReason
+	}
+	inputFields { ...InputValue }
+	interfaces { ...TypeRef }
+	enumValues(includeDeprecated: true) {
+		name
+		description
+		isDeprecated
+		deprecationReason
+	}
+	possibleTypes { ...TypeRef }
+}
+
+fragment InputValue on __InputValue {
+	name
+	description
+	type { ...TypeRef }
+	defaultValue
+}
+
+fragment TypeRef on __Type {
+	kind
+	name
+	ofType {
+		kind
+		name
+		ofType {
+			kind
+			name
+			ofType {
+				kind
+				name
+				ofType {
+					kind
+					name
+					ofType {
+						kind
+						name
+						ofType {
+							kind
+							name
+							ofType { kind name }
+						}
+					}
+				}
+			}
+		}
+	}
+}`
+
+// Schema is the schema of a server, as returned by the introspection
+// query.
+type Schema struct {
+	// QueryType, MutationType and SubscriptionType are the names of the
+	// root operation types, empty for those the schema does not have.
+	QueryType        string
+	MutationType     string
+	SubscriptionType string
+	Types            []*SchemaType
+	Directives       []*SchemaDirective
+
+	types map[string]*SchemaType
+}
+
+// Type returns the type with the given name, or nil if the schema has no
+// such type.
+func (s *Schema) Type(name string) *SchemaType {
+	return s.types[name]
+}
+
+// SchemaType is a named type of a schema.
+type SchemaType struct {
+	// Kind is the kind of the type, such as OBJECT or ENUM.
+	Kind          string         `json:"kind"`
+	Name          string         `json:"name"`
+	Description   string         `json:"description"`
+	Fields        []*SchemaField `json:"fields"`
+	InputFields   []*SchemaInput `json:"inputFields"`
+	Interfaces    []*TypeRef     `json:"interfaces"`
+	EnumValues    []*SchemaEnum  `json:"enumValues"`
+	PossibleTypes []*TypeRef     `json:"possibleTypes"`
+}
+
+// Field returns the field of the type with the given name, or nil if it
+// has no such field.
+func (t *SchemaType) Field(name string) *SchemaField {
+	for _, field := range t.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// SchemaField is a field of an object or interface type.
+type SchemaField struct {
+	Name              string         `json:"name"`
+	Description       string         `json:"description"`
+	Args              []*SchemaInput `json:"args"`
+	Type              *TypeRef       `json:"type"`
+	IsDeprecated      bool           `json:"isDeprecated"`
+	DeprecationReason string         `json:"deprecationReason"`
+}
+
+// SchemaInput is an argument of a field or directive, or a field of an
+// input object type.
+type SchemaInput struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Type        *TypeRef `json:"type"`
+	// DefaultValue is the default value, as a GraphQL literal, or nil if
+	// there is none.
+	DefaultValue *string `json:"defaultValue"`
+}
+
+// SchemaEnum is a value of an enum type.
+type SchemaEnum struct {
+	Name              string `json:"name"`
+	Description       string `json:"description"`
+	IsDeprecated      bool   `json:"isDeprecated"`
+	DeprecationReason string `json:"deprecationReason"`
+}
+
+// SchemaDirective is a directive a schema supports.
+type SchemaDirective struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Locations are where the directive can be used, such as FIELD.
+	Locations []string       `json:"locations"`
+	Args      []*SchemaInput `json:"args"`
+}
+
+// TypeRef is a reference to a type, which is a named type, or a list or
+// non-null type wrapping the type OfType.
+type TypeRef struct {
+	// Kind is the kind of the type, which is LIST or NON_NULL for
+	// wrapping types.
+	Kind string `json:"kind"`
+	// Name is the name of named types, and empty for wrapping types.
+	Name   string   `json:"name"`
+	OfType *TypeRef `json:"ofType"`
+}
+
+// String returns the type as written in GraphQL, such as [ID!]!.
+func (t *TypeRef) String() string {
+	if t == nil {
+		return ""
+	}
+	switch t.Kind {
+	case "LIST":
+		return "[" + t.OfType.String() + "]"
+	case "NON_NULL":
+		return t.OfType.String() + "!"
+	}
+	return t.Name
+}
+
+// NamedType returns the name of the named type the reference wraps.
+func (t *TypeRef) NamedType() string {
+	for t != nil && t.Name == "" {
+		t = t.OfType
+	}
+	if t == nil {
+		return ""
+	}
+	return t.Name
+}
+
+// schemaCache holds the schema introspected by a client. sem is held
+// while introspecting, so that the schema is only introspected once at a
+// time, while callers waiting for it can give up.
+type schemaCache struct {
+	sem chan struct{}
+
+	mu     sync.Mutex
+	schema *Schema
+}
+
+func newSchemaCache() *schemaCache {
+	return &schemaCache{sem: make(chan struct{}, 1)}
+}
+
+// Schema returns the schema of the server, running the introspection
+// query the first time it is called and keeping the schema for later
+// calls, and for the clients made with Client.With. Calls made while the
+// schema is being introspected wait for it. If introspection fails, the
+// error is returned and the next call tries again. Use RefreshSchema to
+// introspect the schema again once it has changed.
+func (c *Client) Schema(ctx context.Context) (*Schema, error) {
+	c.schema.mu.Lock()
+	schema := c.schema.schema
+	c.schema.mu.Unlock()
+	if schema != nil {
+		return schema, nil
+	}
+	return c.introspect(ctx, false)
+}
+
+// RefreshSchema runs the introspection query again, replacing the schema
+// returned by Schema.
+func (c *Client) RefreshSchema(ctx context.Context) (*Schema, error) {
+	return c.introspect(ctx, true)
+}
+
+// introspect runs the introspection query and keeps the schema, unless
+// another call has kept one while it waited and refresh is false.
+func (c *Client) introspect(ctx context.Context, refresh bool) (*Schema, error) {
+	select {
+	case c.schema.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-c.schema.sem }()
+	if !refresh {
+		c.schema.mu.Lock()
+		schema := c.schema.schema
+		c.schema.mu.Unlock()
+		if schema != nil {
+			return schema, nil
+		}
+	}
+	req := NewRequest(introspectionQuery)
+	req.CacheTTL(-1) // the schema is kept here instead
+	var resp struct {
+		Schema *struct {
+			QueryType        *struct{ Name string } `json:"queryType"`
+			MutationType     *struct{ Name string } `json:"mutationType"`
+			SubscriptionType *struct{ Name string } `json:"subscriptionType"`
+			Types            []*SchemaType          `json:"types"`
+			Directives       []*SchemaDirective     `json:"directives"`
+		} `json:"__schema"`
+	}
+	if err := c.Run(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Schema == nil {
+		return nil, errors.New("graphql: introspection returned no schema")
+	}
+	schema := &Schema{
+		Types:      resp.Schema.Types,
+		Directives: resp.Schema.Directives,
+		types:      make(map[string]*SchemaType, len(resp.Schema.Types)),
+	}
+	if t := resp.Schema.QueryType; t != nil {
+		schema.QueryType = t.Name
+	}
+	if t := resp.Schema.MutationType; t != nil {
+		schema.MutationType = t.Name
+	}
+	if t := resp.Schema.SubscriptionType; t != nil {
+		schema.SubscriptionType = t.Name
+	}
+	for _, t := range schema.Types {
+		schema.types[t.Name] = t
+	}
+	c.schema.mu.Lock()
+	c.schema.schema = schema
+	c.schema.mu.Unlock()
+	return schema, nil
+}
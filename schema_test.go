@@ -0,0 +1,118 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+const introspectionResponse = `{"data":{"__schema":{
+	"queryType":{"name":"Query"},
+	"mutationType":null,
+	"subscriptionType":null,
+	"types":[
+		{"kind":"OBJECT","name":"Query","fields":[
+			{"name":"user","args":[{"name":"id","type":{"kind":"NON_NULL","name":null,"ofType":{"kind":"SCALAR","name":"ID","ofType":null}},"defaultValue":null}],
+			"type":{"kind":"OBJECT","name":"User","ofType":null},"isDeprecated":false,"deprecationReason":null},
+			{"name":"tags","args":[],"type":{"kind":"NON_NULL","name":null,"ofType":{"kind":"LIST","name":null,"ofType":{"kind":"NON_NULL","name":null,"ofType":{"kind":"SCALAR","name":"String","ofType":null}}}},
+			"isDeprecated":true,"deprecationReason":"Use labels"}
+		],"inputFields":null,"interfaces":[],"enumValues":null,"possibleTypes":null},
+		{"kind":"OBJECT","name":"User","description":"A user","fields":[],"inputFields":null,"interfaces":[],"enumValues":null,"possibleTypes":null},
+		{"kind":"SCALAR","name":"ID","fields":null,"inputFields":null,"interfaces":null,"enumValues":null,"possibleTypes":null}
+	],
+	"directives":[{"name":"include","locations":["FIELD"],"args":[{"name":"if","type":{"kind":"NON_NULL","name":null,"ofType":{"kind":"SCALAR","name":"Boolean","ofType":null}},"defaultValue":null}]}]
+}}}`
+
+func TestSchema(t *testing.T) {
+	is := is.New(t)
+	var mu sync.Mutex
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Query string }
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		is.True(strings.Contains(body.Query, "__schema"))
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		io.WriteString(w, introspectionResponse)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithCache(time.Minute))
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Schema(ctx)
+			is.NoErr(err)
+		}()
+	}
+	wg.Wait()
+	schema, err := client.With(WithCacheControl()).Schema(ctx)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+
+	is.Equal(schema.QueryType, "Query")
+	is.Equal(schema.MutationType, "")
+	is.Equal(len(schema.Types), 3)
+	is.Equal(schema.Type("User").Description, "A user")
+	is.True(schema.Type("Post") == nil)
+	user := schema.Type("Query").Field("user")
+	is.Equal(user.Type.String(), "User")
+	is.Equal(user.Args[0].Type.String(), "ID!")
+	is.Equal(user.Args[0].Type.NamedType(), "ID")
+	tags := schema.Type("Query").Field("tags")
+	is.Equal(tags.Type.String(), "[String!]!")
+	is.True(tags.IsDeprecated)
+	is.True(schema.Type("Query").Field("posts") == nil)
+	is.Equal(schema.Directives[0].Locations, []string{"FIELD"})
+
+	// Refreshing introspects the schema again, bypassing the cache
+	refreshed, err := client.RefreshSchema(ctx)
+	is.NoErr(err)
+	is.Equal(calls, 2)
+	is.True(refreshed != schema)
+	schema, err = client.Schema(ctx)
+	is.NoErr(err)
+	is.True(schema == refreshed)
+}
+
+func TestSchemaErrors(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			io.WriteString(w, `{"errors":[{"message":"introspection is disabled"}]}`)
+			return
+		}
+		io.WriteString(w, introspectionResponse)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	_, err := client.Schema(ctx)
+	is.Equal(err.Error(), "graphql: introspection is disabled")
+
+	// Failures are not kept
+	schema, err := client.Schema(ctx)
+	is.NoErr(err)
+	is.Equal(schema.QueryType, "Query")
+	is.Equal(calls, 2)
+}
@@ -0,0 +1,46 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Poll emulates a subscription for servers, or networks, where a
+// persistent connection is not available. It runs req every interval
+// until ctx is done, calling fn with the data of the response whenever it
+// differs from the data last delivered. A subscription operation is run
+// as a query with the same selection set.
+//
+// Poll returns when ctx is done, or when running the request or fn
+// returns an error.
+func (c *Client) Poll(ctx context.Context, req *Request, interval time.Duration, fn func(data json.RawMessage) error) error {
+	// Run the subscription as a query: the rest of the request, including
+	// its headers and variables, is shared
+	poll := *req
+	if typ, offset := findOperation(req.q, req.operationName); typ == "subscription" {
+		poll.q = req.q[:offset] + "query" + req.q[offset+len("subscription"):]
+		poll.hash = &documentHash{}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var last json.RawMessage
+	for {
+		var data json.RawMessage
+		if err := c.Run(ctx, &poll, &data); err != nil {
+			return err
+		}
+		if last == nil || !bytes.Equal(data, last) {
+			last = data
+			if err := fn(data); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
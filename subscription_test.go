@@ -0,0 +1,45 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestPoll(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.True(strings.HasPrefix(string(b), `{"query":"query OnChange { value }"`))
+		// the value changes every other poll
+		fmt.Fprintf(w, `{"data":{"value":%d}}`, calls/2)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	var values []string
+	err := client.Poll(ctx, NewRequest("subscription OnChange { value }"), time.Millisecond, func(data json.RawMessage) error {
+		values = append(values, string(data))
+		if len(values) == 3 {
+			return io.EOF
+		}
+		return nil
+	})
+	is.Equal(err, io.EOF)
+	is.Equal(values, []string{`{"value":0}`, `{"value":1}`, `{"value":2}`})
+	is.Equal(calls, 4) // the unchanged third result is not delivered
+}
@@ -0,0 +1,80 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Tracing is the timing of the server resolving an operation, in the
+// Apollo tracing format that servers report in the tracing field of the
+// response extensions.
+//
+// See https://github.com/apollographql/apollo-tracing
+type Tracing struct {
+	Version    int           `json:"version"`
+	StartTime  time.Time     `json:"startTime"`
+	EndTime    time.Time     `json:"endTime"`
+	Duration   time.Duration `json:"duration"`
+	Parsing    TracingPhase  `json:"parsing"`
+	Validation TracingPhase  `json:"validation"`
+	Execution  struct {
+		Resolvers []ResolverTiming `json:"resolvers"`
+	} `json:"execution"`
+}
+
+// TracingPhase is the timing of a phase of resolving an operation. The
+// offset is relative to the start of the operation.
+type TracingPhase struct {
+	StartOffset time.Duration `json:"startOffset"`
+	Duration    time.Duration `json:"duration"`
+}
+
+// ResolverTiming is the timing of resolving a single field of the
+// response. The offset is relative to the start of the operation.
+type ResolverTiming struct {
+	Path        []interface{} `json:"path"`
+	ParentType  string        `json:"parentType"`
+	FieldName   string        `json:"fieldName"`
+	ReturnType  string        `json:"returnType"`
+	StartOffset time.Duration `json:"startOffset"`
+	Duration    time.Duration `json:"duration"`
+}
+
+// PathString returns the path of the field in dotted form, such as
+// user.posts.3.title.
+func (r ResolverTiming) PathString() string {
+	return pathString(r.Path)
+}
+
+// Slowest returns up to n resolvers of the execution, slowest first.
+func (t *Tracing) Slowest(n int) []ResolverTiming {
+	resolvers := append([]ResolverTiming(nil), t.Execution.Resolvers...)
+	sort.SliceStable(resolvers, func(i, j int) bool {
+		return resolvers[i].Duration > resolvers[j].Duration
+	})
+	if n < len(resolvers) {
+		resolvers = resolvers[:n]
+	}
+	return resolvers
+}
+
+// ParseTracing parses the tracing field of the extensions of a response,
+// as passed to the function set by Request.OnExtensions. It returns nil if
+// the server did not report tracing.
+func ParseTracing(extensions map[string]interface{}) (*Tracing, error) {
+	raw, ok := extensions["tracing"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var tracing Tracing
+	if err := json.Unmarshal(b, &tracing); err != nil {
+		return nil, fmt.Errorf("graphql: invalid tracing extension: %w", err)
+	}
+	return &tracing, nil
+}
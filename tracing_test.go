@@ -0,0 +1,57 @@
+package graphql
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestParseTracing(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"user":{"name":"Mat"}},"extensions":{"tracing":{
+			"version": 1,
+			"startTime": "2017-07-28T14:20:32.106Z",
+			"endTime": "2017-07-28T14:20:32.109Z",
+			"duration": 2694443,
+			"parsing": {"startOffset": 34953, "duration": 351736},
+			"validation": {"startOffset": 412349, "duration": 670107},
+			"execution": {"resolvers": [
+				{"path": ["user"], "parentType": "Query", "fieldName": "user", "returnType": "User", "startOffset": 1172456, "duration": 215657},
+				{"path": ["user", "name"], "parentType": "User", "fieldName": "name", "returnType": "String!", "startOffset": 1350000, "duration": 1500000}
+			]}
+		}}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	req := NewRequest("query {}")
+	var tracing *Tracing
+	var err error
+	req.OnExtensions(func(ext map[string]interface{}) {
+		tracing, err = ParseTracing(ext)
+	})
+	is.NoErr(client.Run(ctx, req, nil))
+	is.NoErr(err)
+	is.Equal(tracing.Version, 1)
+	is.Equal(tracing.Duration, 2694443*time.Nanosecond)
+	is.Equal(tracing.EndTime.Sub(tracing.StartTime), 3*time.Millisecond)
+	is.Equal(tracing.Parsing.Duration, 351736*time.Nanosecond)
+	is.Equal(len(tracing.Execution.Resolvers), 2)
+	slowest := tracing.Slowest(1)
+	is.Equal(len(slowest), 1)
+	is.Equal(slowest[0].PathString(), "user.name")
+	is.Equal(slowest[0].Duration, 1500*time.Microsecond)
+
+	tracing, err = ParseTracing(map[string]interface{}{"cost": 1})
+	is.NoErr(err)
+	is.True(tracing == nil)
+}
@@ -0,0 +1,215 @@
+package graphql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// addTypename returns q with __typename selected in every selection set
+// except the root selection sets of operations, which cannot be decoded
+// into types other than the root type. Documents that cannot be parsed are
+// returned unchanged for the server to reject.
+func addTypename(q string) string {
+	defs, err := parseDefinitions(q)
+	if err != nil {
+		return q
+	}
+	var b strings.Builder
+	last := 0
+	for _, def := range defs {
+		var depth, parens int
+		for i := def.start; i < def.end; {
+			switch q[i] {
+			case '#':
+				i = skipIgnored(q, i)
+				continue
+			case '"':
+				i = skipString(q, i)
+				continue
+			case '(':
+				parens++
+			case ')':
+				parens--
+			case '{':
+				// Braces within arguments are input objects
+				if parens > 0 {
+					break
+				}
+				depth++
+				if depth > 1 || def.keyword == "fragment" {
+					b.WriteString(q[last : i+1])
+					b.WriteString(" __typename")
+					last = i + 1
+				}
+			case '}':
+				if parens == 0 {
+					depth--
+				}
+			}
+			i++
+		}
+	}
+	b.WriteString(q[last:])
+	return b.String()
+}
+
+// minify returns q without comments and the whitespace and commas that
+// do not separate tokens, preserving strings.
+func minify(q string) string {
+	var b strings.Builder
+	b.Grow(len(q))
+	var last byte
+	space := false
+	for i := 0; i < len(q); {
+		switch c := q[i]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == ',' || c == '#' || strings.HasPrefix(q[i:], "\ufeff"):
+			i = skipIgnored(q, i)
+			space = true
+		default:
+			// Keep names and numbers apart, numbers from any minus sign
+			// following them, and strings from each other, since an empty
+			// string followed by a string would begin a block string
+			if space && (isNameChar(last) && (isNameChar(c) || c == '-') || last == '"' && c == '"') {
+				b.WriteByte(' ')
+			}
+			space = false
+			if c == '"' {
+				end := skipString(q, i)
+				b.WriteString(q[i:end])
+				i, last = end, '"'
+				continue
+			}
+			b.WriteByte(c)
+			i, last = i+1, c
+		}
+	}
+	return b.String()
+}
+
+// inlineVariables returns q with the variables in vars written as
+// literals wherever the operation with the given name, and the fragments,
+// use them, and removed from the variable definitions of the operation.
+func inlineVariables(q, operation string, vars map[string]interface{}) (string, error) {
+	if len(vars) == 0 {
+		return q, nil
+	}
+	literals := make(map[string]string, len(vars))
+	for name, value := range vars {
+		literal, err := graphqlLiteral(value)
+		if err != nil {
+			return "", fmt.Errorf("graphql: cannot inline variable $%s: %w", name, err)
+		}
+		literals[name] = literal
+	}
+	op, defs, err := operationDefinition(q, operation)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	last := 0
+	for _, def := range defs {
+		if def.keyword != "fragment" && def.start != op.start {
+			continue
+		}
+		i := def.start
+		if def.keyword != "fragment" {
+			// Remove the definitions of the inlined variables
+			open := skipIgnored(q, skipName(q, skipIgnored(q, skipName(q, def.start))))
+			if open < def.end && q[open] == '(' {
+				end := skipValue(q, open)
+				var kept []string
+				for _, entry := range splitVariableDefinitions(q[open+1 : end-1]) {
+					if _, ok := literals[entry[1:skipName(entry, 1)]]; !ok {
+						kept = append(kept, entry)
+					}
+				}
+				b.WriteString(q[last:open])
+				if len(kept) > 0 {
+					b.WriteString("(" + strings.Join(kept, ", ") + ")")
+				} else if q[open-1] == ' ' {
+					for end < def.end && q[end] == ' ' {
+						end++
+					}
+				}
+				last, i = end, end
+			}
+		}
+		for i < def.end {
+			switch q[i] {
+			case '#':
+				i = skipIgnored(q, i)
+			case '"':
+				i = skipString(q, i)
+			case '$':
+				end := skipName(q, i+1)
+				if literal, ok := literals[q[i+1:end]]; ok {
+					b.WriteString(q[last:i])
+					b.WriteString(literal)
+					last = end
+				}
+				i = end
+			default:
+				i++
+			}
+		}
+	}
+	b.WriteString(q[last:])
+	return b.String(), nil
+}
+
+// splitVariableDefinitions splits the variable definitions of an
+// operation, without the parentheses around them, into the definition of
+// each variable, starting with its $name.
+func splitVariableDefinitions(s string) []string {
+	var entries []string
+	start := -1
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '#':
+			i = skipIgnored(s, i)
+			continue
+		case '"':
+			i = skipString(s, i)
+			continue
+		case '$':
+			if start >= 0 {
+				entries = append(entries, strings.TrimRight(s[start:i], " \t\r\n,"))
+			}
+			start = i
+		}
+		i++
+	}
+	if start >= 0 {
+		entries = append(entries, strings.TrimRight(s[start:], " \t\r\n,"))
+	}
+	return entries
+}
+
+// UseInlineVariables writes the values of the variables into the
+// documents sent, as GraphQL literals, in place of sending them in the
+// variables of the request, for legacy servers and gateways that do not
+// support variables. Variables without a value are left to their default.
+func UseInlineVariables() ClientOption {
+	return func(client *Client) {
+		client.inlineVariables = true
+	}
+}
+
+// WithMinify strips comments and the whitespace that does not separate
+// tokens from the documents sent, to shrink requests. The query logged is
+// the document before it was minified.
+func WithMinify() ClientOption {
+	return func(client *Client) {
+		client.minify = true
+	}
+}
+
+// WithTypename adds __typename to every selection set of the queries
+// sent, other than the root selection set of the operation, as needed to
+// tell apart the types of unions and interfaces, and to normalize
+// responses for caching, without adding it to each query by hand.
+func WithTypename() ClientOption {
+	return func(client *Client) {
+		client.addTypename = true
+	}
+}
@@ -0,0 +1,151 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestAddTypename(t *testing.T) {
+	is := is.New(t)
+	is.Equal(addTypename(`{ user { id } }`), `{ user { __typename id } }`)
+	is.Equal(addTypename(`query Q($f: In = {a: 1}) { search(filter: {b: "{"}) { ... on User { name } ...F } }
+fragment F on T { id # }
+}`), `query Q($f: In = {a: 1}) { search(filter: {b: "{"}) { __typename ... on User { __typename name } ...F } }
+fragment F on T { __typename id # }
+}`)
+	is.Equal(addTypename(`mutation { save(input: {tags: [{a: 1}]}) { ok } }`), `mutation { save(input: {tags: [{a: 1}]}) { __typename ok } }`)
+	is.Equal(addTypename(`query { a(s: """}{""") { b } }`), `query { a(s: """}{""") { __typename b } }`)
+	is.Equal(addTypename(`{ a }`), `{ a }`)
+	is.Equal(addTypename(`invalid { a { b } }`), `invalid { a { b } }`)
+}
+
+func TestWithTypename(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query string
+		}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		is.Equal(body.Query, `query { node { __typename id } }`)
+		io.WriteString(w, `{"data":{"node":{"__typename":"User","id":"1"}}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithTypename())
+	req := NewRequest(`query { node { id } }`)
+	var resp struct {
+		Node struct {
+			Typename string `json:"__typename"`
+			ID       string
+		}
+	}
+	is.NoErr(client.Run(ctx, req, &resp))
+	is.Equal(resp.Node.Typename, "User")
+	is.Equal(req.Query(), `query { node { id } }`) // the request is not modified
+}
+
+func TestMinify(t *testing.T) {
+	is := is.New(t)
+	is.Equal(minify(`
+# Get the user
+query User($id: ID!, $n: Int = 10) {
+	user(id: $id) {
+		name # the display name
+		posts(first: $n, tags: ["a, b", "# c"]) { ...Post }
+		... on Admin @include(if: true) { level }
+	}
+}
+
+fragment Post on Post {
+	title
+	body(format: """  keep
+	this  """)
+	score(min: 1 -1)
+}`), `query User($id:ID!$n:Int=10){user(id:$id){name posts(first:$n tags:["a, b" "# c"]){...Post}...on Admin@include(if:true){level}}}fragment Post on Post{title body(format:"""  keep
+	this  """)score(min:1 -1)}`)
+	is.Equal(minify("\ufeff{ a }"), "{a}")
+}
+
+func TestWithMinify(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query string
+		}
+		is.NoErr(json.NewDecoder(r.Body).Decode(&body))
+		is.Equal(body.Query, `query{node(id:1){id name}}`)
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	var logs []string
+	client := NewClient(srv.URL, WithMinify())
+	client.Log = func(s string) {
+		logs = append(logs, s)
+	}
+	q := "query {\n  node(id: 1) {\n    id, name\n  }\n}"
+	is.NoErr(client.Run(ctx, NewRequest(q), nil))
+	is.True(len(logs) > 0)
+	var logged bool
+	for _, s := range logs {
+		logged = logged || s == ">> query: "+q
+	}
+	is.True(logged) // the original query is logged
+}
+
+func TestInlineVariables(t *testing.T) {
+	is := is.New(t)
+	q, err := inlineVariables(`query Items($id: ID!, $first: Int = 10, $filter: Filter) {
+	items(id: $id, first: $first, filter: $filter, note: "$id") { ...F }
+}
+fragment F on Item { tags(in: $filter) }
+query Other($id: ID!) { other(id: $id) }`, "Items", map[string]interface{}{
+		"id":     "a\"b\n",
+		"filter": map[string]interface{}{"state": Enum[string]{Value: "OPEN"}, "min": 1.5},
+	})
+	is.NoErr(err)
+	is.Equal(q, `query Items($first: Int = 10) {
+	items(id: "a\"b\n", first: $first, filter: {min: 1.5, state: OPEN}, note: "$id") { ...F }
+}
+fragment F on Item { tags(in: {min: 1.5, state: OPEN}) }
+query Other($id: ID!) { other(id: $id) }`)
+
+	q, err = inlineVariables(`mutation ($ids: [ID!]!) { delete(ids: $ids) }`, "", map[string]interface{}{"ids": []string{"1", "2"}})
+	is.NoErr(err)
+	is.Equal(q, `mutation { delete(ids: ["1", "2"]) }`)
+
+	_, err = inlineVariables(`query A { a }`, "B", map[string]interface{}{"id": 1})
+	is.True(err != nil)
+}
+
+func TestUseInlineVariables(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `{"query":"query { user(id: \"1\") { name } }","variables":null}`+"\n")
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, UseInlineVariables())
+	req := NewRequest(`query ($id: ID!) { user(id: $id) { name } }`)
+	req.Var("id", ID("1"))
+	is.NoErr(client.Run(ctx, req, nil))
+}
@@ -0,0 +1,250 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// unixScheme is the prefix of endpoints served over a unix domain socket,
+// which take the form unix:///path/to/socket:/http/path.
+const unixScheme = "unix://"
+
+// parseUnixEndpoint splits a unix socket endpoint into the path of the
+// socket and the HTTP path to request. ok is false if endpoint does not
+// use the unix scheme.
+func parseUnixEndpoint(endpoint string) (socket, path string, ok bool) {
+	if !strings.HasPrefix(endpoint, unixScheme) {
+		return "", "", false
+	}
+	socket = strings.TrimPrefix(endpoint, unixScheme)
+	path = "/"
+	if i := strings.Index(socket, ":"); i >= 0 {
+		socket, path = socket[:i], socket[i+1:]
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+	}
+	return socket, path, true
+}
+
+// resolveEndpoint returns the HTTP URL used to request endpoint. Unix
+// socket endpoints are registered with the client and given a synthetic
+// host name, which the transport dials as the socket.
+func (c *Client) resolveEndpoint(endpoint string) string {
+	socket, path, ok := parseUnixEndpoint(endpoint)
+	if !ok {
+		return endpoint
+	}
+	if c.sockets == nil {
+		c.sockets = make(map[string]string)
+	}
+	host := "unix"
+	if n := len(c.sockets); n > 0 {
+		host += strconv.Itoa(n)
+	}
+	c.sockets[host+":80"] = socket
+	return "http://" + host + path
+}
+
+// configureTransport applies the transport options, and wires any unix
+// socket endpoints, into the transport of the http.Client. Neither can be
+// applied to a custom http.RoundTripper, in which case every request fails
+// with an error.
+func (c *Client) configureTransport() {
+	if len(c.sockets) == 0 && len(c.transportOptions) == 0 {
+		return
+	}
+	transport := cloneTransport(c.httpClient.Transport)
+	if transport == nil {
+		c.transportErr = errors.New("graphql: unix socket endpoints and transport options require an *http.Transport")
+		c.logf("!! %v", c.transportErr)
+		return
+	}
+	for _, option := range c.transportOptions {
+		option(transport)
+	}
+	httpClient := *c.httpClient
+	httpClient.Transport = transport
+	c.httpClient = &httpClient
+	if len(c.sockets) > 0 {
+		wireSockets(transport, c.sockets)
+	}
+}
+
+// wireSockets makes transport dial the unix sockets for the synthetic
+// addresses of unix socket endpoints.
+func wireSockets(transport *http.Transport, sockets map[string]string) {
+	if proxy := transport.Proxy; proxy != nil {
+		// Never send requests for unix socket endpoints to a proxy
+		transport.Proxy = func(r *http.Request) (*url.URL, error) {
+			if _, ok := sockets[canonicalAddr(r.URL)]; ok {
+				return nil, nil
+			}
+			return proxy(r)
+		}
+	}
+	dialer := &net.Dialer{}
+	dial := transport.DialContext
+	if dial == nil {
+		dial = dialer.DialContext
+	}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if socket, ok := sockets[addr]; ok {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+		return dial(ctx, network, addr)
+	}
+}
+
+// canonicalAddr returns the host and port dialed for u.
+func canonicalAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// cloneTransport returns a copy of rt that can be modified, or nil if rt
+// is not an *http.Transport.
+func cloneTransport(rt http.RoundTripper) *http.Transport {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	transport, ok := rt.(*http.Transport)
+	if !ok {
+		return nil
+	}
+	return transport.Clone()
+}
+
+// WithMaxConnsPerHost limits the number of connections to each host,
+// including connections in use. Zero means no limit.
+func WithMaxConnsPerHost(n int) ClientOption {
+	return withTransport(func(transport *http.Transport) {
+		transport.MaxConnsPerHost = n
+	})
+}
+
+// WithMaxIdleConnsPerHost sets the number of idle connections kept open to
+// each host for reuse. The default of the http package is 2, which is low
+// for clients making many concurrent requests to one server.
+func WithMaxIdleConnsPerHost(n int) ClientOption {
+	return withTransport(func(transport *http.Transport) {
+		transport.MaxIdleConnsPerHost = n
+		if transport.MaxIdleConns != 0 && transport.MaxIdleConns < n {
+			transport.MaxIdleConns = n
+		}
+	})
+}
+
+// WithIdleConnTimeout sets how long idle connections are kept open before
+// they are closed.
+func WithIdleConnTimeout(d time.Duration) ClientOption {
+	return withTransport(func(transport *http.Transport) {
+		transport.IdleConnTimeout = d
+	})
+}
+
+// ForceHTTP2 attempts HTTP/2 even when the transport has been given a
+// custom dialer or TLS configuration, which otherwise disables it.
+func ForceHTTP2() ClientOption {
+	return withTransport(func(transport *http.Transport) {
+		transport.ForceAttemptHTTP2 = true
+	})
+}
+
+// DisableKeepAlives closes the connection after every request instead of
+// reusing it.
+func DisableKeepAlives() ClientOption {
+	return withTransport(func(transport *http.Transport) {
+		transport.DisableKeepAlives = true
+	})
+}
+
+// withTransport returns a ClientOption modifying the *http.Transport used
+// by the client, which is a copy of the transport of the http.Client.
+func withTransport(option func(*http.Transport)) ClientOption {
+	return func(client *Client) {
+		client.transportOptions = append(client.transportOptions, option)
+	}
+}
+
+// Warmup opens n connections to each endpoint sharing the load of the
+// client, including the TLS handshake, so that the first requests do not
+// wait for them, as matters for short-lived processes such as serverless
+// functions. The connections are opened with concurrent OPTIONS requests,
+// whose responses are ignored, and are kept open for reuse up to the
+// limit set with WithMaxIdleConnsPerHost, which is 2 by default. Servers
+// using HTTP/2 share one connection between requests, so only one is
+// opened.
+func (c *Client) Warmup(ctx context.Context, n int) error {
+	if c.transportErr != nil {
+		return c.transportErr
+	}
+	var endpoints []string
+	c.endpoints.mu.Lock()
+	for _, ep := range c.endpoints.endpoints[:c.endpoints.replicas] {
+		endpoints = append(endpoints, ep.url)
+	}
+	c.endpoints.mu.Unlock()
+
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for _, endpoint := range endpoints {
+		// Each request holds its connection until every request has one,
+		// so that none can reuse the connection of another
+		connected := make(chan struct{})
+		var holding sync.WaitGroup
+		holding.Add(n)
+		go func() {
+			holding.Wait()
+			close(connected)
+		}()
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(endpoint string) {
+				defer wg.Done()
+				var once sync.Once
+				hold := func() {
+					once.Do(holding.Done)
+					select {
+					case <-connected:
+					case <-ctx.Done():
+					}
+				}
+				defer once.Do(holding.Done)
+				trace := &httptrace.ClientTrace{GotConn: func(httptrace.GotConnInfo) { hold() }}
+				r, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodOptions, endpoint, nil)
+				if err == nil {
+					var res *http.Response
+					if res, err = c.httpClient.Do(r); err == nil {
+						io.Copy(io.Discard, res.Body)
+						res.Body.Close()
+					}
+				}
+				if err != nil {
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("graphql: failed to warm up connection: %w", err))
+					mu.Unlock()
+				}
+			}(endpoint)
+		}
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,172 @@
+package graphql
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestParseUnixEndpoint(t *testing.T) {
+	is := is.New(t)
+	socket, path, ok := parseUnixEndpoint("unix:///var/run/graphql.sock:/query")
+	is.True(ok)
+	is.Equal(socket, "/var/run/graphql.sock")
+	is.Equal(path, "/query")
+
+	socket, path, ok = parseUnixEndpoint("unix:///var/run/graphql.sock")
+	is.True(ok)
+	is.Equal(socket, "/var/run/graphql.sock")
+	is.Equal(path, "/")
+
+	_, _, ok = parseUnixEndpoint("https://machinebox.io/graphql")
+	is.True(!ok)
+}
+
+func TestUnixSocketEndpoint(t *testing.T) {
+	is := is.New(t)
+	socket := filepath.Join(t.TempDir(), "graphql.sock")
+	l, err := net.Listen("unix", socket)
+	is.NoErr(err)
+	var calls int
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		is.Equal(r.URL.Path, "/query")
+		io.WriteString(w, `{"data":{"value":"some data"}}`)
+	})}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient("unix://" + socket + ":/query")
+	var resp struct {
+		Value string
+	}
+	err = client.Run(ctx, NewRequest("query {}"), &resp)
+	is.NoErr(err)
+	is.Equal(calls, 1)
+	is.Equal(resp.Value, "some data")
+}
+
+func TestUnixSocketEndpointIgnoresProxy(t *testing.T) {
+	is := is.New(t)
+	client := NewClient("unix:///var/run/graphql.sock:/query", WithHTTPClient(&http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyURL(&url.URL{Scheme: "http", Host: "proxy:3128"}),
+		},
+	}))
+	transport := client.httpClient.Transport.(*http.Transport)
+
+	r, err := http.NewRequest(http.MethodPost, client.endpoint, nil)
+	is.NoErr(err)
+	proxy, err := transport.Proxy(r)
+	is.NoErr(err)
+	is.True(proxy == nil)
+
+	r, err = http.NewRequest(http.MethodPost, "http://machinebox.io/graphql", nil)
+	is.NoErr(err)
+	proxy, err = transport.Proxy(r)
+	is.NoErr(err)
+	is.Equal(proxy.Host, "proxy:3128")
+}
+
+func TestUnixSocketEndpointCustomRoundTripper(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	client := NewClient("unix:///var/run/graphql.sock:/query", WithHTTPClient(&http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			calls++
+			return nil, nil
+		}),
+	}))
+	err := client.Run(context.Background(), NewRequest("query {}"), nil)
+	is.Equal(err.Error(), "graphql: unix socket endpoints and transport options require an *http.Transport")
+	is.Equal(calls, 0)
+}
+
+func TestTransportOptions(t *testing.T) {
+	is := is.New(t)
+	original := &http.Transport{}
+	client := NewClient("https://machinebox.io/graphql",
+		WithHTTPClient(&http.Client{Transport: original, Timeout: time.Second}),
+		WithMaxConnsPerHost(10),
+		WithMaxIdleConnsPerHost(5),
+		WithIdleConnTimeout(time.Minute),
+		ForceHTTP2(),
+		DisableKeepAlives(),
+	)
+	transport := client.httpClient.Transport.(*http.Transport)
+	is.True(transport != original) // the given transport is not modified
+	is.Equal(original.MaxConnsPerHost, 0)
+	is.Equal(transport.MaxConnsPerHost, 10)
+	is.Equal(transport.MaxIdleConnsPerHost, 5)
+	is.Equal(transport.IdleConnTimeout, time.Minute)
+	is.True(transport.ForceAttemptHTTP2)
+	is.True(transport.DisableKeepAlives)
+	is.Equal(client.httpClient.Timeout, time.Second)
+
+	// the default transport is copied rather than modified
+	client = NewClient("https://machinebox.io/graphql", DisableKeepAlives())
+	is.True(client.httpClient != http.DefaultClient)
+	is.True(!http.DefaultTransport.(*http.Transport).DisableKeepAlives)
+}
+
+func TestWarmup(t *testing.T) {
+	is := is.New(t)
+	var mu sync.Mutex
+	var conns int
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithHTTPClient(srv.Client()), WithMaxIdleConnsPerHost(4))
+	is.NoErr(client.Warmup(ctx, 4))
+	mu.Lock()
+	is.Equal(conns, 4)
+	mu.Unlock()
+
+	// Requests reuse the connections
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			is.NoErr(client.Run(ctx, NewRequest("query {}"), nil))
+		}()
+	}
+	wg.Wait()
+	mu.Lock()
+	is.Equal(conns, 4)
+	mu.Unlock()
+}
+
+func TestWarmupUnreachable(t *testing.T) {
+	is := is.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	err := NewClient("http://127.0.0.1:1").Warmup(ctx, 2)
+	is.True(err != nil)
+}
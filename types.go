@@ -0,0 +1,100 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ID is a value of the ID scalar. It is sent as a string, and decoded
+// from either a string or a number, which some servers send for numeric
+// IDs.
+type ID string
+
+// UnmarshalJSON decodes an ID from a string or a number.
+func (id *ID) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) > 0 && data[0] != '"' {
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("invalid ID %s", data)
+		}
+		*id = ID(n)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*id = ID(s)
+	return nil
+}
+
+// Upload is a file sent as a variable of the Upload scalar, such as
+// req.Var("avatar", graphql.Upload{Name: "avatar.png", R: f}), including
+// within input objects and lists. Uploads are sent following the GraphQL
+// multipart request specification, so need a client created with the
+// UseMultipartRequestSpec option.
+type Upload struct {
+	// Name is the file name sent for the file.
+	Name string
+	// R reads the content of the file.
+	R io.Reader
+}
+
+// errUploadNotMultipart is returned when an Upload is sent by a client
+// that does not use the multipart request specification.
+var errUploadNotMultipart = errors.New("graphql: Upload variables need a client using UseMultipartRequestSpec")
+
+// MarshalJSON fails, as uploads cannot be encoded as JSON.
+func (Upload) MarshalJSON() ([]byte, error) {
+	return nil, errUploadNotMultipart
+}
+
+// Enum is a value of an enum type T, decoded from and sent as a string.
+// Unlike a string, it is rendered as a bare enum value in the arguments
+// of a Builder:
+//
+//	type Role string
+//	graphql.Query("users").Arg("role", graphql.Enum[Role]{Value: "ADMIN"})
+//
+// renders users(role: ADMIN).
+type Enum[T ~string] struct {
+	Value T
+}
+
+// MarshalJSON encodes the enum value as a string.
+func (e Enum[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(e.Value))
+}
+
+// UnmarshalJSON decodes the enum value from a string.
+func (e *Enum[T]) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	e.Value = T(s)
+	return nil
+}
+
+func (e Enum[T]) String() string {
+	return string(e.Value)
+}
+
+func (e Enum[T]) enumValue() string {
+	return string(e.Value)
+}
+
+// enumValue is implemented by Enum values.
+type enumValue interface {
+	enumValue() string
+}
@@ -0,0 +1,86 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+type testRole string
+
+func TestID(t *testing.T) {
+	is := is.New(t)
+	var resp struct {
+		A, B, C ID
+	}
+	is.NoErr(json.Unmarshal([]byte(`{"A":"abc","B":123,"C":null}`), &resp))
+	is.Equal(resp.A, ID("abc"))
+	is.Equal(resp.B, ID("123"))
+	is.Equal(resp.C, ID(""))
+	b, err := json.Marshal(resp.B)
+	is.NoErr(err)
+	is.Equal(string(b), `"123"`)
+
+	q, err := Query("user").Arg("id", Var("id")).Select("name").Var("id", ID("1")).Document()
+	is.NoErr(err)
+	is.Equal(q, `query ($id: ID!) { user(id: $id) { name } }`)
+}
+
+func TestEnum(t *testing.T) {
+	is := is.New(t)
+	var resp struct {
+		Role Enum[testRole]
+	}
+	is.NoErr(json.Unmarshal([]byte(`{"Role":"ADMIN"}`), &resp))
+	is.Equal(resp.Role.Value, testRole("ADMIN"))
+	b, err := json.Marshal(resp.Role)
+	is.NoErr(err)
+	is.Equal(string(b), `"ADMIN"`)
+
+	q, err := Query("users").
+		Arg("role", Enum[testRole]{Value: "ADMIN"}).
+		Arg("filter", map[string]interface{}{"roles": []Enum[testRole]{{"ADMIN"}, {"USER"}}, "name": "Mat"}).
+		Select("id").
+		Document()
+	is.NoErr(err)
+	is.Equal(q, `query { users(role: ADMIN, filter: {name: "Mat", roles: [ADMIN, USER]}) { id } }`)
+}
+
+func TestUpload(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		is.Equal(r.FormValue("operations"), `{"query":"mutation {}","variables":{"input":{"avatar":null,"name":"Mat"},"photos":[null]}}`)
+		is.Equal(r.FormValue("map"), `{"upload0":["variables.input.avatar"],"upload1":["variables.photos.0"]}`)
+		for _, key := range []string{"upload0", "upload1"} {
+			file, header, err := r.FormFile(key)
+			is.NoErr(err)
+			b, err := io.ReadAll(file)
+			is.NoErr(err)
+			is.Equal(string(b), "content of "+header.Filename)
+		}
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	req := NewRequest("mutation {}")
+	req.Var("input", map[string]interface{}{
+		"name":   "Mat",
+		"avatar": Upload{Name: "avatar.png", R: strings.NewReader("content of avatar.png")},
+	})
+	req.Var("photos", []Upload{{Name: "photo.jpg", R: strings.NewReader("content of photo.jpg")}})
+	is.NoErr(NewClient(srv.URL, UseMultipartRequestSpec()).Run(ctx, req, nil))
+
+	err := NewClient(srv.URL).Run(ctx, req, nil)
+	is.True(errors.Is(err, errUploadNotMultipart))
+}
@@ -0,0 +1,222 @@
+package graphql
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// variableDefinition is a variable declared by an operation.
+type variableDefinition struct {
+	name string
+	// typ is the type of the variable, such as [ID!]!
+	typ string
+	// defaultValue is the default value of the variable, if it has one
+	defaultValue string
+}
+
+// required reports whether the variable must be given a value that is not
+// null.
+func (v variableDefinition) required() bool {
+	return strings.HasSuffix(v.typ, "!") && v.defaultValue == ""
+}
+
+// variableDefinitions returns the variables declared by the operation
+// with the given name in q, or the first operation if name is empty. ok
+// is false if there is no such operation.
+func variableDefinitions(q, name string) (defs []variableDefinition, ok bool) {
+	typ, offset := findOperation(q, name)
+	if typ == "" {
+		return nil, false
+	}
+	if offset < 0 {
+		return nil, true // the shorthand syntax declares no variables
+	}
+	i := skipIgnored(q, skipName(q, offset))
+	i = skipIgnored(q, skipName(q, i))
+	if i >= len(q) || q[i] != '(' {
+		return nil, true
+	}
+	for i = skipIgnored(q, i+1); i < len(q) && q[i] == '$'; i = skipIgnored(q, i) {
+		start := i + 1
+		i = skipName(q, start)
+		def := variableDefinition{name: q[start:i]}
+		i = skipIgnored(q, i)
+		if i >= len(q) || q[i] != ':' {
+			return nil, false
+		}
+		i = skipIgnored(q, i+1)
+		def.typ, i = parseType(q, i)
+		i = skipIgnored(q, i)
+		if i < len(q) && q[i] == '=' {
+			start := skipIgnored(q, i+1)
+			i = skipValue(q, start)
+			def.defaultValue = q[start:i]
+		}
+		// Skip any directives of the variable
+		for i = skipIgnored(q, i); i < len(q) && q[i] == '@'; i = skipIgnored(q, i) {
+			i = skipIgnored(q, skipName(q, i+1))
+			if i < len(q) && q[i] == '(' {
+				i = skipValue(q, i)
+			}
+		}
+		defs = append(defs, def)
+	}
+	return defs, true
+}
+
+// parseType returns the type starting at i in q, such as [ID!]!, with
+// any ignored tokens within it removed, and the index just past it.
+func parseType(q string, i int) (string, int) {
+	var typ string
+	if i < len(q) && q[i] == '[' {
+		var inner string
+		inner, i = parseType(q, skipIgnored(q, i+1))
+		i = skipIgnored(q, i)
+		if i < len(q) && q[i] == ']' {
+			i++
+		}
+		typ = "[" + inner + "]"
+	} else {
+		start := i
+		i = skipName(q, i)
+		typ = q[start:i]
+	}
+	if j := skipIgnored(q, i); j < len(q) && q[j] == '!' {
+		typ, i = typ+"!", j+1
+	}
+	return typ, i
+}
+
+// skipValue returns the index just past the value starting at i in q,
+// which may be a list or object, or a list of arguments.
+func skipValue(q string, i int) int {
+	var depth int
+	for i < len(q) {
+		switch q[i] {
+		case '#':
+			i = skipIgnored(q, i)
+			continue
+		case '"':
+			i = skipString(q, i)
+			if depth == 0 {
+				return i
+			}
+			continue
+		case '[', '{', '(':
+			depth++
+		case ']', '}', ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case ' ', '\t', '\r', '\n', ',', '$', '@':
+			if depth == 0 {
+				return i
+			}
+		}
+		i++
+	}
+	return i
+}
+
+// VariableError is returned when a request does not give a value to
+// every non-null variable its operation declares without a default, which
+// is checked before sending requests with WithVariableValidation.
+type VariableError struct {
+	// Operation is the name of the operation, if it has one.
+	Operation string
+	// Missing are the names of the variables missing a value.
+	Missing []string
+}
+
+func (e *VariableError) Error() string {
+	names := make([]string, len(e.Missing))
+	for i, name := range e.Missing {
+		names[i] = "$" + name
+	}
+	msg := "graphql: missing values for non-null variables "
+	if len(names) == 1 {
+		msg = "graphql: missing value for non-null variable "
+	}
+	msg += strings.Join(names, ", ")
+	if e.Operation != "" {
+		msg += " of operation " + e.Operation
+	}
+	return msg
+}
+
+// validateVars checks that vars has a value for every non-null variable
+// declared by the operation of req, returning a VariableError if not, and
+// warns of variables of req the operation does not declare. Requests whose
+// operation cannot be found are left for the server to reject.
+func (c *Client) validateVars(req *Request, vars map[string]interface{}) error {
+	defs, ok := variableDefinitions(req.q, req.operationName)
+	if !ok {
+		return nil
+	}
+	declared := make(map[string]bool, len(defs))
+	var missing []string
+	for _, def := range defs {
+		declared[def.name] = true
+		if !def.required() {
+			continue
+		}
+		if def.name == "files" && len(req.files) > 0 {
+			continue // filled in with the files of the request
+		}
+		if value, ok := vars[def.name]; !ok || isNullValue(value) {
+			missing = append(missing, def.name)
+		}
+	}
+	if len(missing) > 0 {
+		return &VariableError{Operation: req.operationName, Missing: missing}
+	}
+	// Only variables set on the request are reported, since the default
+	// variables of the client are not meant for every operation
+	var unknown []string
+	for name := range req.vars {
+		if !declared[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		message := "variable $" + name + " is not declared by the operation"
+		c.logf("!! %s", message)
+		if req.onWarning != nil {
+			req.onWarning(Warning{Message: message, Source: "variables"})
+		}
+	}
+	return nil
+}
+
+// isNullValue reports whether value is sent as null.
+func isNullValue(value interface{}) bool {
+	switch value := value.(type) {
+	case nil, NullValue:
+		return true
+	case interface{ IsNull() bool }:
+		return value.IsNull()
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// WithVariableValidation checks, before sending a request, that it gives
+// a value to every non-null variable its operation declares without a
+// default, failing with a VariableError rather than a validation error
+// from the server. Variables the operation does not declare are reported
+// to the OnWarning function of the request.
+func WithVariableValidation() ClientOption {
+	return func(client *Client) {
+		client.validateVariables = true
+	}
+}
@@ -0,0 +1,72 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestVariableDefinitions(t *testing.T) {
+	is := is.New(t)
+	defs, ok := variableDefinitions(`query Q($id: ID!, $tags: [String!] = ["a", "b"], $n: Int! = 10 @deprecated(reason: "x"), $in: In!) { a }`, "")
+	is.True(ok)
+	is.Equal(defs, []variableDefinition{
+		{name: "id", typ: "ID!"},
+		{name: "tags", typ: "[String!]", defaultValue: `["a", "b"]`},
+		{name: "n", typ: "Int!", defaultValue: "10"},
+		{name: "in", typ: "In!"},
+	})
+
+	defs, ok = variableDefinitions("query A { a }\nmutation B(\n\t$ids: [ ID! ]! # comment\n) { b }", "B")
+	is.True(ok)
+	is.Equal(defs, []variableDefinition{{name: "ids", typ: "[ID!]!"}})
+
+	defs, ok = variableDefinitions(`{ a }`, "")
+	is.True(ok)
+	is.Equal(len(defs), 0)
+
+	_, ok = variableDefinitions(`query A { a }`, "B")
+	is.True(!ok)
+}
+
+func TestWithVariableValidation(t *testing.T) {
+	is := is.New(t)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		calls++
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithVariableValidation())
+	req := NewRequest(`query Items($id: ID!, $first: Int! = 10, $after: String, $filter: Filter!) { items }`)
+	req.OperationName("Items")
+	req.Var("filter", Null)
+	err := client.Run(ctx, req, nil)
+	var varErr *VariableError
+	is.True(errors.As(err, &varErr))
+	is.Equal(varErr.Missing, []string{"id", "filter"})
+	is.Equal(err.Error(), "graphql: missing values for non-null variables $id, $filter of operation Items")
+	is.Equal(calls, 0)
+
+	var warnings []Warning
+	req.OnWarning(func(warning Warning) {
+		warnings = append(warnings, warning)
+	})
+	req.Var("id", "1")
+	req.Var("filter", map[string]interface{}{})
+	req.Var("extra", true)
+	is.NoErr(client.Run(ctx, req, nil))
+	is.Equal(calls, 1)
+	is.Equal(warnings, []Warning{{Message: "variable $extra is not declared by the operation", Source: "variables"}})
+}
@@ -0,0 +1,309 @@
+package graphql
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// VarsFromStruct sets a variable of the request for each exported field
+// of the struct v, or pointer to one, so that inputs can be declared as
+// structs rather than assembled as maps. The name of each variable is
+// taken from the graphql tag of the field, or else its json tag, or else
+// the name of the field, and both tags support the omitempty and "-"
+// options of encoding/json:
+//
+//	type SaveUserVars struct {
+//		ID    string    `graphql:"id"`
+//		Name  *string   `graphql:"name,omitempty"`
+//		Input UserInput `json:"input"`
+//	}
+//
+// Nested structs are converted the same way, except for types
+// implementing json.Marshaler or encoding.TextMarshaler, which are sent
+// as they marshal themselves.
+func (req *Request) VarsFromStruct(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("graphql: VarsFromStruct needs a struct, got %T", v)
+	}
+	vars, err := (*varEncoder)(nil).structVars(rv)
+	if err != nil {
+		return err
+	}
+	for key, value := range vars {
+		req.Var(key, value)
+	}
+	return nil
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// varEncoder converts values into the values of variables, encoding the
+// values of the types registered with WithScalarEncoder. A nil varEncoder
+// converts structs into maps, so that their graphql tags apply, as
+// VarsFromStruct does. Other varEncoders only convert structs containing
+// registered types, naming their fields by their json tags as
+// encoding/json would, so that struct variables are sent the same
+// whether or not they contain registered types.
+type varEncoder struct {
+	encoders map[reflect.Type]func(v interface{}) (interface{}, error)
+	// uses caches whether values of a type contain registered types
+	uses sync.Map
+}
+
+// clone returns a copy of the encoder, which may be nil.
+func (e *varEncoder) clone() *varEncoder {
+	if e == nil {
+		return nil
+	}
+	clone := &varEncoder{encoders: make(map[reflect.Type]func(interface{}) (interface{}, error), len(e.encoders))}
+	for typ, encode := range e.encoders {
+		clone.encoders[typ] = encode
+	}
+	return clone
+}
+
+// structVars converts the struct value rv into a map of variables.
+func (e *varEncoder) structVars(rv reflect.Value) (map[string]interface{}, error) {
+	vars := make(map[string]interface{})
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		var tag string
+		var ok bool
+		if e == nil {
+			tag, ok = f.Tag.Lookup("graphql")
+		}
+		if !ok {
+			tag = f.Tag.Get("json")
+		}
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		omitEmpty := strings.Contains(","+opts+",", ",omitempty,")
+		fv := rv.Field(i)
+		if f.Anonymous && name == "" {
+			// Promote the fields of embedded structs, like encoding/json
+			for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && !isMarshaler(fv.Type()) {
+				embedded, err := e.structVars(fv)
+				if err != nil {
+					return nil, err
+				}
+				for key, value := range embedded {
+					if _, ok := vars[key]; !ok {
+						vars[key] = value
+					}
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		// Like encoding/json, other varEncoders send omitted Optional
+		// fields as null
+		if omitEmpty && isEmptyValue(fv) || e == nil && isOmitted(fv.Interface()) {
+			continue
+		}
+		value, err := e.value(fv)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+// value converts rv into the value of a variable.
+func (e *varEncoder) value(rv reflect.Value) (interface{}, error) {
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	if e != nil {
+		if encode, ok := e.encoders[rv.Type()]; ok {
+			return encode(rv.Interface())
+		}
+		if !e.contains(rv.Type()) {
+			return rv.Interface(), nil
+		}
+	} else if isMarshaler(rv.Type()) {
+		return rv.Interface(), nil
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return e.value(rv.Elem())
+	case reflect.Struct:
+		return e.structVars(rv)
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil, nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			// Byte slices are base64 encoded by encoding/json
+			return rv.Interface(), nil
+		}
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			item, err := e.value(rv.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	case reflect.Map:
+		if rv.IsNil() || rv.Type().Key().Kind() != reflect.String {
+			return rv.Interface(), nil
+		}
+		fields := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			if isOmitted(iter.Value().Interface()) {
+				continue
+			}
+			value, err := e.value(iter.Value())
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", iter.Key().String(), err)
+			}
+			fields[iter.Key().String()] = value
+		}
+		return fields, nil
+	}
+	return rv.Interface(), nil
+}
+
+// contains reports whether values of type t may contain values of
+// registered types.
+func (e *varEncoder) contains(t reflect.Type) bool {
+	if uses, ok := e.uses.Load(t); ok {
+		return uses.(bool)
+	}
+	uses := e.containsType(t, make(map[reflect.Type]bool))
+	e.uses.Store(t, uses)
+	return uses
+}
+
+func (e *varEncoder) containsType(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if _, ok := e.encoders[t]; ok {
+		return true
+	}
+	if t.Kind() == reflect.Ptr {
+		return e.containsType(t.Elem(), visiting)
+	}
+	if visiting[t] || isMarshaler(t) {
+		return false
+	}
+	visiting[t] = true
+	switch t.Kind() {
+	case reflect.Interface:
+		// The dynamic value may be of any type
+		return true
+	case reflect.Slice, reflect.Array:
+		return e.containsType(t.Elem(), visiting)
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && e.containsType(t.Elem(), visiting)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); (f.IsExported() || f.Anonymous) && e.containsType(f.Type, visiting) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// encodeVars returns the variables with the values of types registered
+// with WithScalarEncoder encoded.
+func (c *Client) encodeVars(vars map[string]interface{}) (map[string]interface{}, error) {
+	if c.varEncoder == nil || len(vars) == 0 {
+		return vars, nil
+	}
+	encoded := make(map[string]interface{}, len(vars))
+	for key, value := range vars {
+		v, err := c.varEncoder.value(reflect.ValueOf(value))
+		if err != nil {
+			return nil, fmt.Errorf("graphql: failed to encode variable %s: %w", key, err)
+		}
+		encoded[key] = v
+	}
+	return encoded, nil
+}
+
+// WithScalarEncoder registers a function encoding values of type T in
+// the variables of requests, such as time.Time for a DateTime scalar, so
+// they are sent consistently without converting them at every call site.
+// encode returns the value to send, which is encoded as JSON:
+//
+//	client := graphql.NewClient(endpoint,
+//		graphql.WithScalarEncoder(func(t time.Time) (interface{}, error) {
+//			return t.UTC().Format(time.RFC3339), nil
+//		}),
+//	)
+//
+// Values of type T nested in maps, slices and structs are encoded too.
+// Structs set with Request.Var are sent as encoding/json encodes them,
+// with the names of their json tags, whether or not they contain values
+// of type T; graphql tags only apply to the structs given to
+// Request.VarsFromStruct.
+func WithScalarEncoder[T any](encode func(v T) (interface{}, error)) ClientOption {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	return func(client *Client) {
+		if client.varEncoder == nil {
+			client.varEncoder = &varEncoder{encoders: make(map[reflect.Type]func(interface{}) (interface{}, error))}
+		}
+		client.varEncoder.encoders[typ] = func(v interface{}) (interface{}, error) {
+			return encode(v.(T))
+		}
+	}
+}
+
+// isMarshaler reports whether values of type t, or pointers to them,
+// marshal themselves.
+func isMarshaler(t reflect.Type) bool {
+	for _, marshaler := range []reflect.Type{jsonMarshalerType, textMarshalerType} {
+		if t.Implements(marshaler) || t.Kind() != reflect.Ptr && reflect.PointerTo(t).Implements(marshaler) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyValue reports whether rv is empty according to the omitempty
+// option of encoding/json.
+func isEmptyValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return rv.IsNil()
+	}
+	return false
+}
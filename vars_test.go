@@ -0,0 +1,185 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+type varsBase struct {
+	Tenant string `graphql:"tenant"`
+}
+
+type varsAddress struct {
+	City    string `graphql:"city"`
+	Country string `json:"country,omitempty"`
+}
+
+func TestVarsFromStruct(t *testing.T) {
+	is := is.New(t)
+	name := "Mat"
+	vars := struct {
+		varsBase
+		ID        string        `graphql:"id"`
+		Name      *string       `graphql:"name,omitempty"`
+		Nickname  *string       `graphql:"nickname,omitempty"`
+		Email     string        `json:"email"`
+		Tags      []string      `graphql:"tags,omitempty"`
+		Addresses []varsAddress `graphql:"addresses"`
+		Born      time.Time     `graphql:"born"`
+		Secret    string        `graphql:"-"`
+		Plain     int
+		internal  int
+	}{
+		varsBase:  varsBase{Tenant: "acme"},
+		ID:        "1",
+		Name:      &name,
+		Email:     "mat@example.com",
+		Addresses: []varsAddress{{City: "London"}},
+		Born:      time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC),
+		Secret:    "hidden",
+		Plain:     3,
+	}
+	req := NewRequest("mutation {}")
+	is.NoErr(req.VarsFromStruct(&vars))
+	is.Equal(req.Vars(), map[string]interface{}{
+		"tenant":    "acme",
+		"id":        "1",
+		"name":      "Mat",
+		"email":     "mat@example.com",
+		"addresses": []interface{}{map[string]interface{}{"city": "London"}},
+		"born":      vars.Born,
+		"Plain":     3,
+	})
+
+	err := req.VarsFromStruct("not a struct")
+	is.Equal(err.Error(), "graphql: VarsFromStruct needs a struct, got string")
+}
+
+func TestVarsFromStructRun(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `{"query":"mutation {}","variables":{"address":{"city":"London"},"born":"2000-01-02T00:00:00Z"}}`+"\n")
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	req := NewRequest("mutation {}")
+	is.NoErr(req.VarsFromStruct(struct {
+		Address varsAddress `graphql:"address"`
+		Born    time.Time   `graphql:"born"`
+	}{varsAddress{City: "London"}, time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)}))
+	is.NoErr(NewClient(srv.URL).Run(ctx, req, nil))
+}
+
+type varsDecimal struct {
+	units int64
+}
+
+func TestScalarEncoder(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `{"query":"mutation {}","variables":{"at":"2000-01-02","input":{"Price":"1.50","Skip":null,"when":["2000-01-02"]},"plain":1,"prices":["0.25"]}}`+"\n")
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL,
+		WithScalarEncoder(func(t time.Time) (interface{}, error) {
+			return t.Format("2006-01-02"), nil
+		}),
+		WithScalarEncoder(func(d varsDecimal) (interface{}, error) {
+			return fmt.Sprintf("%d.%02d", d.units/100, d.units%100), nil
+		}),
+	)
+	at := time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+	req := NewRequest("mutation {}")
+	req.Var("at", at)
+	req.Var("plain", 1)
+	req.Var("prices", []varsDecimal{{25}})
+	req.Var("input", struct {
+		Price varsDecimal   `graphql:"price"`
+		When  []*time.Time  `json:"when"`
+		Skip  Optional[int] `graphql:"skip"`
+	}{Price: varsDecimal{150}, When: []*time.Time{&at}})
+	is.NoErr(client.Run(ctx, req, nil))
+	is.Equal(req.Vars()["at"], at) // req is not modified
+}
+
+func TestScalarEncoderStructTags(t *testing.T) {
+	is := is.New(t)
+	type input struct {
+		Name  string        `graphql:"name" json:"title"`
+		Skip  Optional[int] `json:"skip"`
+		Empty string        `json:"empty,omitempty"`
+	}
+	type scalarInput struct {
+		input
+		At time.Time `json:"at"`
+	}
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		bodies = append(bodies, string(b))
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	// Structs are encoded by their json tags, whether or not they contain
+	// values of registered types, and whether or not the client has an
+	// encoder
+	encoder := NewClient(srv.URL, WithScalarEncoder(func(t time.Time) (interface{}, error) {
+		return "at", nil
+	}))
+	for _, client := range []*Client{encoder, NewClient(srv.URL)} {
+		req := NewRequest("mutation {}")
+		req.Var("input", input{Name: "a"})
+		is.NoErr(client.Run(ctx, req, nil))
+	}
+	req := NewRequest("mutation {}")
+	req.Var("input", scalarInput{input: input{Name: "a"}})
+	is.NoErr(encoder.Run(ctx, req, nil))
+	is.Equal(bodies[0], `{"query":"mutation {}","variables":{"input":{"title":"a","skip":null}}}`+"\n")
+	is.Equal(bodies[1], bodies[0])
+	is.Equal(bodies[2], `{"query":"mutation {}","variables":{"input":{"at":"at","skip":null,"title":"a"}}}`+"\n")
+}
+
+func TestDefaultVars(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		is.NoErr(err)
+		is.Equal(string(b), `{"query":"query {}","variables":{"locale":"fr","tenant":"acme"}}`+"\n")
+		io.WriteString(w, `{"data":{}}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL, WithDefaultVars(map[string]interface{}{"tenant": "acme", "locale": "en"}))
+	req := NewRequest("query {}")
+	req.Var("locale", "fr")
+	is.NoErr(client.Run(ctx, req, nil))
+	is.Equal(len(req.Vars()), 1) // req is not modified
+}
@@ -0,0 +1,103 @@
+package graphql
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Warning is a warning the server sent with a response, typically that
+// the operation uses a deprecated field or that the endpoint itself is
+// deprecated.
+type Warning struct {
+	// Message describes the warning.
+	Message string
+	// Path is the path of the response field the warning refers to, if
+	// the server reported one.
+	Path []interface{}
+	// Source is where the warning was found: "extensions" for warnings
+	// in the response extensions, "Warning" or "Deprecation" for warnings
+	// in the headers of the same name, "variables" for variables the
+	// operation does not declare, found by WithVariableValidation, or
+	// "duplicates" for mutations found by WithDuplicateSuppression.
+	Source string
+}
+
+// PathString returns the path of the warning in dotted form, such as
+// user.posts.3.title.
+func (w Warning) PathString() string {
+	return pathString(w.Path)
+}
+
+// OnWarning sets a function called with each warning the server sent
+// with the response, so that uses of deprecated fields can be tracked
+// from the client. Warnings are read from the warnings and deprecations
+// fields of the response extensions, which hold a list of objects with a
+// message and an optional path like errors do, and from the Warning and
+// Deprecation HTTP headers. It is called before Run returns, including
+// when the response also has errors.
+func (req *Request) OnWarning(fn func(warning Warning)) {
+	req.onWarning = fn
+}
+
+// responseWarnings returns the warnings in the headers and extensions of
+// a response.
+func responseWarnings(header http.Header, extensions map[string]interface{}) []Warning {
+	var warnings []Warning
+	for _, value := range header.Values("Warning") {
+		warnings = append(warnings, Warning{Message: warningText(value), Source: "Warning"})
+	}
+	if value := header.Get("Deprecation"); value != "" && value != "false" {
+		message := "endpoint is deprecated"
+		if sunset := header.Get("Sunset"); sunset != "" {
+			message += ", sunset " + sunset
+		}
+		warnings = append(warnings, Warning{Message: message, Source: "Deprecation"})
+	}
+	for _, key := range []string{"warnings", "deprecations"} {
+		list, ok := extensions[key].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, item := range list {
+			warning := Warning{Source: "extensions"}
+			switch item := item.(type) {
+			case string:
+				warning.Message = item
+			case map[string]interface{}:
+				warning.Message, _ = item["message"].(string)
+				warning.Path, _ = item["path"].([]interface{})
+			default:
+				b, _ := json.Marshal(item)
+				warning.Message = string(b)
+			}
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings
+}
+
+// warningText returns the text of a Warning header value, which takes the
+// form 299 agent "text" followed by an optional date. Values not in that
+// form are returned whole.
+func warningText(value string) string {
+	start := strings.IndexByte(value, '"')
+	if start < 0 {
+		return value
+	}
+	var b strings.Builder
+	for i := start + 1; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			if i+1 < len(value) {
+				i++
+				b.WriteByte(value[i])
+			}
+		case '"':
+			return b.String()
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return value
+}
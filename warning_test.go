@@ -0,0 +1,55 @@
+package graphql
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestOnWarning(t *testing.T) {
+	is := is.New(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Warning", `299 - "Field \"email\" is deprecated" "Sat, 25 Aug 2012 23:34:45 GMT"`)
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Sunset", "Sat, 31 Dec 2026 23:59:59 GMT")
+		io.WriteString(w, `{
+			"data": {"user": {"email": "mat@example.com"}},
+			"errors": [{"message": "partial"}],
+			"extensions": {"warnings": [{"message": "user.email is deprecated", "path": ["user", "email"]}, "plain"]}
+		}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	client := NewClient(srv.URL)
+	req := NewRequest("query {}")
+	var warnings []Warning
+	req.OnWarning(func(warning Warning) {
+		warnings = append(warnings, warning)
+	})
+	err := client.Run(ctx, req, nil)
+	is.Equal(err.Error(), "graphql: partial")
+	is.Equal(len(warnings), 4)
+	is.Equal(warnings[0].Message, `Field "email" is deprecated`)
+	is.Equal(warnings[0].Source, "Warning")
+	is.Equal(warnings[1].Message, "endpoint is deprecated, sunset Sat, 31 Dec 2026 23:59:59 GMT")
+	is.Equal(warnings[1].Source, "Deprecation")
+	is.Equal(warnings[2].Message, "user.email is deprecated")
+	is.Equal(warnings[2].PathString(), "user.email")
+	is.Equal(warnings[2].Source, "extensions")
+	is.Equal(warnings[3].Message, "plain")
+}
+
+func TestWarningText(t *testing.T) {
+	is := is.New(t)
+	is.Equal(warningText(`299 api.example.com "Deprecated API"`), "Deprecated API")
+	is.Equal(warningText(`299 - "unterminated`), `299 - "unterminated`)
+	is.Equal(warningText(`no quotes`), "no quotes")
+}